@@ -13,6 +13,21 @@ const (
 	emptyExpressionValue = "<NIL>"
 )
 
+/// Functions
+
+// IsGrouped reports whether the node is an index, property or call expression with Grouped set.
+func IsGrouped(node Node) bool {
+	switch node := node.(type) {
+	case *IndexExpression:
+		return node.Grouped
+	case *PropertyExpression:
+		return node.Grouped
+	case *CallExpression:
+		return node.Grouped
+	}
+	return false
+}
+
 /// Types
 
 // Node is the base interface of the AST.
@@ -21,7 +36,11 @@ type Node interface {
 	// TokenLiteral produces the string literal that the node is associated with.
 	// It is only used for debugging and testing.
 	TokenLiteral() string
+	// String returns a fully parenthesized representation of the node for debugging and testing, which is no valid source in general.
+	// The source of nodes is returned by format.Node.
 	String() string
+	// Pos returns the position of the token that the node is associated with.
+	Pos() token.Position
 }
 
 type Statement interface {
@@ -40,6 +59,9 @@ type Expression interface {
 // Every Monkey program consists of a series of statements.
 type Program struct {
 	Statements []Statement
+	// Comments contains all comments of the program in source order.
+	// They are not part of any statement and are only used by tools like the formatter.
+	Comments []*Comment
 }
 
 func (p *Program) TokenLiteral() string {
@@ -49,6 +71,13 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out strings.Builder
 
@@ -59,6 +88,17 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+// Comment is a line comment, which starts with "//" and extends to the end of the line.
+type Comment struct {
+	// the token.COMMENT token
+	Token token.Token
+}
+
+func (c *Comment) Pos() token.Position { return c.Token.Position }
+
+// Text returns the text of the comment including the leading "//".
+func (c *Comment) Text() string { return c.Token.Literal }
+
 type ExpressionStatement struct {
 	// the first token of the expression
 	Token      token.Token
@@ -67,6 +107,7 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Position }
 func (es *ExpressionStatement) String() string {
 	value := emptyExpressionValue
 	if es.Expression != nil {
@@ -84,6 +125,7 @@ type LetStatement struct {
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Position }
 func (ls *LetStatement) String() string {
 	value := emptyExpressionValue
 	if ls.Value != nil {
@@ -100,6 +142,7 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Position }
 func (rs *ReturnStatement) String() string {
 	value := emptyExpressionValue
 	if rs.ReturnValue != nil {
@@ -108,14 +151,67 @@ func (rs *ReturnStatement) String() string {
 	return fmt.Sprintf("%s %s;", rs.TokenLiteral(), value)
 }
 
+type ThrowStatement struct {
+	// the token.THROW token
+	Token token.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Pos() token.Position  { return ts.Token.Position }
+func (ts *ThrowStatement) String() string {
+	value := emptyExpressionValue
+	if ts.Value != nil {
+		value = ts.Value.String()
+	}
+	return fmt.Sprintf("%s %s;", ts.TokenLiteral(), value)
+}
+
+// ImportStatement binds the module at the path to the name, e.g. import "lib.mk" as lib.
+type ImportStatement struct {
+	// the token.IMPORT token
+	Token token.Token
+	Path  *StringLiteral
+	Name  *Identifier
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Pos() token.Position  { return is.Token.Position }
+func (is *ImportStatement) String() string {
+	path := emptyExpressionValue
+	if is.Path != nil {
+		path = fmt.Sprintf("%q", is.Path.Value)
+	}
+	return fmt.Sprintf("%s %s as %s;", is.TokenLiteral(), path, is.Name)
+}
+
+// ExportStatement exports the binding of the let statement from the module it is part of.
+type ExportStatement struct {
+	// the token.EXPORT token
+	Token     token.Token
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Pos() token.Position  { return es.Token.Position }
+func (es *ExportStatement) String() string {
+	return fmt.Sprintf("%s %s", es.TokenLiteral(), es.Statement)
+}
+
 type BlockStatement struct {
 	// the token.LBRACE token
 	Token      token.Token
 	Statements []Statement
+	// the position of the closing '}'
+	Rbrace token.Position
 }
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Position }
 func (bs *BlockStatement) String() string {
 	var out strings.Builder
 
@@ -134,6 +230,7 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Position }
 func (i *Identifier) String() string       { return i.Value }
 
 type IntegerLiteral struct {
@@ -144,6 +241,7 @@ type IntegerLiteral struct {
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Position }
 func (il *IntegerLiteral) String() string       { return il.TokenLiteral() }
 
 type BooleanLiteral struct {
@@ -154,6 +252,7 @@ type BooleanLiteral struct {
 
 func (bl *BooleanLiteral) expressionNode()      {}
 func (bl *BooleanLiteral) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BooleanLiteral) Pos() token.Position  { return bl.Token.Position }
 func (bl *BooleanLiteral) String() string       { return bl.TokenLiteral() }
 
 type StringLiteral struct {
@@ -163,35 +262,110 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Position }
 func (sl *StringLiteral) String() string       { return sl.TokenLiteral() }
 
+type NullLiteral struct {
+	// the token.NULL token
+	Token token.Token
+}
+
+func (nl *NullLiteral) expressionNode()      {}
+func (nl *NullLiteral) TokenLiteral() string { return nl.Token.Literal }
+func (nl *NullLiteral) Pos() token.Position  { return nl.Token.Position }
+func (nl *NullLiteral) String() string       { return nl.TokenLiteral() }
+
 type FunctionLiteral struct {
 	// the token token.FUNCTION
-	Token      token.Token
+	Token token.Token
+	// The optional name of the function, nil for anonymous functions
+	Name       *Identifier
 	Parameters []*Identifier
-	Body       *BlockStatement
+	// Default values of the parameters at the same index, nil for parameters without default value
+	Defaults []Expression
+	// The optional rest parameter collecting all remaining arguments, possibly nil
+	Rest *Identifier
+	Body *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Position }
 func (fl *FunctionLiteral) String() string {
 	params := []string{}
-	for _, p := range fl.Parameters {
+	for i, p := range fl.Parameters {
+		if def := fl.Default(i); def != nil {
+			params = append(params, fmt.Sprintf("%s = %s", p, def))
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
+	}
+	name := ""
+	if fl.Name != nil {
+		name = " " + fl.Name.String()
+	}
+	return fmt.Sprintf("%s%s(%s) { %s }", fl.TokenLiteral(), name, strings.Join(params, ", "), fl.Body)
+}
+
+// Default returns the default value of the parameter at the given index, or nil if it has none.
+func (fl *FunctionLiteral) Default(index int) Expression {
+	if index < len(fl.Defaults) {
+		return fl.Defaults[index]
+	}
+	return nil
+}
+
+// MacroLiteral is the definition of a macro, which is only valid as value of a top-level let statement.
+// Macros are defined and expanded before the program is executed.
+type MacroLiteral struct {
+	// the token.MACRO token
+	Token      token.Token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) Pos() token.Position  { return ml.Token.Position }
+func (ml *MacroLiteral) String() string {
+	params := []string{}
+	for _, p := range ml.Parameters {
 		params = append(params, p.String())
 	}
-	return fmt.Sprintf("%s(%s) { %s }", fl.TokenLiteral(), strings.Join(params, ", "), fl.Body)
+	return fmt.Sprintf("%s(%s) { %s }", ml.TokenLiteral(), strings.Join(params, ", "), ml.Body)
+}
+
+type SpreadExpression struct {
+	// the token.ELLIPSIS token
+	Token token.Token
+	// the expression evaluating to the array that is spread
+	Value Expression
 }
 
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) Pos() token.Position  { return se.Token.Position }
+func (se *SpreadExpression) String() string       { return fmt.Sprintf("...%s", se.Value) }
+
 type CallExpression struct {
-	// the token token.LPAREN
+	// the token token.LPAREN, or token.PIPE for calls written with the pipeline operator
 	Token token.Token
 	// Something that evaluates to a function
 	Function  Expression
 	Arguments []Expression
+	// the position of the closing ')', unset for pipelines into expressions other than calls
+	Rparen token.Position
+	// Grouped is true if the expression is enclosed in parentheses, which end the short-circuit of optional links within it,
+	// like in "(a?.b).c". The parser only sets it for chains with optional links that are continued after the parentheses.
+	Grouped bool
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Token.Position }
 func (ce *CallExpression) String() string {
 	args := []string{}
 
@@ -205,10 +379,13 @@ type ArrayLiteral struct {
 	// the '[' token
 	Token    token.Token
 	Elements []Expression
+	// the position of the closing ']'
+	Rbracket token.Position
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Position }
 func (al *ArrayLiteral) String() string {
 	elements := []string{}
 
@@ -218,6 +395,83 @@ func (al *ArrayLiteral) String() string {
 	return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
 }
 
+type HashLiteral struct {
+	// the '{' token
+	Token token.Token
+	// the key-value pairs in source order
+	Pairs []HashPair
+	// the position of the closing '}'
+	Rbrace token.Position
+}
+
+// HashPair is a single key-value pair of a HashLiteral.
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Position }
+func (hl *HashLiteral) String() string {
+	pairs := []string{}
+
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key, pair.Value))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
+}
+
+type IndexExpression struct {
+	// the token.LBRACKET or token.OPTIONAL_LBRACKET token
+	Token token.Token
+	Left  Expression
+	Index Expression
+	// Optional is true for the safe navigation form "left?[index]",
+	// which evaluates to null instead of an error if left is null.
+	Optional bool
+	// the position of the closing ']'
+	Rbracket token.Position
+	// Grouped is true if the expression is enclosed in parentheses, which end the short-circuit of optional links within it,
+	// like in "(a?.b).c". The parser only sets it for chains with optional links that are continued after the parentheses.
+	Grouped bool
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Position }
+func (ie *IndexExpression) String() string {
+	operator := "["
+	if ie.Optional {
+		operator = "?["
+	}
+	return fmt.Sprintf("(%s%s%s])", ie.Left, operator, ie.Index)
+}
+
+type PropertyExpression struct {
+	// the token.OPTIONAL_DOT token
+	Token    token.Token
+	Object   Expression
+	Property *Identifier
+	// Optional is true for the safe navigation form "object?.property",
+	// which evaluates to null instead of an error if object is null.
+	Optional bool
+	// Grouped is true if the expression is enclosed in parentheses, which end the short-circuit of optional links within it,
+	// like in "(a?.b).c". The parser only sets it for chains with optional links that are continued after the parentheses.
+	Grouped bool
+}
+
+func (pe *PropertyExpression) expressionNode()      {}
+func (pe *PropertyExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PropertyExpression) Pos() token.Position  { return pe.Token.Position }
+func (pe *PropertyExpression) String() string {
+	operator := "."
+	if pe.Optional {
+		operator = "?."
+	}
+	return fmt.Sprintf("(%s%s%s)", pe.Object, operator, pe.Property)
+}
+
 type PrefixExpression struct {
 	// the prefix token, e.g. token.BANG or token.DASH
 	Token    token.Token
@@ -227,6 +481,7 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Position }
 func (pe *PrefixExpression) String() string {
 	return fmt.Sprintf("(%s%s)", pe.Operator, pe.Right)
 }
@@ -240,6 +495,7 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return ie.Token.Position }
 func (ie *InfixExpression) String() string {
 	return fmt.Sprintf("(%s %s %s)", ie.Left, ie.Operator, ie.Right)
 }
@@ -256,6 +512,7 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Position }
 func (ie *IfExpression) String() string {
 	condition := emptyExpressionValue
 	then := ""
@@ -271,3 +528,34 @@ func (ie *IfExpression) String() string {
 	}
 	return fmt.Sprintf("if (%s) { %s }%s", condition, then, otherwise)
 }
+
+type TryExpression struct {
+	// the token.TRY token
+	Token token.Token
+	Block *BlockStatement
+	// The identifier the caught error is bound to, nil if there is no catch-branch
+	CatchParameter *Identifier
+	// The optional catch-branch, possibly nil
+	Catch *BlockStatement
+	// The optional finally-branch, possibly nil
+	Finally *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) Pos() token.Position  { return te.Token.Position }
+func (te *TryExpression) String() string {
+	block := ""
+	catch := ""
+	finally := ""
+	if te.Block != nil {
+		block = te.Block.String()
+	}
+	if te.Catch != nil {
+		catch = fmt.Sprintf(" catch (%s) { %s }", te.CatchParameter, te.Catch)
+	}
+	if te.Finally != nil {
+		finally = fmt.Sprintf(" finally { %s }", te.Finally)
+	}
+	return fmt.Sprintf("try { %s }%s%s", block, catch, finally)
+}
@@ -0,0 +1,189 @@
+package ast
+
+import "fmt"
+
+/// Functions
+
+// Clone returns a deep copy of the AST, which shares no nodes with the original.
+// The copy is structurally equal to the original, including tokens, positions and comments.
+//
+// Nodes can be shared, e.g. by the program and the functions created from its function literals,
+// so ASTs that are still in use have to be cloned before they are modified, e.g. by Rewrite.
+func Clone(node Node) Node {
+	if isNil(node) {
+		return node
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		return &Program{Statements: cloneStatements(n.Statements), Comments: cloneComments(n.Comments)}
+	case *ExpressionStatement:
+		return &ExpressionStatement{Token: n.Token, Expression: cloneExpression(n.Expression)}
+	case *LetStatement:
+		return &LetStatement{Token: n.Token, Name: cloneIdentifier(n.Name), Value: cloneExpression(n.Value)}
+	case *ReturnStatement:
+		return &ReturnStatement{Token: n.Token, ReturnValue: cloneExpression(n.ReturnValue)}
+	case *ThrowStatement:
+		return &ThrowStatement{Token: n.Token, Value: cloneExpression(n.Value)}
+	case *ImportStatement:
+		clone := &ImportStatement{Token: n.Token, Name: cloneIdentifier(n.Name)}
+		if n.Path != nil {
+			clone.Path = Clone(n.Path).(*StringLiteral)
+		}
+		return clone
+	case *ExportStatement:
+		clone := &ExportStatement{Token: n.Token}
+		if n.Statement != nil {
+			clone.Statement = Clone(n.Statement).(*LetStatement)
+		}
+		return clone
+	case *BlockStatement:
+		return &BlockStatement{Token: n.Token, Statements: cloneStatements(n.Statements), Rbrace: n.Rbrace}
+
+	case *Identifier:
+		clone := *n
+		return &clone
+	case *IntegerLiteral:
+		clone := *n
+		return &clone
+	case *BooleanLiteral:
+		clone := *n
+		return &clone
+	case *StringLiteral:
+		clone := *n
+		return &clone
+	case *NullLiteral:
+		clone := *n
+		return &clone
+	case *FunctionLiteral:
+		return &FunctionLiteral{
+			Token:      n.Token,
+			Name:       cloneIdentifier(n.Name),
+			Parameters: cloneIdentifiers(n.Parameters),
+			Defaults:   cloneExpressions(n.Defaults),
+			Rest:       cloneIdentifier(n.Rest),
+			Body:       cloneBlock(n.Body),
+		}
+	case *MacroLiteral:
+		return &MacroLiteral{Token: n.Token, Parameters: cloneIdentifiers(n.Parameters), Body: cloneBlock(n.Body)}
+	case *SpreadExpression:
+		return &SpreadExpression{Token: n.Token, Value: cloneExpression(n.Value)}
+	case *CallExpression:
+		return &CallExpression{Token: n.Token, Function: cloneExpression(n.Function), Arguments: cloneExpressions(n.Arguments), Rparen: n.Rparen, Grouped: n.Grouped}
+	case *ArrayLiteral:
+		return &ArrayLiteral{Token: n.Token, Elements: cloneExpressions(n.Elements), Rbracket: n.Rbracket}
+	case *HashLiteral:
+		pairs := []HashPair(nil)
+		if n.Pairs != nil {
+			pairs = make([]HashPair, len(n.Pairs))
+		}
+		for index, pair := range n.Pairs {
+			pairs[index] = HashPair{Key: cloneExpression(pair.Key), Value: cloneExpression(pair.Value)}
+		}
+		return &HashLiteral{Token: n.Token, Pairs: pairs, Rbrace: n.Rbrace}
+	case *IndexExpression:
+		return &IndexExpression{
+			Token:    n.Token,
+			Left:     cloneExpression(n.Left),
+			Index:    cloneExpression(n.Index),
+			Optional: n.Optional,
+			Rbracket: n.Rbracket,
+			Grouped:  n.Grouped,
+		}
+	case *PropertyExpression:
+		return &PropertyExpression{
+			Token:    n.Token,
+			Object:   cloneExpression(n.Object),
+			Property: cloneIdentifier(n.Property),
+			Optional: n.Optional,
+			Grouped:  n.Grouped,
+		}
+	case *PrefixExpression:
+		return &PrefixExpression{Token: n.Token, Operator: n.Operator, Right: cloneExpression(n.Right)}
+	case *InfixExpression:
+		return &InfixExpression{Token: n.Token, Left: cloneExpression(n.Left), Operator: n.Operator, Right: cloneExpression(n.Right)}
+	case *IfExpression:
+		return &IfExpression{Token: n.Token, Condition: cloneExpression(n.Condition), Then: cloneBlock(n.Then), Otherwise: cloneBlock(n.Otherwise)}
+	case *TryExpression:
+		return &TryExpression{
+			Token:          n.Token,
+			Block:          cloneBlock(n.Block),
+			CatchParameter: cloneIdentifier(n.CatchParameter),
+			Catch:          cloneBlock(n.Catch),
+			Finally:        cloneBlock(n.Finally),
+		}
+	}
+
+	panic(fmt.Sprintf("ast.Clone: unexpected node type %T", node))
+}
+
+func cloneStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+	clones := make([]Statement, len(stmts))
+	for index, stmt := range stmts {
+		if !isNil(stmt) {
+			clones[index] = Clone(stmt).(Statement)
+		}
+	}
+	return clones
+}
+
+func cloneExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+	clones := make([]Expression, len(exps))
+	for index, exp := range exps {
+		clones[index] = cloneExpression(exp)
+	}
+	return clones
+}
+
+// cloneExpression clones an expression, which may be nil, like a missing default value.
+func cloneExpression(exp Expression) Expression {
+	if isNil(exp) {
+		return exp
+	}
+	return Clone(exp).(Expression)
+}
+
+// cloneIdentifier clones an identifier, which may be nil, like the name of an anonymous function.
+func cloneIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	return Clone(ident).(*Identifier)
+}
+
+func cloneIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+	clones := make([]*Identifier, len(idents))
+	for index, ident := range idents {
+		clones[index] = cloneIdentifier(ident)
+	}
+	return clones
+}
+
+// cloneBlock clones a block, which may be nil, like a missing else-branch.
+func cloneBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return Clone(block).(*BlockStatement)
+}
+
+func cloneComments(comments []*Comment) []*Comment {
+	if comments == nil {
+		return nil
+	}
+	clones := make([]*Comment, len(comments))
+	for index, comment := range comments {
+		clone := *comment
+		clones[index] = &clone
+	}
+	return clones
+}
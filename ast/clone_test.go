@@ -0,0 +1,54 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+/// Tests
+
+func TestClone(t *testing.T) {
+	for index, node := range nodeTypes() {
+		t.Run(reflect.TypeOf(node).Elem().Name(), func(t *testing.T) {
+			original := populated(index)
+			clone := Clone(original)
+
+			if !reflect.DeepEqual(clone, original) {
+				t.Errorf("clone differs from the original")
+			}
+			if !Equal(clone, original, EqualOptions{}) {
+				t.Errorf("clone is not equal to the original")
+			}
+
+			nodes := map[Node]bool{}
+			Inspect(original, func(n Node) bool {
+				nodes[n] = true
+				return true
+			})
+			Inspect(clone, func(n Node) bool {
+				if n != nil && nodes[n] {
+					t.Errorf("clone shares node %T with the original", n)
+				}
+				return true
+			})
+			if program, ok := original.(*Program); ok && program.Comments[0] == clone.(*Program).Comments[0] {
+				t.Errorf("clone shares comments with the original")
+			}
+		})
+	}
+}
+
+func TestCloneNil(t *testing.T) {
+	if clone := Clone(nil); clone != nil {
+		t.Errorf("clone of nil is not nil. got=%T", clone)
+	}
+
+	exp := &IfExpression{Condition: ident("a"), Then: &BlockStatement{}}
+	clone := Clone(exp).(*IfExpression)
+	if clone.Otherwise != nil {
+		t.Errorf("missing else-branch was cloned. got=%+v", clone.Otherwise)
+	}
+	if !Equal(clone, exp, EqualOptions{}) {
+		t.Errorf("clone is not equal to the original")
+	}
+}
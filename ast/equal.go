@@ -0,0 +1,207 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Functions
+
+// Equal reports whether the ASTs a and b are structurally equal, i.e. they consist of nodes of the same types
+// with the same values, tokens and positions. The options allow to ignore tokens and positions.
+//
+// Nil and empty lists are equal, since the parser does not distinguish them. Nil nodes are only equal to nil nodes.
+func Equal(a, b Node, opts EqualOptions) bool {
+	return comparer{opts}.nodes(a, b)
+}
+
+/// Types
+
+// EqualOptions configure the comparison of Equal.
+type EqualOptions struct {
+	// IgnorePositions ignores the positions of tokens, comments and closing delimiters
+	IgnorePositions bool
+	// IgnoreTokens ignores the tokens of nodes, so that only the types and values of the nodes are compared,
+	// e.g. the operator of an infix expression, but not the type or literal of its token.
+	// Comments are still compared by their text.
+	IgnoreTokens bool
+}
+
+// comparer compares nodes with the options of Equal.
+type comparer struct {
+	opts EqualOptions
+}
+
+func (c comparer) nodes(a, b Node) bool {
+	if isNil(a) || isNil(b) {
+		return isNil(a) && isNil(b)
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Program:
+		b := b.(*Program)
+		return c.statements(a.Statements, b.Statements) && c.comments(a.Comments, b.Comments)
+	case *ExpressionStatement:
+		b := b.(*ExpressionStatement)
+		return c.token(a.Token, b.Token) && c.nodes(a.Expression, b.Expression)
+	case *LetStatement:
+		b := b.(*LetStatement)
+		return c.token(a.Token, b.Token) && c.nodes(a.Name, b.Name) && c.nodes(a.Value, b.Value)
+	case *ReturnStatement:
+		b := b.(*ReturnStatement)
+		return c.token(a.Token, b.Token) && c.nodes(a.ReturnValue, b.ReturnValue)
+	case *ThrowStatement:
+		b := b.(*ThrowStatement)
+		return c.token(a.Token, b.Token) && c.nodes(a.Value, b.Value)
+	case *ImportStatement:
+		b := b.(*ImportStatement)
+		return c.token(a.Token, b.Token) && c.nodes(a.Path, b.Path) && c.nodes(a.Name, b.Name)
+	case *ExportStatement:
+		b := b.(*ExportStatement)
+		return c.token(a.Token, b.Token) && c.nodes(a.Statement, b.Statement)
+	case *BlockStatement:
+		b := b.(*BlockStatement)
+		return c.token(a.Token, b.Token) && c.position(a.Rbrace, b.Rbrace) && c.statements(a.Statements, b.Statements)
+
+	case *Identifier:
+		b := b.(*Identifier)
+		return c.token(a.Token, b.Token) && a.Value == b.Value
+	case *IntegerLiteral:
+		b := b.(*IntegerLiteral)
+		return c.token(a.Token, b.Token) && a.Value == b.Value
+	case *BooleanLiteral:
+		b := b.(*BooleanLiteral)
+		return c.token(a.Token, b.Token) && a.Value == b.Value
+	case *StringLiteral:
+		b := b.(*StringLiteral)
+		return c.token(a.Token, b.Token) && a.Value == b.Value
+	case *NullLiteral:
+		b := b.(*NullLiteral)
+		return c.token(a.Token, b.Token)
+	case *FunctionLiteral:
+		b := b.(*FunctionLiteral)
+		if !c.token(a.Token, b.Token) || !c.nodes(a.Name, b.Name) || len(a.Parameters) != len(b.Parameters) {
+			return false
+		}
+		for index := range a.Parameters {
+			if !c.nodes(a.Parameters[index], b.Parameters[index]) || !c.nodes(a.Default(index), b.Default(index)) {
+				return false
+			}
+		}
+		return c.nodes(a.Rest, b.Rest) && c.nodes(a.Body, b.Body)
+	case *MacroLiteral:
+		b := b.(*MacroLiteral)
+		if !c.token(a.Token, b.Token) || len(a.Parameters) != len(b.Parameters) {
+			return false
+		}
+		for index := range a.Parameters {
+			if !c.nodes(a.Parameters[index], b.Parameters[index]) {
+				return false
+			}
+		}
+		return c.nodes(a.Body, b.Body)
+	case *SpreadExpression:
+		b := b.(*SpreadExpression)
+		return c.token(a.Token, b.Token) && c.nodes(a.Value, b.Value)
+	case *CallExpression:
+		b := b.(*CallExpression)
+		return c.token(a.Token, b.Token) && c.position(a.Rparen, b.Rparen) && a.Grouped == b.Grouped &&
+			c.nodes(a.Function, b.Function) && c.expressions(a.Arguments, b.Arguments)
+	case *ArrayLiteral:
+		b := b.(*ArrayLiteral)
+		return c.token(a.Token, b.Token) && c.position(a.Rbracket, b.Rbracket) && c.expressions(a.Elements, b.Elements)
+	case *HashLiteral:
+		b := b.(*HashLiteral)
+		if !c.token(a.Token, b.Token) || !c.position(a.Rbrace, b.Rbrace) || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+		for index := range a.Pairs {
+			if !c.nodes(a.Pairs[index].Key, b.Pairs[index].Key) || !c.nodes(a.Pairs[index].Value, b.Pairs[index].Value) {
+				return false
+			}
+		}
+		return true
+	case *IndexExpression:
+		b := b.(*IndexExpression)
+		return c.token(a.Token, b.Token) && c.position(a.Rbracket, b.Rbracket) && a.Optional == b.Optional && a.Grouped == b.Grouped &&
+			c.nodes(a.Left, b.Left) && c.nodes(a.Index, b.Index)
+	case *PropertyExpression:
+		b := b.(*PropertyExpression)
+		return c.token(a.Token, b.Token) && a.Optional == b.Optional && a.Grouped == b.Grouped &&
+			c.nodes(a.Object, b.Object) && c.nodes(a.Property, b.Property)
+	case *PrefixExpression:
+		b := b.(*PrefixExpression)
+		return c.token(a.Token, b.Token) && a.Operator == b.Operator && c.nodes(a.Right, b.Right)
+	case *InfixExpression:
+		b := b.(*InfixExpression)
+		return c.token(a.Token, b.Token) && a.Operator == b.Operator && c.nodes(a.Left, b.Left) && c.nodes(a.Right, b.Right)
+	case *IfExpression:
+		b := b.(*IfExpression)
+		return c.token(a.Token, b.Token) && c.nodes(a.Condition, b.Condition) && c.nodes(a.Then, b.Then) && c.nodes(a.Otherwise, b.Otherwise)
+	case *TryExpression:
+		b := b.(*TryExpression)
+		return c.token(a.Token, b.Token) && c.nodes(a.Block, b.Block) && c.nodes(a.CatchParameter, b.CatchParameter) &&
+			c.nodes(a.Catch, b.Catch) && c.nodes(a.Finally, b.Finally)
+	}
+
+	panic(fmt.Sprintf("ast.Equal: unexpected node type %T", a))
+}
+
+func (c comparer) statements(a, b []Statement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for index := range a {
+		if !c.nodes(a[index], b[index]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c comparer) expressions(a, b []Expression) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for index := range a {
+		if !c.nodes(a[index], b[index]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c comparer) comments(a, b []*Comment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for index := range a {
+		if a[index].Text() != b[index].Text() || !c.position(a[index].Pos(), b[index].Pos()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c comparer) token(a, b token.Token) bool {
+	if c.opts.IgnoreTokens {
+		return true
+	}
+	return a.Type == b.Type && a.Literal == b.Literal && c.position(a.Position, b.Position)
+}
+
+func (c comparer) position(a, b token.Position) bool {
+	return c.opts.IgnorePositions || a == b
+}
+
+/// helpers
+
+// isNil reports whether the node is nil, including nil pointers of node types like a missing *Identifier.
+func isNil(node Node) bool {
+	return node == nil || reflect.ValueOf(node).IsNil()
+}
@@ -0,0 +1,120 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Tests
+
+// TestEqualComparesAllFields checks for every field of every node type, that changing it makes the nodes unequal,
+// unless the options ignore the change.
+func TestEqualComparesAllFields(t *testing.T) {
+	for index, node := range nodeTypes() {
+		kind := reflect.TypeOf(node).Elem().Name()
+		fields := reflect.TypeOf(node).Elem().NumField()
+
+		t.Run(kind, func(t *testing.T) {
+			a, b := populated(index), populated(index)
+			if !Equal(a, b, EqualOptions{}) {
+				t.Fatalf("equal nodes are not equal")
+			}
+
+			for field := 0; field < fields; field++ {
+				name := reflect.TypeOf(node).Elem().Field(field).Name
+				value := reflect.ValueOf(b).Elem().Field(field)
+
+				// * the option, which makes the changed nodes equal again, nil if there is none
+				var ignoring *EqualOptions
+				switch original := value.Interface().(type) {
+				case token.Token:
+					changed := original
+					changed.Position.Column++
+					value.Set(reflect.ValueOf(changed))
+					ignoring = &EqualOptions{IgnorePositions: true}
+					if Equal(a, b, EqualOptions{IgnoreTokens: true}) != true {
+						t.Errorf("%s: changing the position of the token is not ignored by IgnoreTokens", name)
+					}
+				case token.Position:
+					value.Set(reflect.ValueOf(token.Position{Line: original.Line + 1, Column: original.Column}))
+					ignoring = &EqualOptions{IgnorePositions: true}
+				case string:
+					value.SetString(original + "_")
+				case int64:
+					value.SetInt(original + 1)
+				case bool:
+					value.SetBool(!original)
+				default:
+					if value.Kind() == reflect.Slice {
+						value.Set(value.Slice(0, value.Len()-1))
+					} else {
+						value.Set(reflect.Zero(value.Type()))
+					}
+				}
+
+				if Equal(a, b, EqualOptions{}) {
+					t.Errorf("%s: changed nodes are equal", name)
+				}
+				if ignoring != nil && !Equal(a, b, *ignoring) {
+					t.Errorf("%s: changed nodes are not equal with %+v", name, *ignoring)
+				}
+				b = populated(index)
+			}
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     Node
+		opts     EqualOptions
+		expected bool
+	}{
+		{"nil", nil, nil, EqualOptions{}, true},
+		{"nil and node", nil, ident("a"), EqualOptions{}, false},
+		{"nil pointer and nil", (*Identifier)(nil), nil, EqualOptions{}, true},
+		{"different types", ident("a"), integer(1), EqualOptions{}, false},
+		{"different values", ident("a"), ident("b"), EqualOptions{}, false},
+		{"nil and empty list", &ArrayLiteral{}, &ArrayLiteral{Elements: []Expression{}}, EqualOptions{}, true},
+		{
+			"missing defaults",
+			&FunctionLiteral{Parameters: []*Identifier{ident("a")}, Body: &BlockStatement{}},
+			&FunctionLiteral{Parameters: []*Identifier{ident("a")}, Defaults: []Expression{nil}, Body: &BlockStatement{}},
+			EqualOptions{},
+			true,
+		},
+		{
+			"different tokens",
+			&ExpressionStatement{Token: token.Token{Type: token.LPAREN, Literal: "("}, Expression: ident("a")},
+			&ExpressionStatement{Token: token.Token{Type: token.IDENTIFIER, Literal: "a"}, Expression: ident("a")},
+			EqualOptions{},
+			false,
+		},
+		{
+			"ignored tokens",
+			&ExpressionStatement{Token: token.Token{Type: token.LPAREN, Literal: "("}, Expression: ident("a")},
+			&ExpressionStatement{Token: token.Token{Type: token.IDENTIFIER, Literal: "a"}, Expression: ident("a")},
+			EqualOptions{IgnoreTokens: true},
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		if got := Equal(test.a, test.b, test.opts); got != test.expected {
+			t.Errorf("%s: Equal returned %t, expected %t", test.name, got, test.expected)
+		}
+	}
+}
+
+/// helpers
+
+// populated returns the node type at the index in nodeTypes with all fields populated.
+func populated(index int) Node {
+	node := nodeTypes()[index]
+	populate(node)
+	populateValues(node)
+	return node
+}
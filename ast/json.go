@@ -0,0 +1,523 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+// The JSON representation of a node is an object with the fields
+//
+//	"kind"        the name of the node type, e.g. "LetStatement"
+//	"position"    the position of the node as object with "line" and "column"
+//	"token"       the token of the node as object with "type" and "literal", missing for programs
+//	"attributes"  the values of the node that are no nodes, e.g. the operator of an infix expression
+//	"children"    the child nodes by name, either a node, a list of nodes or null for missing optional children
+//
+// Attributes and children are named like the fields of the node types, starting with a lowercase letter.
+// Comments of programs are represented like nodes of the kind "Comment" in the attribute "comments".
+
+/// Types
+
+func (p *Program) MarshalJSON() ([]byte, error) {
+	return marshalNode("Program", p.Pos(), nil, jsonFields{"comments": p.Comments}, jsonFields{"statements": p.Statements})
+}
+
+// UnmarshalJSON decodes the JSON representation of a program, which is produced by its MarshalJSON method.
+func (p *Program) UnmarshalJSON(data []byte) error {
+	d := &decoder{}
+	node := d.node(data)
+	if d.err != nil {
+		return d.err
+	}
+	program, ok := node.(*Program)
+	if !ok {
+		return fmt.Errorf("expected node of kind Program, got %s", kindOf(node))
+	}
+	*p = *program
+	return nil
+}
+
+func (c *Comment) MarshalJSON() ([]byte, error) {
+	return marshalNode("Comment", c.Pos(), &c.Token, nil, nil)
+}
+
+func (es *ExpressionStatement) MarshalJSON() ([]byte, error) {
+	return marshalNode("ExpressionStatement", es.Pos(), &es.Token, nil, jsonFields{"expression": es.Expression})
+}
+
+func (ls *LetStatement) MarshalJSON() ([]byte, error) {
+	return marshalNode("LetStatement", ls.Pos(), &ls.Token, nil, jsonFields{"name": ls.Name, "value": ls.Value})
+}
+
+func (rs *ReturnStatement) MarshalJSON() ([]byte, error) {
+	return marshalNode("ReturnStatement", rs.Pos(), &rs.Token, nil, jsonFields{"returnValue": rs.ReturnValue})
+}
+
+func (ts *ThrowStatement) MarshalJSON() ([]byte, error) {
+	return marshalNode("ThrowStatement", ts.Pos(), &ts.Token, nil, jsonFields{"value": ts.Value})
+}
+
+func (is *ImportStatement) MarshalJSON() ([]byte, error) {
+	return marshalNode("ImportStatement", is.Pos(), &is.Token, nil, jsonFields{"path": is.Path, "name": is.Name})
+}
+
+func (es *ExportStatement) MarshalJSON() ([]byte, error) {
+	return marshalNode("ExportStatement", es.Pos(), &es.Token, nil, jsonFields{"statement": es.Statement})
+}
+
+func (bs *BlockStatement) MarshalJSON() ([]byte, error) {
+	return marshalNode("BlockStatement", bs.Pos(), &bs.Token, jsonFields{"rbrace": bs.Rbrace}, jsonFields{"statements": bs.Statements})
+}
+
+func (i *Identifier) MarshalJSON() ([]byte, error) {
+	return marshalNode("Identifier", i.Pos(), &i.Token, jsonFields{"value": i.Value}, nil)
+}
+
+func (il *IntegerLiteral) MarshalJSON() ([]byte, error) {
+	return marshalNode("IntegerLiteral", il.Pos(), &il.Token, jsonFields{"value": il.Value}, nil)
+}
+
+func (bl *BooleanLiteral) MarshalJSON() ([]byte, error) {
+	return marshalNode("BooleanLiteral", bl.Pos(), &bl.Token, jsonFields{"value": bl.Value}, nil)
+}
+
+func (sl *StringLiteral) MarshalJSON() ([]byte, error) {
+	return marshalNode("StringLiteral", sl.Pos(), &sl.Token, jsonFields{"value": sl.Value}, nil)
+}
+
+func (nl *NullLiteral) MarshalJSON() ([]byte, error) {
+	return marshalNode("NullLiteral", nl.Pos(), &nl.Token, nil, nil)
+}
+
+func (fl *FunctionLiteral) MarshalJSON() ([]byte, error) {
+	return marshalNode("FunctionLiteral", fl.Pos(), &fl.Token, nil, jsonFields{
+		"name":       fl.Name,
+		"parameters": fl.Parameters,
+		"defaults":   fl.Defaults,
+		"rest":       fl.Rest,
+		"body":       fl.Body,
+	})
+}
+
+func (ml *MacroLiteral) MarshalJSON() ([]byte, error) {
+	return marshalNode("MacroLiteral", ml.Pos(), &ml.Token, nil, jsonFields{"parameters": ml.Parameters, "body": ml.Body})
+}
+
+func (se *SpreadExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("SpreadExpression", se.Pos(), &se.Token, nil, jsonFields{"value": se.Value})
+}
+
+func (ce *CallExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("CallExpression", ce.Pos(), &ce.Token, jsonFields{"rparen": ce.Rparen, "grouped": ce.Grouped}, jsonFields{
+		"function":  ce.Function,
+		"arguments": ce.Arguments,
+	})
+}
+
+func (al *ArrayLiteral) MarshalJSON() ([]byte, error) {
+	return marshalNode("ArrayLiteral", al.Pos(), &al.Token, jsonFields{"rbracket": al.Rbracket}, jsonFields{"elements": al.Elements})
+}
+
+// MarshalJSON represents the pairs of the hash literal by the children "keys" and "values" of the same length.
+func (hl *HashLiteral) MarshalJSON() ([]byte, error) {
+	var keys, values []Expression
+	if hl.Pairs != nil {
+		keys, values = []Expression{}, []Expression{}
+	}
+	for _, pair := range hl.Pairs {
+		keys = append(keys, pair.Key)
+		values = append(values, pair.Value)
+	}
+	return marshalNode("HashLiteral", hl.Pos(), &hl.Token, jsonFields{"rbrace": hl.Rbrace}, jsonFields{"keys": keys, "values": values})
+}
+
+func (ie *IndexExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("IndexExpression", ie.Pos(), &ie.Token, jsonFields{"optional": ie.Optional, "rbracket": ie.Rbracket, "grouped": ie.Grouped}, jsonFields{
+		"left":  ie.Left,
+		"index": ie.Index,
+	})
+}
+
+func (pe *PropertyExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("PropertyExpression", pe.Pos(), &pe.Token, jsonFields{"optional": pe.Optional, "grouped": pe.Grouped}, jsonFields{
+		"object":   pe.Object,
+		"property": pe.Property,
+	})
+}
+
+func (pe *PrefixExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("PrefixExpression", pe.Pos(), &pe.Token, jsonFields{"operator": pe.Operator}, jsonFields{"right": pe.Right})
+}
+
+func (ie *InfixExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("InfixExpression", ie.Pos(), &ie.Token, jsonFields{"operator": ie.Operator}, jsonFields{
+		"left":  ie.Left,
+		"right": ie.Right,
+	})
+}
+
+func (ie *IfExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("IfExpression", ie.Pos(), &ie.Token, nil, jsonFields{
+		"condition": ie.Condition,
+		"then":      ie.Then,
+		"otherwise": ie.Otherwise,
+	})
+}
+
+func (te *TryExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("TryExpression", te.Pos(), &te.Token, nil, jsonFields{
+		"block":          te.Block,
+		"catchParameter": te.CatchParameter,
+		"catch":          te.Catch,
+		"finally":        te.Finally,
+	})
+}
+
+// jsonFields contains the attributes or children of a node by name.
+type jsonFields map[string]interface{}
+
+// jsonNode is the JSON representation of a node.
+type jsonNode struct {
+	Kind       string         `json:"kind"`
+	Position   token.Position `json:"position"`
+	Token      *jsonToken     `json:"token,omitempty"`
+	Attributes jsonFields     `json:"attributes,omitempty"`
+	Children   jsonFields     `json:"children,omitempty"`
+}
+
+// jsonToken is the JSON representation of the token of a node, whose position is the position of the node.
+type jsonToken struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+}
+
+// rawNode is the JSON representation of a node, whose attributes and children are not decoded yet.
+type rawNode struct {
+	Kind       string                     `json:"kind"`
+	Position   token.Position             `json:"position"`
+	Token      *jsonToken                 `json:"token"`
+	Attributes map[string]json.RawMessage `json:"attributes"`
+	Children   map[string]json.RawMessage `json:"children"`
+}
+
+// decoder decodes nodes from their JSON representation.
+// After the first error, all methods return zero values and the error is kept in err.
+type decoder struct {
+	err error
+}
+
+// node decodes a node of any kind, or nil for null.
+// Nodes missing any child that the parser always sets, like the value of a let statement, are rejected.
+func (d *decoder) node(data json.RawMessage) Node {
+	var raw rawNode
+	if !d.decode(data, &raw) {
+		return nil
+	}
+
+	tok := token.Token{Position: raw.Position}
+	if raw.Token != nil {
+		tok.Type, tok.Literal = raw.Token.Type, raw.Token.Literal
+	}
+	attributes, children := raw.Attributes, raw.Children
+	// required returns the named child, failing if it is missing
+	required := func(name string) json.RawMessage {
+		data := children[name]
+		if data == nil || string(data) == "null" {
+			d.fail("%s is missing its %s", raw.Kind, name)
+		}
+		return data
+	}
+
+	switch raw.Kind {
+	case "Program":
+		return &Program{Statements: d.statements(children["statements"]), Comments: d.comments(attributes["comments"])}
+	case "ExpressionStatement":
+		return &ExpressionStatement{Token: tok, Expression: d.expression(required("expression"))}
+	case "LetStatement":
+		return &LetStatement{Token: tok, Name: d.identifier(required("name")), Value: d.expression(required("value"))}
+	case "ReturnStatement":
+		return &ReturnStatement{Token: tok, ReturnValue: d.expression(required("returnValue"))}
+	case "ThrowStatement":
+		return &ThrowStatement{Token: tok, Value: d.expression(required("value"))}
+	case "ImportStatement":
+		return &ImportStatement{Token: tok, Path: d.stringLiteral(required("path")), Name: d.identifier(required("name"))}
+	case "ExportStatement":
+		return &ExportStatement{Token: tok, Statement: d.letStatement(required("statement"))}
+	case "BlockStatement":
+		block := &BlockStatement{Token: tok, Statements: d.statements(children["statements"])}
+		d.decode(attributes["rbrace"], &block.Rbrace)
+		return block
+
+	case "Identifier":
+		ident := &Identifier{Token: tok}
+		d.decode(attributes["value"], &ident.Value)
+		return ident
+	case "IntegerLiteral":
+		integer := &IntegerLiteral{Token: tok}
+		d.decode(attributes["value"], &integer.Value)
+		return integer
+	case "BooleanLiteral":
+		boolean := &BooleanLiteral{Token: tok}
+		d.decode(attributes["value"], &boolean.Value)
+		return boolean
+	case "StringLiteral":
+		str := &StringLiteral{Token: tok}
+		d.decode(attributes["value"], &str.Value)
+		return str
+	case "NullLiteral":
+		return &NullLiteral{Token: tok}
+	case "FunctionLiteral":
+		return &FunctionLiteral{
+			Token:      tok,
+			Name:       d.identifier(children["name"]),
+			Parameters: d.identifiers(children["parameters"]),
+			Defaults:   d.defaults(children["defaults"]),
+			Rest:       d.identifier(children["rest"]),
+			Body:       d.block(required("body")),
+		}
+	case "MacroLiteral":
+		return &MacroLiteral{Token: tok, Parameters: d.identifiers(children["parameters"]), Body: d.block(required("body"))}
+	case "SpreadExpression":
+		return &SpreadExpression{Token: tok, Value: d.expression(required("value"))}
+	case "CallExpression":
+		call := &CallExpression{Token: tok, Function: d.expression(required("function")), Arguments: d.expressions(children["arguments"])}
+		d.decode(attributes["rparen"], &call.Rparen)
+		d.decode(attributes["grouped"], &call.Grouped)
+		return call
+	case "ArrayLiteral":
+		array := &ArrayLiteral{Token: tok, Elements: d.expressions(children["elements"])}
+		d.decode(attributes["rbracket"], &array.Rbracket)
+		return array
+	case "HashLiteral":
+		hash := &HashLiteral{Token: tok}
+		d.decode(attributes["rbrace"], &hash.Rbrace)
+		keys, values := d.expressions(children["keys"]), d.expressions(children["values"])
+		if len(keys) != len(values) {
+			d.fail("hash literal has %d keys, but %d values", len(keys), len(values))
+			return nil
+		}
+		if keys != nil {
+			hash.Pairs = []HashPair{}
+		}
+		for index := range keys {
+			hash.Pairs = append(hash.Pairs, HashPair{Key: keys[index], Value: values[index]})
+		}
+		return hash
+	case "IndexExpression":
+		index := &IndexExpression{Token: tok, Left: d.expression(required("left")), Index: d.expression(required("index"))}
+		d.decode(attributes["optional"], &index.Optional)
+		d.decode(attributes["rbracket"], &index.Rbracket)
+		d.decode(attributes["grouped"], &index.Grouped)
+		return index
+	case "PropertyExpression":
+		property := &PropertyExpression{Token: tok, Object: d.expression(required("object")), Property: d.identifier(required("property"))}
+		d.decode(attributes["optional"], &property.Optional)
+		d.decode(attributes["grouped"], &property.Grouped)
+		return property
+	case "PrefixExpression":
+		prefix := &PrefixExpression{Token: tok, Right: d.expression(required("right"))}
+		d.decode(attributes["operator"], &prefix.Operator)
+		return prefix
+	case "InfixExpression":
+		infix := &InfixExpression{Token: tok, Left: d.expression(required("left")), Right: d.expression(required("right"))}
+		d.decode(attributes["operator"], &infix.Operator)
+		return infix
+	case "IfExpression":
+		return &IfExpression{
+			Token:     tok,
+			Condition: d.expression(required("condition")),
+			Then:      d.block(required("then")),
+			Otherwise: d.block(children["otherwise"]),
+		}
+	case "TryExpression":
+		try := &TryExpression{
+			Token:          tok,
+			Block:          d.block(required("block")),
+			CatchParameter: d.identifier(children["catchParameter"]),
+			Catch:          d.block(children["catch"]),
+			Finally:        d.block(children["finally"]),
+		}
+		switch {
+		case d.err != nil:
+			return nil
+		case (try.CatchParameter == nil) != (try.Catch == nil):
+			d.fail("TryExpression needs both a catchParameter and a catch, or neither")
+			return nil
+		case try.Catch == nil && try.Finally == nil:
+			d.fail("TryExpression is missing its catch or finally")
+			return nil
+		}
+		return try
+	}
+
+	d.fail("unknown node kind %q", raw.Kind)
+	return nil
+}
+
+func (d *decoder) statement(data json.RawMessage) Statement {
+	node := d.node(data)
+	if stmt, ok := node.(Statement); ok {
+		return stmt
+	}
+	d.fail("expected statement, got %s", kindOf(node))
+	return nil
+}
+
+func (d *decoder) expression(data json.RawMessage) Expression {
+	node := d.node(data)
+	if exp, ok := node.(Expression); ok || node == nil {
+		return exp
+	}
+	d.fail("expected expression, got %s", kindOf(node))
+	return nil
+}
+
+func (d *decoder) identifier(data json.RawMessage) *Identifier {
+	node := d.node(data)
+	if ident, ok := node.(*Identifier); ok || node == nil {
+		return ident
+	}
+	d.fail("expected Identifier, got %s", kindOf(node))
+	return nil
+}
+
+func (d *decoder) stringLiteral(data json.RawMessage) *StringLiteral {
+	node := d.node(data)
+	if str, ok := node.(*StringLiteral); ok || node == nil {
+		return str
+	}
+	d.fail("expected StringLiteral, got %s", kindOf(node))
+	return nil
+}
+
+func (d *decoder) letStatement(data json.RawMessage) *LetStatement {
+	node := d.node(data)
+	if let, ok := node.(*LetStatement); ok || node == nil {
+		return let
+	}
+	d.fail("expected LetStatement, got %s", kindOf(node))
+	return nil
+}
+
+func (d *decoder) block(data json.RawMessage) *BlockStatement {
+	node := d.node(data)
+	if block, ok := node.(*BlockStatement); ok || node == nil {
+		return block
+	}
+	d.fail("expected BlockStatement, got %s", kindOf(node))
+	return nil
+}
+
+// statements decodes a list of statements, which is nil for null.
+func (d *decoder) statements(data json.RawMessage) []Statement {
+	var list []json.RawMessage
+	if !d.decode(data, &list) || list == nil {
+		return nil
+	}
+	stmts := []Statement{}
+	for _, item := range list {
+		stmts = append(stmts, d.statement(item))
+	}
+	return stmts
+}
+
+// expressions decodes a list of expressions, which is nil for null.
+func (d *decoder) expressions(data json.RawMessage) []Expression {
+	exps := d.defaults(data)
+	for _, exp := range exps {
+		if exp == nil {
+			d.fail("expected expression, got null")
+			return nil
+		}
+	}
+	return exps
+}
+
+// defaults decodes the default values of parameters, which is nil for null.
+// Unlike in other lists of expressions, its elements may be null for parameters without default value.
+func (d *decoder) defaults(data json.RawMessage) []Expression {
+	var list []json.RawMessage
+	if !d.decode(data, &list) || list == nil {
+		return nil
+	}
+	exps := []Expression{}
+	for _, item := range list {
+		exps = append(exps, d.expression(item))
+	}
+	return exps
+}
+
+// identifiers decodes a list of identifiers, which is nil for null.
+func (d *decoder) identifiers(data json.RawMessage) []*Identifier {
+	var list []json.RawMessage
+	if !d.decode(data, &list) || list == nil {
+		return nil
+	}
+	idents := []*Identifier{}
+	for _, item := range list {
+		ident := d.identifier(item)
+		if ident == nil {
+			d.fail("expected Identifier, got null")
+			return nil
+		}
+		idents = append(idents, ident)
+	}
+	return idents
+}
+
+// comments decodes the comments of a program, which are nil for null.
+func (d *decoder) comments(data json.RawMessage) []*Comment {
+	var list []rawNode
+	if !d.decode(data, &list) || list == nil {
+		return nil
+	}
+	comments := []*Comment{}
+	for _, raw := range list {
+		if raw.Kind != "Comment" || raw.Token == nil {
+			d.fail("expected comment, got %s", raw.Kind)
+			return nil
+		}
+		comments = append(comments, &Comment{Token: token.Token{Type: raw.Token.Type, Literal: raw.Token.Literal, Position: raw.Position}})
+	}
+	return comments
+}
+
+// decode unmarshals the data into the value, leaving it unchanged for missing data or null.
+// It reports whether the data was decoded.
+func (d *decoder) decode(data json.RawMessage, value interface{}) bool {
+	if d.err != nil || data == nil || string(data) == "null" {
+		return false
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		d.err = err
+		return false
+	}
+	return true
+}
+
+func (d *decoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+/// helpers
+
+func marshalNode(kind string, position token.Position, tok *token.Token, attributes, children jsonFields) ([]byte, error) {
+	node := jsonNode{Kind: kind, Position: position, Attributes: attributes, Children: children}
+	if tok != nil {
+		node.Token = &jsonToken{Type: tok.Type, Literal: tok.Literal}
+	}
+	return json.Marshal(node)
+}
+
+// kindOf returns the kind of a node in its JSON representation, or "null" for nil.
+func kindOf(node Node) string {
+	if node == nil {
+		return "null"
+	}
+	return reflect.TypeOf(node).Elem().Name()
+}
@@ -0,0 +1,248 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Tests
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, node := range nodeTypes() {
+		kind := reflect.TypeOf(node).Elem().Name()
+		t.Run(kind, func(t *testing.T) {
+			populate(node)
+			populateValues(node)
+
+			data, err := json.Marshal(node)
+			if err != nil {
+				t.Fatalf("could not marshal node: %s", err)
+			}
+
+			var fields struct{ Kind string }
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatalf("could not unmarshal kind: %s", err)
+			}
+			if fields.Kind != kind {
+				t.Errorf("kind is wrong. expected=%q, got=%q", kind, fields.Kind)
+			}
+
+			d := &decoder{}
+			decoded := d.node(data)
+			if d.err != nil {
+				t.Fatalf("could not decode node: %s", d.err)
+			}
+			if !reflect.DeepEqual(decoded, node) {
+				t.Errorf("decoded node differs from the original.\nJSON: %s", data)
+			}
+		})
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let", Position: token.Position{Line: 1, Column: 1}},
+				Name: &Identifier{
+					Token: token.Token{Type: token.IDENTIFIER, Literal: "x", Position: token.Position{Line: 1, Column: 5}},
+					Value: "x",
+				},
+				Value: &PrefixExpression{
+					Token:    token.Token{Type: token.DASH, Literal: "-", Position: token.Position{Line: 1, Column: 9}},
+					Operator: "-",
+					Right: &IntegerLiteral{
+						Token: token.Token{Type: token.INTEGER, Literal: "1", Position: token.Position{Line: 1, Column: 10}},
+						Value: 1,
+					},
+				},
+			},
+		},
+		Comments: []*Comment{
+			{Token: token.Token{Type: token.COMMENT, Literal: "// one", Position: token.Position{Line: 1, Column: 13}}},
+		},
+	}
+	expected := `{
+  "kind": "Program",
+  "position": {"line": 1, "column": 1},
+  "attributes": {
+    "comments": [
+      {
+        "kind": "Comment",
+        "position": {"line": 1, "column": 13},
+        "token": {"type": "COMMENT", "literal": "// one"}
+      }
+    ]
+  },
+  "children": {
+    "statements": [
+      {
+        "kind": "LetStatement",
+        "position": {"line": 1, "column": 1},
+        "token": {"type": "LET", "literal": "let"},
+        "children": {
+          "name": {
+            "kind": "Identifier",
+            "position": {"line": 1, "column": 5},
+            "token": {"type": "IDENTIFIER", "literal": "x"},
+            "attributes": {"value": "x"}
+          },
+          "value": {
+            "kind": "PrefixExpression",
+            "position": {"line": 1, "column": 9},
+            "token": {"type": "-", "literal": "-"},
+            "attributes": {"operator": "-"},
+            "children": {
+              "right": {
+                "kind": "IntegerLiteral",
+                "position": {"line": 1, "column": 10},
+                "token": {"type": "INTEGER", "literal": "1"},
+                "attributes": {"value": 1}
+              }
+            }
+          }
+        }
+      }
+    ]
+  }
+}`
+
+	data, err := json.Marshal(program)
+	if err != nil {
+		t.Fatalf("could not marshal program: %s", err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(expected)); err != nil {
+		t.Fatalf("expected JSON is invalid: %s", err)
+	}
+	if string(data) != compact.String() {
+		t.Errorf("JSON is wrong.\nexpected=%s\ngot=     %s", compact.String(), data)
+	}
+
+	var decoded Program
+	if err := json.Unmarshal([]byte(expected), &decoded); err != nil {
+		t.Fatalf("could not unmarshal program: %s", err)
+	}
+	if !reflect.DeepEqual(&decoded, program) {
+		t.Errorf("decoded program differs from the original. got=%q", decoded.String())
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	// program and expression wrap the JSON of a statement or expression into the JSON of a program
+	program := func(statement string) string {
+		return `{"kind": "Program", "children": {"statements": [` + statement + `]}}`
+	}
+	expression := func(expression string) string {
+		return program(`{"kind": "ExpressionStatement", "children": {"expression": ` + expression + `}}`)
+	}
+	const (
+		null  = `{"kind": "NullLiteral"}`
+		ident = `{"kind": "Identifier", "attributes": {"value": "x"}}`
+		block = `{"kind": "BlockStatement"}`
+	)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[]`, "cannot unmarshal array"},
+		{`null`, "expected node of kind Program, got null"},
+		{`{"kind": "Identifier"}`, "expected node of kind Program, got Identifier"},
+		{`{"kind": "Program", "children": {"statements": [{"kind": "Loop"}]}}`, `unknown node kind "Loop"`},
+		{`{"kind": "Program", "children": {"statements": [{"kind": "NullLiteral"}]}}`, "expected statement, got NullLiteral"},
+		{
+			`{"kind": "Program", "children": {"statements": [{"kind": "ExpressionStatement", "children": {"expression": {"kind": "ThrowStatement", "children": {"value": {"kind": "NullLiteral"}}}}}]}}`,
+			"expected expression, got ThrowStatement",
+		},
+		{
+			`{"kind": "Program", "children": {"statements": [{"kind": "LetStatement", "children": {"name": {"kind": "NullLiteral"}}}]}}`,
+			"expected Identifier, got NullLiteral",
+		},
+		{
+			`{"kind": "Program", "children": {"statements": [{"kind": "ExpressionStatement", "children": {"expression": {"kind": "HashLiteral", "children": {"keys": [{"kind": "NullLiteral"}]}}}}]}}`,
+			"hash literal has 1 keys, but 0 values",
+		},
+		{`{"kind": "Program", "attributes": {"comments": [{"kind": "Identifier"}]}}`, "expected comment, got Identifier"},
+
+		{program(`null`), "expected statement, got null"},
+		{program(`{"kind": "ExpressionStatement"}`), "ExpressionStatement is missing its expression"},
+		{program(`{"kind": "LetStatement", "children": {"value": ` + null + `}}`), "LetStatement is missing its name"},
+		{program(`{"kind": "LetStatement", "children": {"name": ` + ident + `}}`), "LetStatement is missing its value"},
+		{program(`{"kind": "ReturnStatement"}`), "ReturnStatement is missing its returnValue"},
+		{program(`{"kind": "ThrowStatement", "children": {"value": null}}`), "ThrowStatement is missing its value"},
+		{program(`{"kind": "ImportStatement", "children": {"name": ` + ident + `}}`), "ImportStatement is missing its path"},
+		{program(`{"kind": "ImportStatement", "children": {"path": {"kind": "StringLiteral"}}}`), "ImportStatement is missing its name"},
+		{program(`{"kind": "ExportStatement"}`), "ExportStatement is missing its statement"},
+		{program(`{"kind": "BlockStatement", "children": {"statements": [null]}}`), "expected statement, got null"},
+		{expression(`{"kind": "FunctionLiteral"}`), "FunctionLiteral is missing its body"},
+		{expression(`{"kind": "FunctionLiteral", "children": {"parameters": [null], "body": ` + block + `}}`), "expected Identifier, got null"},
+		{expression(`{"kind": "MacroLiteral"}`), "MacroLiteral is missing its body"},
+		{expression(`{"kind": "SpreadExpression"}`), "SpreadExpression is missing its value"},
+		{expression(`{"kind": "CallExpression"}`), "CallExpression is missing its function"},
+		{expression(`{"kind": "CallExpression", "children": {"function": ` + ident + `, "arguments": [null]}}`), "expected expression, got null"},
+		{expression(`{"kind": "ArrayLiteral", "children": {"elements": [null]}}`), "expected expression, got null"},
+		{expression(`{"kind": "HashLiteral", "children": {"keys": [null], "values": [` + null + `]}}`), "expected expression, got null"},
+		{expression(`{"kind": "IndexExpression", "children": {"index": ` + null + `}}`), "IndexExpression is missing its left"},
+		{expression(`{"kind": "IndexExpression", "children": {"left": ` + ident + `}}`), "IndexExpression is missing its index"},
+		{expression(`{"kind": "PropertyExpression", "children": {"property": ` + ident + `}}`), "PropertyExpression is missing its object"},
+		{expression(`{"kind": "PropertyExpression", "children": {"object": ` + ident + `}}`), "PropertyExpression is missing its property"},
+		{expression(`{"kind": "PrefixExpression"}`), "PrefixExpression is missing its right"},
+		{expression(`{"kind": "InfixExpression", "children": {"right": ` + null + `}}`), "InfixExpression is missing its left"},
+		{expression(`{"kind": "InfixExpression", "children": {"left": ` + null + `}}`), "InfixExpression is missing its right"},
+		{expression(`{"kind": "IfExpression", "children": {"then": ` + block + `}}`), "IfExpression is missing its condition"},
+		{expression(`{"kind": "IfExpression", "children": {"condition": ` + null + `}}`), "IfExpression is missing its then"},
+		{expression(`{"kind": "TryExpression", "children": {"finally": ` + block + `}}`), "TryExpression is missing its block"},
+		{expression(`{"kind": "TryExpression", "children": {"block": ` + block + `}}`), "TryExpression is missing its catch or finally"},
+		{
+			expression(`{"kind": "TryExpression", "children": {"block": ` + block + `, "catch": ` + block + `}}`),
+			"TryExpression needs both a catchParameter and a catch, or neither",
+		},
+		{
+			expression(`{"kind": "TryExpression", "children": {"block": ` + block + `, "catchParameter": ` + ident + `, "finally": ` + block + `}}`),
+			"TryExpression needs both a catchParameter and a catch, or neither",
+		},
+	}
+
+	for _, test := range tests {
+		var program Program
+		err := json.Unmarshal([]byte(test.input), &program)
+		if err == nil {
+			t.Errorf("expected error for %s", test.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("wrong error for %s. expected=%q, got=%q", test.input, test.expected, err)
+		}
+	}
+}
+
+/// helpers
+
+// populateValues sets every field of the node, which holds no child nodes, to a non-zero value.
+func populateValues(node Node) {
+	value := reflect.ValueOf(node).Elem()
+	for index := 0; index < value.NumField(); index++ {
+		field := value.Field(index)
+		position := token.Position{Line: 2, Column: index + 1}
+		switch field.Interface().(type) {
+		case token.Token:
+			field.Set(reflect.ValueOf(token.Token{Type: token.ILLEGAL, Literal: "literal", Position: position}))
+		case token.Position:
+			field.Set(reflect.ValueOf(position))
+		case []*Comment:
+			field.Set(reflect.ValueOf([]*Comment{{Token: token.Token{Type: token.COMMENT, Literal: "// comment", Position: position}}}))
+		case string:
+			field.SetString("value")
+		case int64:
+			field.SetInt(42)
+		case bool:
+			field.SetBool(true)
+		}
+	}
+}
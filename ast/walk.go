@@ -0,0 +1,312 @@
+package ast
+
+import "fmt"
+
+/// Functions
+
+// Walk traverses the AST in depth-first order, starting by calling v.Visit(node).
+// If the visitor w returned by it is not nil, Walk is called recursively with w for each of the children of the node,
+// followed by a call of w.Visit(nil).
+//
+// Children are visited in source order. Optional children which are nil are skipped.
+// Comments are no nodes and are not visited.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *ExpressionStatement:
+		Walk(v, n.Expression)
+	case *LetStatement:
+		Walk(v, n.Name)
+		Walk(v, n.Value)
+	case *ReturnStatement:
+		Walk(v, n.ReturnValue)
+	case *ThrowStatement:
+		Walk(v, n.Value)
+	case *ImportStatement:
+		Walk(v, n.Path)
+		Walk(v, n.Name)
+	case *ExportStatement:
+		Walk(v, n.Statement)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+
+	case *Identifier, *IntegerLiteral, *BooleanLiteral, *StringLiteral, *NullLiteral:
+		// * leaves
+	case *FunctionLiteral:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		for index, param := range n.Parameters {
+			Walk(v, param)
+			if def := n.Default(index); def != nil {
+				Walk(v, def)
+			}
+		}
+		if n.Rest != nil {
+			Walk(v, n.Rest)
+		}
+		Walk(v, n.Body)
+	case *MacroLiteral:
+		for _, param := range n.Parameters {
+			Walk(v, param)
+		}
+		Walk(v, n.Body)
+	case *SpreadExpression:
+		Walk(v, n.Value)
+	case *CallExpression:
+		Walk(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			Walk(v, pair.Key)
+			Walk(v, pair.Value)
+		}
+	case *IndexExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Index)
+	case *PropertyExpression:
+		Walk(v, n.Object)
+		Walk(v, n.Property)
+	case *PrefixExpression:
+		Walk(v, n.Right)
+	case *InfixExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *IfExpression:
+		Walk(v, n.Condition)
+		Walk(v, n.Then)
+		if n.Otherwise != nil {
+			Walk(v, n.Otherwise)
+		}
+	case *TryExpression:
+		Walk(v, n.Block)
+		if n.CatchParameter != nil {
+			Walk(v, n.CatchParameter)
+		}
+		if n.Catch != nil {
+			Walk(v, n.Catch)
+		}
+		if n.Finally != nil {
+			Walk(v, n.Finally)
+		}
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, stmts []Statement) {
+	for _, stmt := range stmts {
+		Walk(v, stmt)
+	}
+}
+
+func walkExpressions(v Visitor, exps []Expression) {
+	for _, exp := range exps {
+		Walk(v, exp)
+	}
+}
+
+// Inspect traverses the AST in depth-first order, starting by calling f(node).
+// If f returns true, Inspect is called recursively for each of the children of the node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite traverses the AST in depth-first order and replaces each node by the result of calling f with it.
+// f is called after the children of a node have been rewritten, so it receives the node with the rewritten children.
+// The root node is replaced by returning the result of f for it.
+//
+// The nodes are modified in place. Statements for which f returns nil are removed from their list,
+// other nodes have to be replaced by a node that fits their field, e.g. an Expression for an expression,
+// an *Identifier for a parameter or a *BlockStatement for the body of a function. Otherwise Rewrite panics.
+func Rewrite(node Node, f func(Node) Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		n.Statements = rewriteStatements(n.Statements, f)
+	case *ExpressionStatement:
+		n.Expression = rewriteExpression(n.Expression, f)
+	case *LetStatement:
+		n.Name = rewriteIdentifier(n.Name, f)
+		n.Value = rewriteExpression(n.Value, f)
+	case *ReturnStatement:
+		n.ReturnValue = rewriteExpression(n.ReturnValue, f)
+	case *ThrowStatement:
+		n.Value = rewriteExpression(n.Value, f)
+	case *ImportStatement:
+		n.Path = rewriteStringLiteral(n.Path, f)
+		n.Name = rewriteIdentifier(n.Name, f)
+	case *ExportStatement:
+		n.Statement = rewriteLetStatement(n.Statement, f)
+	case *BlockStatement:
+		n.Statements = rewriteStatements(n.Statements, f)
+
+	case *Identifier, *IntegerLiteral, *BooleanLiteral, *StringLiteral, *NullLiteral:
+		// * leaves
+	case *FunctionLiteral:
+		n.Name = rewriteIdentifier(n.Name, f)
+		for index := range n.Parameters {
+			n.Parameters[index] = rewriteIdentifier(n.Parameters[index], f)
+			if index < len(n.Defaults) {
+				n.Defaults[index] = rewriteExpression(n.Defaults[index], f)
+			}
+		}
+		n.Rest = rewriteIdentifier(n.Rest, f)
+		n.Body = rewriteBlock(n.Body, f)
+	case *MacroLiteral:
+		for index := range n.Parameters {
+			n.Parameters[index] = rewriteIdentifier(n.Parameters[index], f)
+		}
+		n.Body = rewriteBlock(n.Body, f)
+	case *SpreadExpression:
+		n.Value = rewriteExpression(n.Value, f)
+	case *CallExpression:
+		n.Function = rewriteExpression(n.Function, f)
+		rewriteExpressions(n.Arguments, f)
+	case *ArrayLiteral:
+		rewriteExpressions(n.Elements, f)
+	case *HashLiteral:
+		for index, pair := range n.Pairs {
+			n.Pairs[index] = HashPair{Key: rewriteExpression(pair.Key, f), Value: rewriteExpression(pair.Value, f)}
+		}
+	case *IndexExpression:
+		n.Left = rewriteExpression(n.Left, f)
+		n.Index = rewriteExpression(n.Index, f)
+	case *PropertyExpression:
+		n.Object = rewriteExpression(n.Object, f)
+		n.Property = rewriteIdentifier(n.Property, f)
+	case *PrefixExpression:
+		n.Right = rewriteExpression(n.Right, f)
+	case *InfixExpression:
+		n.Left = rewriteExpression(n.Left, f)
+		n.Right = rewriteExpression(n.Right, f)
+	case *IfExpression:
+		n.Condition = rewriteExpression(n.Condition, f)
+		n.Then = rewriteBlock(n.Then, f)
+		n.Otherwise = rewriteBlock(n.Otherwise, f)
+	case *TryExpression:
+		n.Block = rewriteBlock(n.Block, f)
+		n.CatchParameter = rewriteIdentifier(n.CatchParameter, f)
+		n.Catch = rewriteBlock(n.Catch, f)
+		n.Finally = rewriteBlock(n.Finally, f)
+
+	default:
+		panic(fmt.Sprintf("ast.Rewrite: unexpected node type %T", n))
+	}
+
+	return f(node)
+}
+
+func rewriteStatements(stmts []Statement, f func(Node) Node) []Statement {
+	rewritten := stmts[:0]
+	for _, stmt := range stmts {
+		result := Rewrite(stmt, f)
+		if result == nil {
+			continue
+		}
+		replacement, ok := result.(Statement)
+		if !ok {
+			panic(fmt.Sprintf("ast.Rewrite: cannot replace statement %T by %T", stmt, result))
+		}
+		rewritten = append(rewritten, replacement)
+	}
+	return rewritten
+}
+
+func rewriteExpressions(exps []Expression, f func(Node) Node) {
+	for index, exp := range exps {
+		exps[index] = rewriteExpression(exp, f)
+	}
+}
+
+// rewriteExpression rewrites an expression, which may be nil, like a missing default value.
+func rewriteExpression(exp Expression, f func(Node) Node) Expression {
+	if exp == nil {
+		return nil
+	}
+	result := Rewrite(exp, f)
+	replacement, ok := result.(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace expression %T by %T", exp, result))
+	}
+	return replacement
+}
+
+// rewriteIdentifier rewrites an identifier, which may be nil, like the name of an anonymous function.
+func rewriteIdentifier(ident *Identifier, f func(Node) Node) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	result := Rewrite(ident, f)
+	replacement, ok := result.(*Identifier)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace identifier by %T", result))
+	}
+	return replacement
+}
+
+// rewriteStringLiteral rewrites a string literal, like the path of an import statement.
+func rewriteStringLiteral(str *StringLiteral, f func(Node) Node) *StringLiteral {
+	if str == nil {
+		return nil
+	}
+	result := Rewrite(str, f)
+	replacement, ok := result.(*StringLiteral)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace string literal by %T", result))
+	}
+	return replacement
+}
+
+// rewriteLetStatement rewrites a let statement, like the one of an export statement.
+func rewriteLetStatement(let *LetStatement, f func(Node) Node) *LetStatement {
+	if let == nil {
+		return nil
+	}
+	result := Rewrite(let, f)
+	replacement, ok := result.(*LetStatement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace let statement by %T", result))
+	}
+	return replacement
+}
+
+// rewriteBlock rewrites a block, which may be nil, like a missing else-branch.
+func rewriteBlock(block *BlockStatement, f func(Node) Node) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	result := Rewrite(block, f)
+	replacement, ok := result.(*BlockStatement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace block by %T", result))
+	}
+	return replacement
+}
+
+/// Types
+
+// A Visitor's Visit method is called by Walk for each node of the AST.
+// If the returned visitor w is not nil, the children of the node are visited with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// inspector adapts a function to the Visitor interface for Inspect.
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
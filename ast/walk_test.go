@@ -0,0 +1,347 @@
+package ast
+
+import (
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Constants / Variables
+
+// astFile contains the node types, which have to be covered by the traversals
+const astFile = "ast.go"
+
+/// Tests
+
+// TestNodeTypes checks that nodeTypes contains every node type declared in astFile, so that the other tests cover all of them.
+func TestNodeTypes(t *testing.T) {
+	fset := gotoken.NewFileSet()
+	file, err := goparser.ParseFile(fset, astFile, nil, 0)
+	if err != nil {
+		t.Fatalf("could not parse %s: %s", astFile, err)
+	}
+
+	declared := []string{}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || fn.Name.Name != "TokenLiteral" {
+			continue
+		}
+		if star, ok := fn.Recv.List[0].Type.(*ast.StarExpr); ok {
+			declared = append(declared, star.X.(*ast.Ident).Name)
+		}
+	}
+
+	covered := []string{}
+	for _, node := range nodeTypes() {
+		covered = append(covered, reflect.TypeOf(node).Elem().Name())
+	}
+
+	sort.Strings(declared)
+	sort.Strings(covered)
+	if strings.Join(declared, ", ") != strings.Join(covered, ", ") {
+		t.Errorf("nodeTypes does not match the node types in %s.\ndeclared: %v\ncovered:  %v", astFile, declared, covered)
+	}
+}
+
+func TestWalkVisitsAllChildren(t *testing.T) {
+	for _, node := range nodeTypes() {
+		t.Run(reflect.TypeOf(node).Elem().Name(), func(t *testing.T) {
+			expected := populate(node)
+
+			visited := []Node{}
+			Inspect(node, func(n Node) bool {
+				if n == node {
+					return true
+				}
+				if n != nil {
+					visited = append(visited, n)
+				}
+				return false
+			})
+
+			if len(visited) != len(expected) {
+				t.Fatalf("wrong number of children visited. expected=%d, got=%d", len(expected), len(visited))
+			}
+			for _, child := range expected {
+				if !containsNode(visited, child) {
+					t.Errorf("child %T was not visited", child)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteReplacesAllChildren(t *testing.T) {
+	for _, node := range nodeTypes() {
+		t.Run(reflect.TypeOf(node).Elem().Name(), func(t *testing.T) {
+			originals := populate(node)
+
+			replacements := []Node{}
+			result := Rewrite(node, func(n Node) Node {
+				if !containsNode(originals, n) {
+					return n
+				}
+				replacement := reflect.New(reflect.TypeOf(n).Elem()).Interface().(Node)
+				replacements = append(replacements, replacement)
+				return replacement
+			})
+
+			if result != node {
+				t.Errorf("Rewrite did not return the root node. got=%T", result)
+			}
+			children := children(node)
+			if len(children) != len(originals) {
+				t.Fatalf("wrong number of children after rewriting. expected=%d, got=%d", len(originals), len(children))
+			}
+			for _, child := range children {
+				if !containsNode(replacements, child) {
+					t.Errorf("child %T was not replaced", child)
+				}
+			}
+		})
+	}
+}
+
+func TestInspectOrder(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&LetStatement{
+			Name: ident("a"),
+			Value: &FunctionLiteral{
+				Parameters: []*Identifier{ident("b"), ident("c")},
+				Defaults:   []Expression{nil, ident("d")},
+				Rest:       ident("e"),
+				Body: &BlockStatement{Statements: []Statement{
+					&ExpressionStatement{Expression: &InfixExpression{Left: ident("f"), Operator: "+", Right: ident("g")}},
+				}},
+			},
+		},
+		&ExpressionStatement{Expression: &CallExpression{Function: ident("h"), Arguments: []Expression{ident("i")}}},
+	}}
+
+	names := []string{}
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			names = append(names, ident.Value)
+		}
+		return true
+	})
+
+	if strings.Join(names, " ") != "a b c d e f g h i" {
+		t.Errorf("identifiers were not visited in source order. got=%v", names)
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	exp := &CallExpression{Function: ident("f"), Arguments: []Expression{
+		&CallExpression{Function: ident("g"), Arguments: []Expression{ident("x")}},
+	}}
+
+	visited := 0
+	Inspect(exp, func(node Node) bool {
+		if node == nil {
+			return false
+		}
+		visited++
+		_, isCall := node.(*CallExpression)
+		return node == exp || !isCall
+	})
+
+	// * the outer call, f and the inner call
+	if visited != 3 {
+		t.Errorf("wrong number of nodes visited. expected=3, got=%d", visited)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let"},
+			Name:  ident("a"),
+			Value: &InfixExpression{Left: integer(1), Operator: "+", Right: integer(2)},
+		},
+		&ExpressionStatement{Expression: ident("a")},
+		&ExpressionStatement{Expression: &NullLiteral{}},
+	}}
+
+	// * fold additions of integers, rename identifiers and remove null statements
+	Rewrite(program, func(node Node) Node {
+		switch node := node.(type) {
+		case *InfixExpression:
+			left, ok := node.Left.(*IntegerLiteral)
+			right, ok2 := node.Right.(*IntegerLiteral)
+			if ok && ok2 {
+				return integer(left.Value + right.Value)
+			}
+		case *Identifier:
+			return ident(node.Value + "_")
+		case *ExpressionStatement:
+			if _, ok := node.Expression.(*NullLiteral); ok {
+				return nil
+			}
+		}
+		return node
+	})
+
+	if program.String() != "let a_ = 3;a_;" {
+		t.Errorf("program was not rewritten correctly. got=%q", program.String())
+	}
+}
+
+func TestRewritePanicsForMismatchedReplacement(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Rewrite did not panic")
+		}
+	}()
+
+	program := &Program{Statements: []Statement{&LetStatement{Name: ident("a"), Value: integer(1)}}}
+	Rewrite(program, func(node Node) Node {
+		if _, ok := node.(*Identifier); ok {
+			return integer(2)
+		}
+		return node
+	})
+}
+
+/// helpers
+
+// nodeTypes returns a zero value of every node type.
+func nodeTypes() []Node {
+	return []Node{
+		&Program{},
+		&ExpressionStatement{},
+		&LetStatement{},
+		&ReturnStatement{},
+		&ThrowStatement{},
+		&ImportStatement{},
+		&ExportStatement{},
+		&BlockStatement{},
+		&Identifier{},
+		&IntegerLiteral{},
+		&BooleanLiteral{},
+		&StringLiteral{},
+		&NullLiteral{},
+		&FunctionLiteral{},
+		&MacroLiteral{},
+		&SpreadExpression{},
+		&CallExpression{},
+		&ArrayLiteral{},
+		&HashLiteral{},
+		&IndexExpression{},
+		&PropertyExpression{},
+		&PrefixExpression{},
+		&InfixExpression{},
+		&IfExpression{},
+		&TryExpression{},
+	}
+}
+
+var (
+	expressionType = reflect.TypeOf((*Expression)(nil)).Elem()
+	statementType  = reflect.TypeOf((*Statement)(nil)).Elem()
+	identifierType = reflect.TypeOf(&Identifier{})
+	blockType      = reflect.TypeOf(&BlockStatement{})
+	stringType     = reflect.TypeOf(&StringLiteral{})
+	letType        = reflect.TypeOf(&LetStatement{})
+	hashPairsType  = reflect.TypeOf([]HashPair{})
+)
+
+// populate sets every field of the node holding child nodes to new nodes, and returns them.
+// Lists are populated with two children.
+func populate(node Node) []Node {
+	children := []Node{}
+	newChild := func(t reflect.Type) reflect.Value {
+		var child Node
+		switch t {
+		case expressionType, identifierType:
+			child = ident("child")
+		case statementType:
+			child = &ExpressionStatement{Expression: ident("child")}
+		case blockType:
+			child = &BlockStatement{}
+		case stringType:
+			child = &StringLiteral{Value: "child"}
+		case letType:
+			child = &LetStatement{Name: ident("child"), Value: ident("child")}
+		default:
+			return reflect.Value{}
+		}
+		children = append(children, child)
+		return reflect.ValueOf(child)
+	}
+
+	value := reflect.ValueOf(node).Elem()
+	for index := 0; index < value.NumField(); index++ {
+		field := value.Field(index)
+		switch {
+		case field.Type() == hashPairsType:
+			key, value := ident("key"), ident("value")
+			children = append(children, key, value)
+			field.Set(reflect.ValueOf([]HashPair{{Key: key, Value: value}}))
+		case field.Kind() == reflect.Slice:
+			slice := reflect.MakeSlice(field.Type(), 0, 2)
+			for i := 0; i < 2; i++ {
+				if child := newChild(field.Type().Elem()); child.IsValid() {
+					slice = reflect.Append(slice, child)
+				}
+			}
+			field.Set(slice)
+		default:
+			if child := newChild(field.Type()); child.IsValid() {
+				field.Set(child)
+			}
+		}
+	}
+	return children
+}
+
+// children returns the nodes held by the fields of the node.
+func children(node Node) []Node {
+	children := []Node{}
+	value := reflect.ValueOf(node).Elem()
+	for index := 0; index < value.NumField(); index++ {
+		field := value.Field(index)
+		switch {
+		case field.Type() == hashPairsType:
+			for _, pair := range field.Interface().([]HashPair) {
+				children = append(children, pair.Key, pair.Value)
+			}
+		case field.Kind() == reflect.Slice:
+			for i := 0; i < field.Len(); i++ {
+				if child, ok := field.Index(i).Interface().(Node); ok {
+					children = append(children, child)
+				}
+			}
+		default:
+			if child, ok := field.Interface().(Node); ok {
+				children = append(children, child)
+			}
+		}
+	}
+	return children
+}
+
+func containsNode(nodes []Node, node Node) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENTIFIER, Literal: name}, Value: name}
+}
+
+func integer(value int64) *IntegerLiteral {
+	return &IntegerLiteral{Token: token.Token{Type: token.INTEGER, Literal: fmt.Sprint(value)}, Value: value}
+}
@@ -0,0 +1,30 @@
+let map = fn(arr, f) {
+	let iter = fn(i, acc) {
+		if (i == len(arr)) {
+			return acc;
+		}
+		iter(i + 1, [...acc, f(arr[i])])
+	};
+	iter(0, [])
+};
+
+let reduce = fn(arr, initial, f) {
+	let iter = fn(i, acc) {
+		if (i == len(arr)) {
+			return acc;
+		}
+		iter(i + 1, f(acc, arr[i]))
+	};
+	iter(0, initial)
+};
+
+let range = fn(n, acc) {
+	if (len(acc) == n) {
+		return acc;
+	}
+	range(n, [...acc, len(acc)])
+};
+
+let numbers = range(200, []);
+let squares = map(numbers, fn(x) { x * x });
+reduce(squares, 0, fn(sum, x) { sum + x })
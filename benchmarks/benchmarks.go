@@ -0,0 +1,46 @@
+package benchmarks
+
+import (
+	"embed"
+	"sort"
+	"strings"
+)
+
+/// Constants / Variables
+
+// files contains representative Monkey programs, which are run by the benchmarks of the lexer, the parser and the engines,
+// as well as the bench command
+//
+//go:embed *.mk
+var files embed.FS
+
+/// Functions
+
+// Programs returns the benchmark programs sorted by name.
+func Programs() []Program {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		panic(err)
+	}
+
+	programs := []Program{}
+	for _, entry := range entries {
+		source, err := files.ReadFile(entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		programs = append(programs, Program{Name: strings.TrimSuffix(entry.Name(), ".mk"), Source: string(source)})
+	}
+
+	sort.Slice(programs, func(i, j int) bool { return programs[i].Name < programs[j].Name })
+	return programs
+}
+
+/// Types
+
+// Program is a benchmark program.
+type Program struct {
+	// Name is the file name of the program without extension
+	Name   string
+	Source string
+}
@@ -0,0 +1,24 @@
+let adder = fn(x) { fn(y) { x + y } };
+let compose = fn(f, g) { fn(x) { g(f(x)) } };
+
+let counter = fn(start) {
+	let next = fn(n) { counter(n + 1) };
+	{"value": start, "next": fn() { next(start) }}
+};
+
+let sum = fn(n, acc) {
+	if (n == 0) {
+		return acc;
+	}
+	let step = compose(adder(n), adder(1));
+	sum(n - 1, step(acc))
+};
+
+let count = fn(c, n) {
+	if (n == 0) {
+		return c["value"];
+	}
+	count(c["next"](), n - 1)
+};
+
+sum(1000, 0) + count(counter(0), 1000)
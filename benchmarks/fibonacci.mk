@@ -0,0 +1,9 @@
+let fib = fn(n) {
+	if (n < 2) {
+		n
+	} else {
+		fib(n - 1) + fib(n - 2)
+	}
+};
+
+fib(15)
@@ -0,0 +1,15 @@
+let repeat = fn(s, n, acc) {
+	if (n == 0) {
+		return acc;
+	}
+	repeat(s, n - 1, acc + s)
+};
+
+let lines = fn(n, acc) {
+	if (n == 0) {
+		return acc;
+	}
+	lines(n - 1, acc + repeat("ab", 10, "") + "\n")
+};
+
+len(lines(300, ""))
@@ -0,0 +1,297 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Constants / Variables
+
+// Opcodes.
+// Compiled programs are serialized with their opcodes, so any change to them requires a new compiler.FormatVersion.
+const (
+	// OpConstant pushes the constant at the index of its operand
+	OpConstant Opcode = iota
+	// OpPop discards the topmost element of the stack
+	OpPop
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	// Infix operators pop the right and then the left operand and push the result
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpGreaterThan
+
+	// Prefix operators pop the operand and push the result
+	OpMinus
+	OpBang
+
+	// OpJump continues at the offset of its operand
+	OpJump
+	// OpJumpNotTruthy pops the condition and continues at the offset of its operand, if the condition is not truthy
+	OpJumpNotTruthy
+	// OpJumpNull continues at the offset of its operand, if the topmost element is null, keeping it on the stack
+	OpJumpNull
+	// OpJumpNotNull continues at the offset of its operand keeping the topmost element, unless it is null, in which case it is popped
+	OpJumpNotNull
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	// OpGetFree pushes the local binding with the index of its second operand
+	// of the scope that is as many functions outside of the current one, as its first operand says
+	OpGetFree
+	OpGetBuiltin
+
+	// OpArray creates an array from as many elements on the stack, as its operand says
+	OpArray
+	// OpAppend pops an element and appends it to the array below it
+	OpAppend
+	// OpExtend pops an array and appends its elements to the array below it
+	OpExtend
+	// OpHash creates a hash from as many keys and values on the stack, as its operand says
+	OpHash
+	OpIndex
+	// OpProperty accesses the property named by the string constant at the index of its operand
+	OpProperty
+
+	// OpClosure creates a closure of the compiled function constant at the index of its operand
+	OpClosure
+	// OpCall calls the function below as many arguments on the stack, as its operand says
+	OpCall
+	// OpCallArray calls the function below an array containing the arguments
+	OpCallArray
+	// OpTailCall calls a function like OpCall, replacing the current frame
+	OpTailCall
+	// OpTailCallArray calls a function like OpCallArray, replacing the current frame
+	OpTailCallArray
+	// OpJumpIfBound continues at the offset of its second operand, if the local binding of its first operand is set.
+	// It is used to skip the evaluation of default parameter values.
+	OpJumpIfBound
+	OpReturnValue
+	// OpReturn returns from the current function without a value
+	OpReturn
+
+	// OpThrow pops a value and raises the error created from it
+	OpThrow
+	// OpTry installs an error handler with the offsets of the catch- and finally-branch, zero if there is none
+	OpTry
+	// OpEndTry completes the try- or catch-branch with the topmost element as value
+	OpEndTry
+	// OpEndFinally removes the error handler and resumes the completion of the try- or catch-branch
+	OpEndFinally
+
+	// OpImport pushes the module cached in the global with the index of its first operand.
+	// If the global is not bound yet, the module function, which is the constant with the index of its second operand,
+	// is called without arguments instead, which evaluates the module and caches it.
+	OpImport
+	// OpModule creates a module with the path of the constant with the index of its first operand,
+	// which exports as many names and values on the stack, as its second operand says
+	OpModule
+)
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpAdd:         {"OpAdd", []int{}},
+	OpSub:         {"OpSub", []int{}},
+	OpMul:         {"OpMul", []int{}},
+	OpDiv:         {"OpDiv", []int{}},
+	OpEqual:       {"OpEqual", []int{}},
+	OpNotEqual:    {"OpNotEqual", []int{}},
+	OpLessThan:    {"OpLessThan", []int{}},
+	OpGreaterThan: {"OpGreaterThan", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJumpNull:      {"OpJumpNull", []int{2}},
+	OpJumpNotNull:   {"OpJumpNotNull", []int{2}},
+
+	OpGetGlobal:  {"OpGetGlobal", []int{2}},
+	OpSetGlobal:  {"OpSetGlobal", []int{2}},
+	OpGetLocal:   {"OpGetLocal", []int{2}},
+	OpSetLocal:   {"OpSetLocal", []int{2}},
+	OpGetFree:    {"OpGetFree", []int{1, 2}},
+	OpGetBuiltin: {"OpGetBuiltin", []int{1}},
+
+	OpArray:    {"OpArray", []int{2}},
+	OpAppend:   {"OpAppend", []int{}},
+	OpExtend:   {"OpExtend", []int{}},
+	OpHash:     {"OpHash", []int{2}},
+	OpIndex:    {"OpIndex", []int{}},
+	OpProperty: {"OpProperty", []int{2}},
+
+	OpClosure:       {"OpClosure", []int{2}},
+	OpCall:          {"OpCall", []int{1}},
+	OpCallArray:     {"OpCallArray", []int{}},
+	OpTailCall:      {"OpTailCall", []int{1}},
+	OpTailCallArray: {"OpTailCallArray", []int{}},
+	OpJumpIfBound:   {"OpJumpIfBound", []int{2, 2}},
+	OpReturnValue:   {"OpReturnValue", []int{}},
+	OpReturn:        {"OpReturn", []int{}},
+
+	OpThrow:      {"OpThrow", []int{}},
+	OpTry:        {"OpTry", []int{2, 2}},
+	OpEndTry:     {"OpEndTry", []int{}},
+	OpEndFinally: {"OpEndFinally", []int{}},
+
+	OpImport: {"OpImport", []int{2, 2}},
+	OpModule: {"OpModule", []int{2, 2}},
+}
+
+/// Functions
+
+// Lookup returns the definition of the given opcode.
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes an instruction of the given opcode and operands.
+// It returns an empty slice, if the opcode is undefined.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction of the given definition.
+// It returns the operands and the number of bytes read.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 { return binary.BigEndian.Uint16(ins) }
+func ReadUint8(ins Instructions) uint8   { return uint8(ins[0]) }
+
+/// Types
+
+type Opcode byte
+
+// Definition describes the name and the operands of an opcode.
+type Definition struct {
+	Name string
+	// OperandWidths contains the number of bytes of each operand
+	OperandWidths []int
+}
+
+// Instructions is a sequence of encoded instructions.
+type Instructions []byte
+
+// String returns a disassembly of the instructions with one instruction per line, prefixed by its offset.
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+// LineEntry marks the position in the source that the instructions starting at Offset were compiled from.
+type LineEntry struct {
+	Offset   int
+	Position token.Position
+}
+
+// LineTable maps instruction offsets to source positions.
+// The entries are sorted by offset and each one applies up to the offset of the next.
+type LineTable []LineEntry
+
+// PositionAt returns the source position of the instruction at the given offset,
+// or an invalid position if the table does not cover the offset.
+func (lt LineTable) PositionAt(offset int) token.Position {
+	index := sort.Search(len(lt), func(i int) bool { return lt[i].Offset > offset })
+	if index == 0 {
+		return token.Position{}
+	}
+	return lt[index-1].Position
+}
@@ -0,0 +1,117 @@
+package code
+
+import (
+	"testing"
+
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Tests
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetFree, []int{2, 65535}, []byte{byte(OpGetFree), 2, 255, 255}},
+		{OpCall, []int{255}, []byte{byte(OpCall), 255}},
+		{OpTry, []int{1, 258}, []byte{byte(OpTry), 0, 1, 1, 2}},
+	}
+
+	for _, test := range tests {
+		instruction := Make(test.op, test.operands...)
+		if string(instruction) != string(test.expected) {
+			t.Errorf("instruction is wrong. expected=%v, got=%v", test.expected, instruction)
+		}
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetBuiltin, []int{7}, 1},
+		{OpClosure, []int{65535}, 2},
+		{OpJumpIfBound, []int{1, 300}, 4},
+	}
+
+	for _, test := range tests {
+		instruction := Make(test.op, test.operands...)
+
+		def, err := Lookup(byte(test.op))
+		if err != nil {
+			t.Fatalf("definition not found: %s", err)
+		}
+
+		operands, read := ReadOperands(def, instruction[1:])
+		if read != test.bytesRead {
+			t.Errorf("read wrong number of bytes. expected=%d, got=%d", test.bytesRead, read)
+		}
+		for i, expected := range test.operands {
+			if operands[i] != expected {
+				t.Errorf("operand %d is wrong. expected=%d, got=%d", i, expected, operands[i])
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535),
+		Make(OpCall, 2),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0004 OpConstant 2
+0007 OpConstant 65535
+0010 OpClosure 65535
+0013 OpCall 2
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions are wrongly formatted.\nexpected=%q\ngot=%q", expected, concatted.String())
+	}
+}
+
+func TestLineTablePositionAt(t *testing.T) {
+	lines := LineTable{
+		{Offset: 0, Position: token.Position{Line: 1, Column: 1}},
+		{Offset: 4, Position: token.Position{Line: 1, Column: 9}},
+		{Offset: 10, Position: token.Position{Line: 3, Column: 2}},
+	}
+
+	tests := []struct {
+		offset   int
+		expected token.Position
+	}{
+		{0, token.Position{Line: 1, Column: 1}},
+		{3, token.Position{Line: 1, Column: 1}},
+		{4, token.Position{Line: 1, Column: 9}},
+		{9, token.Position{Line: 1, Column: 9}},
+		{10, token.Position{Line: 3, Column: 2}},
+		{100, token.Position{Line: 3, Column: 2}},
+		{-1, token.Position{}},
+	}
+
+	for _, test := range tests {
+		if actual := lines.PositionAt(test.offset); actual != test.expected {
+			t.Errorf("position at %d is wrong. expected=%v, got=%v", test.offset, test.expected, actual)
+		}
+	}
+}
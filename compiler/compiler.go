@@ -0,0 +1,822 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/code"
+	"github.com/smalldevshima/go-monkey/evaluator"
+	"github.com/smalldevshima/go-monkey/module"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Constants / Variables
+
+// placeholderOperand is the operand of jump instructions emitted before their target is known
+const placeholderOperand = 9999
+
+// Limits of the operands of instructions
+const (
+	maxUint8  = 1<<8 - 1
+	maxUint16 = 1<<16 - 1
+)
+
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLessThan,
+	">":  code.OpGreaterThan,
+}
+
+var prefixOpcodes = map[string]code.Opcode{
+	"-": code.OpMinus,
+	"!": code.OpBang,
+}
+
+/// Functions
+
+// NewGlobalSymbolTable creates the table of global symbols with all builtins defined.
+func NewGlobalSymbolTable() *SymbolTable {
+	symbolTable := NewSymbolTable()
+	for index, builtin := range evaluator.Builtins() {
+		symbolTable.DefineBuiltin(index, builtin.Name)
+	}
+	return symbolTable
+}
+
+func New() *Compiler {
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewGlobalSymbolTable(),
+		scopes:      []CompilationScope{{}},
+	}
+}
+
+// NewWithState creates a compiler that continues with the global symbols and constants of previously compiled programs.
+// The symbol table has to be created by NewGlobalSymbolTable.
+func NewWithState(symbolTable *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = symbolTable
+	compiler.constants = constants
+	return compiler
+}
+
+/// Types
+
+// Compiler compiles an AST into bytecode for the vm package.
+// The compiled program behaves identically to the evaluation of the AST by the evaluator package.
+type Compiler struct {
+	// Loader loads the modules imported by compiled programs, nil means a module.Loader without search paths.
+	Loader *module.Loader
+	// Path is the source file of the compiled program, which relative import paths are resolved against.
+	// It is empty for programs that are not read from a file.
+	Path string
+
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	// one scope per function literal being compiled, the outermost one is the program
+	scopes []CompilationScope
+	// the position of the node currently being compiled
+	position token.Position
+	// the function constants of the compiled modules by path
+	modules map[string]int
+	// the paths of the modules being compiled, starting with the program, to detect import cycles
+	importing []string
+}
+
+// CompilationScope holds the instructions of a function literal or the program.
+type CompilationScope struct {
+	instructions code.Instructions
+	lines        code.LineTable
+}
+
+// Bytecode is a compiled program.
+type Bytecode struct {
+	Instructions code.Instructions
+	Lines        code.LineTable
+	Constants    []object.Object
+	// the names of the globals by index, used in error messages
+	GlobalNames []string
+}
+
+// Compile compiles the node and all of its children.
+// A program is compiled so that the VM returns the value of its last statement, just like the evaluator.
+func (c *Compiler) Compile(node ast.Node) error {
+	defer c.at(node)()
+
+	switch node := node.(type) {
+	// * Statements:
+	case *ast.Program:
+		return c.compileProgram(node.Statements)
+	case *ast.BlockStatement:
+		return c.compileBlock(node.Statements, false, false)
+	case *ast.ExpressionStatement:
+		if err := c.compileExpressionStatement(node, false, false); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.ReturnStatement:
+		return c.compileReturnStatement(node, false)
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	case *ast.LetStatement:
+		// * anonymous function literals are named after the identifier they are bound to
+		if fl, ok := node.Value.(*ast.FunctionLiteral); ok {
+			if err := c.compileFunctionLiteral(fl, node.Name.Value); err != nil {
+				return err
+			}
+		} else if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emitSet(c.symbolTable.Define(node.Name.Value))
+	case *ast.ImportStatement:
+		return c.compileImportStatement(node)
+	case *ast.ExportStatement:
+		return c.Compile(node.Statement)
+
+	// * Literal expressions:
+	case *ast.BooleanLiteral:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.IntegerLiteral:
+		return c.emitConstant(&object.Integer{Value: node.Value})
+	case *ast.StringLiteral:
+		return c.emitConstant(&object.String{Value: node.Value})
+	case *ast.NullLiteral:
+		c.emit(code.OpNull)
+	case *ast.ArrayLiteral:
+		if hasSpread(node.Elements) || len(node.Elements) > maxUint16 {
+			return c.compileArrayBuilder(node.Elements)
+		}
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		if 2*len(node.Pairs) > maxUint16 {
+			return fmt.Errorf("hash literal at %s has too many pairs: %d", node.Pos(), len(node.Pairs))
+		}
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, 2*len(node.Pairs))
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node, "")
+
+	// * Operator expressions:
+	case *ast.PrefixExpression:
+		op, ok := prefixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s at %s", node.Operator, node.Pos())
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *ast.InfixExpression:
+		if node.Operator == "??" {
+			return c.compileNullishExpression(node)
+		}
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s at %s", node.Operator, node.Pos())
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+
+	// * Control flow expressions:
+	case *ast.IfExpression:
+		return c.compileIfExpression(node, false, false)
+	case *ast.TryExpression:
+		return c.compileTryExpression(node)
+
+	// * Index and property access:
+	case *ast.IndexExpression:
+		return c.compileChain(node, false)
+	case *ast.PropertyExpression:
+		return c.compileChain(node, false)
+
+	// * Identifiers, function calls:
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			// * the name may still be bound globally before the identifier is evaluated
+			symbol = c.symbolTable.Global().Define(node.Value)
+		}
+		c.emitGet(symbol)
+	case *ast.CallExpression:
+		return c.compileChain(node, false)
+
+	default:
+		return fmt.Errorf("cannot compile node of type %T at %s", node, node.Pos())
+	}
+
+	return nil
+}
+
+// Bytecode returns the compiled program.
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Lines:        c.scopes[len(c.scopes)-1].lines,
+		Constants:    c.constants,
+		GlobalNames:  append([]string{}, c.symbolTable.Global().Names()...),
+	}
+}
+
+// SymbolTable returns the symbol table of the current scope.
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
+
+// compileProgram compiles the statements of the program.
+// The value of the last statement is returned, if it is an expression statement.
+func (c *Compiler) compileProgram(statements []ast.Statement) error {
+	c.hoist(statements)
+
+	for index, stmt := range statements {
+		es, ok := stmt.(*ast.ExpressionStatement)
+		if !ok || index < len(statements)-1 {
+			if err := c.Compile(stmt); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := c.compileExpressionStatement(es, false, false); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+		return nil
+	}
+
+	c.emit(code.OpReturn)
+	return nil
+}
+
+// compileBlock compiles the statements of a block, leaving the value of the last statement on the stack,
+// or null if the last statement is no expression statement.
+//
+// In the statements of a function body, and of if-expressions in statement position within them,
+// return statements are in tail position, and so is the last statement, if tailLast is true.
+// This mirrors the evaluation of tail calls by the evaluator, so that both produce the same stack traces.
+func (c *Compiler) compileBlock(statements []ast.Statement, tailReturns bool, tailLast bool) error {
+	for index, stmt := range statements {
+		last := index == len(statements)-1
+
+		switch stmt := stmt.(type) {
+		case *ast.ExpressionStatement:
+			if err := c.compileExpressionStatement(stmt, tailReturns, tailLast && last); err != nil {
+				return err
+			}
+			if last {
+				return nil
+			}
+			c.emit(code.OpPop)
+		case *ast.ReturnStatement:
+			if err := c.compileReturnStatement(stmt, tailReturns); err != nil {
+				return err
+			}
+		default:
+			if err := c.Compile(stmt); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.emit(code.OpNull)
+	return nil
+}
+
+// compileExpressionStatement compiles the expression of the statement, leaving its value on the stack.
+// A named function literal in statement position also declares the function.
+func (c *Compiler) compileExpressionStatement(es *ast.ExpressionStatement, tailReturns bool, tail bool) error {
+	defer c.at(es)()
+
+	switch exp := es.Expression.(type) {
+	case *ast.CallExpression:
+		if tailReturns {
+			return c.compileChain(exp, tail)
+		}
+	case *ast.IfExpression:
+		if tailReturns {
+			return c.compileIfExpression(exp, true, tail)
+		}
+	case *ast.FunctionLiteral:
+		if exp.Name != nil {
+			if err := c.compileFunctionLiteral(exp, ""); err != nil {
+				return err
+			}
+			symbol := c.symbolTable.Define(exp.Name.Value)
+			c.emitSet(symbol)
+			c.emitGet(symbol)
+			return nil
+		}
+	}
+
+	return c.Compile(es.Expression)
+}
+
+func (c *Compiler) compileReturnStatement(rs *ast.ReturnStatement, tail bool) error {
+	defer c.at(rs)()
+
+	var err error
+	switch exp := rs.ReturnValue.(type) {
+	case *ast.CallExpression:
+		err = c.compileChain(exp, tail)
+	case *ast.IfExpression:
+		err = c.compileIfExpression(exp, tail, tail)
+	default:
+		err = c.Compile(exp)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpReturnValue)
+	return nil
+}
+
+// compileIfExpression compiles the if-expression, passing the tail position on to the statements of its branches.
+func (c *Compiler) compileIfExpression(ie *ast.IfExpression, tailReturns bool, tailLast bool) error {
+	defer c.at(ie)()
+
+	if err := c.Compile(ie.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, placeholderOperand)
+
+	if err := c.compileBlock(ie.Then.Statements, tailReturns, tailLast); err != nil {
+		return err
+	}
+	jump := c.emit(code.OpJump, placeholderOperand)
+
+	c.changeOperands(jumpNotTruthy, len(c.currentInstructions()))
+	if ie.Otherwise != nil {
+		if err := c.compileBlock(ie.Otherwise.Statements, tailReturns, tailLast); err != nil {
+			return err
+		}
+	} else {
+		c.emit(code.OpNull)
+	}
+	c.changeOperands(jump, len(c.currentInstructions()))
+
+	return nil
+}
+
+// compileNullishExpression compiles the right operand so that it is only evaluated, if the left operand is null.
+func (c *Compiler) compileNullishExpression(ie *ast.InfixExpression) error {
+	if err := c.Compile(ie.Left); err != nil {
+		return err
+	}
+	jumpNotNull := c.emit(code.OpJumpNotNull, placeholderOperand)
+	if err := c.Compile(ie.Right); err != nil {
+		return err
+	}
+	c.changeOperands(jumpNotNull, len(c.currentInstructions()))
+	return nil
+}
+
+// compileTryExpression compiles the branches of the try-expression into the following layout:
+//
+//	OpTry <catch> <finally>
+//	<try-branch>    OpEndTry  OpJump <end>
+//	<catch-branch>  OpEndTry  OpJump <end>
+//	<finally-branch>  OpPop  OpEndFinally
+//	<end>
+//
+// OpEndTry continues with the finally-branch, if there is one.
+func (c *Compiler) compileTryExpression(te *ast.TryExpression) error {
+	try := c.emit(code.OpTry, 0, 0)
+	jumps := []int{}
+
+	if err := c.compileBlock(te.Block.Statements, false, false); err != nil {
+		return err
+	}
+	c.emit(code.OpEndTry)
+	jumps = append(jumps, c.emit(code.OpJump, placeholderOperand))
+
+	catch := 0
+	if te.Catch != nil {
+		catch = len(c.currentInstructions())
+
+		// * the catch parameter and all bindings of the catch-branch are only visible within it
+		c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+		c.emitSet(c.symbolTable.Define(te.CatchParameter.Value))
+		c.hoist(te.Catch.Statements)
+		err := c.compileBlock(te.Catch.Statements, false, false)
+		c.symbolTable = c.symbolTable.Outer
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpEndTry)
+		jumps = append(jumps, c.emit(code.OpJump, placeholderOperand))
+	}
+
+	finally := 0
+	if te.Finally != nil {
+		finally = len(c.currentInstructions())
+		if err := c.compileBlock(te.Finally.Statements, false, false); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+		c.emit(code.OpEndFinally)
+	}
+
+	c.changeOperands(try, catch, finally)
+	for _, jump := range jumps {
+		c.changeOperands(jump, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+// compileFunctionLiteral compiles the function literal into a constant and emits the creation of its closure.
+// The name is used for anonymous function literals.
+func (c *Compiler) compileFunctionLiteral(fl *ast.FunctionLiteral, name string) error {
+	defer c.at(fl)()
+
+	if fl.Name != nil {
+		name = fl.Name.Value
+	}
+
+	fn := &object.CompiledFunction{
+		Name:          name,
+		NumParameters: len(fl.Parameters),
+		HasRest:       fl.Rest != nil,
+		Body:          fl.Body.String(),
+	}
+
+	c.enterScope()
+
+	// * parameters occupy the first slots in order, followed by the rest parameter
+	for index, param := range fl.Parameters {
+		c.symbolTable.allocate(param.Value)
+		if def := fl.Default(index); def != nil {
+			fn.Parameters = append(fn.Parameters, fmt.Sprintf("%s = %s", param, def))
+		} else {
+			fn.NumRequired++
+			fn.Parameters = append(fn.Parameters, param.String())
+		}
+	}
+	if fl.Rest != nil {
+		c.symbolTable.allocate(fl.Rest.Value)
+		fn.Parameters = append(fn.Parameters, "..."+fl.Rest.String())
+	}
+
+	for _, def := range fl.Defaults {
+		if def != nil {
+			c.hoistNode(def)
+		}
+	}
+	c.hoist(fl.Body.Statements)
+
+	// * default values are evaluated in order for all parameters without argument
+	for index := range fl.Parameters {
+		def := fl.Default(index)
+		if def == nil {
+			continue
+		}
+		jumpIfBound := c.emit(code.OpJumpIfBound, index, placeholderOperand)
+		if err := c.Compile(def); err != nil {
+			return err
+		}
+		c.emit(code.OpSetLocal, index)
+		c.changeOperands(jumpIfBound, index, len(c.currentInstructions()))
+	}
+
+	if err := c.compileBlock(fl.Body.Statements, true, true); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	fn.NumLocals = c.symbolTable.NumDefinitions()
+	fn.LocalNames = append([]string{}, c.symbolTable.Names()...)
+	fn.Instructions, fn.Lines = c.leaveScope()
+
+	index, err := c.addConstant(fn)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpClosure, index)
+	return nil
+}
+
+// compileChain compiles a chain of index, property and call expressions, like "a?.b[c](d)".
+// An optional link that finds null skips all following links up to the end of the chain or the enclosing parentheses,
+// so that the whole chain evaluates to null.
+// The last call of the chain replaces the current function call if tail is true.
+func (c *Compiler) compileChain(exp ast.Expression, tail bool) error {
+	jumps := []int{}
+	if err := c.compileLink(exp, tail, &jumps); err != nil {
+		return err
+	}
+	for _, jump := range jumps {
+		c.changeOperands(jump, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// compileLink compiles a link of a chain, adding the jumps of its optional links to jumps.
+func (c *Compiler) compileLink(exp ast.Expression, tail bool, jumps *[]int) error {
+	defer c.at(exp)()
+
+	switch exp := exp.(type) {
+	case *ast.IndexExpression:
+		if err := c.compileOperand(exp.Left, jumps); err != nil {
+			return err
+		}
+		if exp.Optional {
+			*jumps = append(*jumps, c.emit(code.OpJumpNull, placeholderOperand))
+		}
+		if err := c.Compile(exp.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.PropertyExpression:
+		if err := c.compileOperand(exp.Object, jumps); err != nil {
+			return err
+		}
+		if exp.Optional {
+			*jumps = append(*jumps, c.emit(code.OpJumpNull, placeholderOperand))
+		}
+		name, err := c.addConstant(&object.String{Value: exp.Property.Value})
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpProperty, name)
+	case *ast.CallExpression:
+		return c.compileCallExpression(exp, tail, jumps)
+	default:
+		return c.Compile(exp)
+	}
+	return nil
+}
+
+// compileOperand compiles the expression that a link is applied to.
+// It continues the chain of the link, unless it is enclosed in parentheses, which end a chain of its own.
+func (c *Compiler) compileOperand(exp ast.Expression, jumps *[]int) error {
+	if ast.IsGrouped(exp) {
+		return c.compileChain(exp, false)
+	}
+	return c.compileLink(exp, false, jumps)
+}
+
+// compileCallExpression compiles the call as link of a chain, replacing the current function call if tail is true.
+// Calls written with the pipeline operator start a chain of their own.
+func (c *Compiler) compileCallExpression(ce *ast.CallExpression, tail bool, jumps *[]int) error {
+	defer c.at(ce)()
+
+	if ident, ok := ce.Function.(*ast.Identifier); ok && ident.Value == evaluator.QUOTE {
+		// * quoting needs the syntax tree of the argument at run time, which compiled programs do not keep
+		return fmt.Errorf("quote is not supported by the vm: call at %s", ce.Pos())
+	}
+
+	if ce.Token.Type == token.PIPE {
+		if err := c.Compile(ce.Function); err != nil {
+			return err
+		}
+	} else if err := c.compileOperand(ce.Function, jumps); err != nil {
+		return err
+	}
+
+	if hasSpread(ce.Arguments) || len(ce.Arguments) > maxUint8 {
+		if err := c.compileArrayBuilder(ce.Arguments); err != nil {
+			return err
+		}
+		if tail {
+			c.emit(code.OpTailCallArray)
+		} else {
+			c.emit(code.OpCallArray)
+		}
+		return nil
+	}
+
+	for _, arg := range ce.Arguments {
+		if err := c.Compile(arg); err != nil {
+			return err
+		}
+	}
+	if tail {
+		c.emit(code.OpTailCall, len(ce.Arguments))
+	} else {
+		c.emit(code.OpCall, len(ce.Arguments))
+	}
+	return nil
+}
+
+// compileArrayBuilder compiles the expressions into an array that is built element by element,
+// expanding the elements of spread arrays in place.
+func (c *Compiler) compileArrayBuilder(exps []ast.Expression) error {
+	c.emit(code.OpArray, 0)
+
+	for _, exp := range exps {
+		spread, ok := exp.(*ast.SpreadExpression)
+		if !ok {
+			if err := c.Compile(exp); err != nil {
+				return err
+			}
+			c.emit(code.OpAppend)
+			continue
+		}
+
+		if err := c.Compile(spread.Value); err != nil {
+			return err
+		}
+		restore := c.at(spread)
+		c.emit(code.OpExtend)
+		restore()
+	}
+
+	return nil
+}
+
+// hoist defines the names bound in the current scope by the statements in advance,
+// so that functions can refer to bindings that are made after their definition, just like in the evaluator.
+// The statements of if-expressions and try-expressions bind names in the current scope as well.
+func (c *Compiler) hoist(statements []ast.Statement) {
+	for _, stmt := range statements {
+		c.hoistNode(stmt)
+	}
+}
+
+func (c *Compiler) hoistNode(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		c.symbolTable.Define(node.Name.Value)
+		c.hoistNode(node.Value)
+	case *ast.ImportStatement:
+		c.symbolTable.Define(node.Name.Value)
+	case *ast.ExportStatement:
+		c.hoistNode(node.Statement)
+	case *ast.ExpressionStatement:
+		if fl, ok := node.Expression.(*ast.FunctionLiteral); ok && fl.Name != nil {
+			c.symbolTable.Define(fl.Name.Value)
+		}
+		c.hoistNode(node.Expression)
+	case *ast.ReturnStatement:
+		c.hoistNode(node.ReturnValue)
+	case *ast.ThrowStatement:
+		c.hoistNode(node.Value)
+	case *ast.BlockStatement:
+		c.hoist(node.Statements)
+
+	case *ast.IfExpression:
+		c.hoistNode(node.Condition)
+		c.hoistNode(node.Then)
+		if node.Otherwise != nil {
+			c.hoistNode(node.Otherwise)
+		}
+	case *ast.TryExpression:
+		// * the catch-branch has its own scope
+		c.hoistNode(node.Block)
+		if node.Finally != nil {
+			c.hoistNode(node.Finally)
+		}
+	case *ast.PrefixExpression:
+		c.hoistNode(node.Right)
+	case *ast.InfixExpression:
+		c.hoistNode(node.Left)
+		c.hoistNode(node.Right)
+	case *ast.CallExpression:
+		c.hoistNode(node.Function)
+		for _, arg := range node.Arguments {
+			c.hoistNode(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			c.hoistNode(el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			c.hoistNode(pair.Key)
+			c.hoistNode(pair.Value)
+		}
+	case *ast.IndexExpression:
+		c.hoistNode(node.Left)
+		c.hoistNode(node.Index)
+	case *ast.PropertyExpression:
+		c.hoistNode(node.Object)
+	case *ast.SpreadExpression:
+		c.hoistNode(node.Value)
+	}
+}
+
+// at attributes all instructions emitted from now on to the position of the node.
+// It returns a function that restores the previous position.
+func (c *Compiler) at(node ast.Node) func() {
+	previous := c.position
+	c.position = node.Pos()
+	return func() { c.position = previous }
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[len(c.scopes)-1].instructions
+}
+
+// emit appends an instruction to the current scope and returns its offset.
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	scope := &c.scopes[len(c.scopes)-1]
+	offset := len(scope.instructions)
+
+	if len(scope.lines) == 0 || scope.lines[len(scope.lines)-1].Position != c.position {
+		scope.lines = append(scope.lines, code.LineEntry{Offset: offset, Position: c.position})
+	}
+	scope.instructions = append(scope.instructions, code.Make(op, operands...)...)
+
+	return offset
+}
+
+// changeOperands replaces the operands of the instruction at the given offset.
+func (c *Compiler) changeOperands(offset int, operands ...int) {
+	instructions := c.currentInstructions()
+	op := code.Opcode(instructions[offset])
+	copy(instructions[offset:], code.Make(op, operands...))
+}
+
+func (c *Compiler) addConstant(obj object.Object) (int, error) {
+	if len(c.constants) > maxUint16 {
+		return 0, fmt.Errorf("too many constants: %d", len(c.constants))
+	}
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1, nil
+}
+
+func (c *Compiler) emitConstant(obj object.Object) error {
+	index, err := c.addConstant(obj)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpConstant, index)
+	return nil
+}
+
+func (c *Compiler) emitGet(symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, symbol.Depth, symbol.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, symbol.Index)
+	}
+}
+
+// emitSet stores the topmost element in the symbol, which is always defined in the current scope.
+func (c *Compiler) emitSet(symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, symbol.Index)
+	}
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() (code.Instructions, code.LineTable) {
+	scope := c.scopes[len(c.scopes)-1]
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.symbolTable = c.symbolTable.Outer
+	return scope.instructions, scope.lines
+}
+
+// hasSpread reports whether any of the expressions is a spread expression.
+func hasSpread(exps []ast.Expression) bool {
+	for _, exp := range exps {
+		if _, ok := exp.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/smalldevshima/go-monkey/code"
+	"github.com/smalldevshima/go-monkey/lexer"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/parser"
+)
+
+/// Tests
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		expectedConstants    []string
+		expectedInstructions []code.Instructions
+	}{
+		{"integer-arithmetic", "1 + 2", []string{"1", "2"}, []code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpAdd),
+			code.Make(code.OpReturnValue),
+		}},
+		{"expression-statements", "1; -2", []string{"1", "2"}, []code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpPop),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpMinus),
+			code.Make(code.OpReturnValue),
+		}},
+		{"conditional", "if (true) { 10 }; 3", []string{"10", "3"}, []code.Instructions{
+			code.Make(code.OpTrue),
+			code.Make(code.OpJumpNotTruthy, 10),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpJump, 11),
+			code.Make(code.OpNull),
+			code.Make(code.OpPop),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpReturnValue),
+		}},
+		{"globals", "let one = 1; one", []string{"1"}, []code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpGetGlobal, 0),
+			code.Make(code.OpReturnValue),
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			program := parser.New(lexer.New(test.input)).ParseProgram()
+			compiler := New()
+			if err := compiler.Compile(program); err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+			bytecode := compiler.Bytecode()
+
+			expected := code.Instructions{}
+			for _, ins := range test.expectedInstructions {
+				expected = append(expected, ins...)
+			}
+			if bytecode.Instructions.String() != expected.String() {
+				t.Errorf("instructions are wrong.\nexpected:\n%s\ngot:\n%s", expected, bytecode.Instructions)
+			}
+
+			if len(bytecode.Constants) != len(test.expectedConstants) {
+				t.Fatalf("wrong number of constants. expected=%d, got=%d", len(test.expectedConstants), len(bytecode.Constants))
+			}
+			for i, constant := range test.expectedConstants {
+				if bytecode.Constants[i].Inspect() != constant {
+					t.Errorf("constant %d is wrong. expected=%s, got=%s", i, constant, bytecode.Constants[i].Inspect())
+				}
+			}
+		})
+	}
+}
+
+func TestCompileFunctions(t *testing.T) {
+	program := parser.New(lexer.New("let add = fn(a, b = 1) { a + b }")).ParseProgram()
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := compiler.Bytecode()
+
+	var fn *object.CompiledFunction
+	for _, constant := range bytecode.Constants {
+		if constant, ok := constant.(*object.CompiledFunction); ok {
+			fn = constant
+		}
+	}
+	if fn == nil {
+		t.Fatalf("no compiled function in constants")
+	}
+
+	if fn.Name != "add" || fn.NumParameters != 2 || fn.NumRequired != 1 || fn.NumLocals != 2 || fn.HasRest {
+		t.Errorf("function is wrong. got Name=%q, NumParameters=%d, NumRequired=%d, NumLocals=%d, HasRest=%v",
+			fn.Name, fn.NumParameters, fn.NumRequired, fn.NumLocals, fn.HasRest)
+	}
+}
@@ -0,0 +1,74 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/smalldevshima/go-monkey/code"
+	"github.com/smalldevshima/go-monkey/object"
+)
+
+/// Types
+
+// Disassemble returns a listing of the program's instructions, followed by its constants and the instructions of every compiled function.
+// Instructions are annotated with the source position they were compiled from, whenever it changes.
+func (b *Bytecode) Disassemble() string {
+	var out bytes.Buffer
+
+	fmt.Fprintf(&out, "== %s ==\n", object.FRAME_PROGRAM)
+	disassembleInstructions(&out, b.Instructions, b.Lines)
+
+	if len(b.GlobalNames) > 0 {
+		fmt.Fprintf(&out, "\n== globals ==\n")
+		for index, name := range b.GlobalNames {
+			fmt.Fprintf(&out, "%04d %s\n", index, name)
+		}
+	}
+
+	if len(b.Constants) > 0 {
+		fmt.Fprintf(&out, "\n== constants ==\n")
+		for index, constant := range b.Constants {
+			switch constant := constant.(type) {
+			case *object.CompiledFunction:
+				fmt.Fprintf(&out, "%04d %s %s(%s)\n", index, constant.Type(), constant.DisplayName(), strings.Join(constant.Parameters, ", "))
+			case *object.String:
+				fmt.Fprintf(&out, "%04d %s %q\n", index, constant.Type(), constant.Value)
+			default:
+				fmt.Fprintf(&out, "%04d %s %s\n", index, constant.Type(), constant.Inspect())
+			}
+		}
+	}
+
+	for index, constant := range b.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&out, "\n== constant %d: %s ==\n", index, fn.DisplayName())
+		fmt.Fprintf(&out, "locals: %s\n", strings.Join(fn.LocalNames, ", "))
+		disassembleInstructions(&out, fn.Instructions, fn.Lines)
+	}
+
+	return out.String()
+}
+
+// disassembleInstructions writes the instructions, prefixing the first instruction of every line table entry with its position.
+func disassembleInstructions(out *bytes.Buffer, ins code.Instructions, lines code.LineTable) {
+	entry := 0
+	for _, line := range strings.SplitAfter(ins.String(), "\n") {
+		if line == "" {
+			continue
+		}
+
+		offset, _ := strconv.Atoi(strings.Fields(line)[0])
+
+		position := ""
+		for entry < len(lines) && lines[entry].Offset <= offset {
+			position = lines[entry].Position.String()
+			entry++
+		}
+		fmt.Fprintf(out, "%7s  %s", position, line)
+	}
+}
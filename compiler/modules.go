@@ -0,0 +1,127 @@
+package compiler
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/code"
+	"github.com/smalldevshima/go-monkey/evaluator"
+	"github.com/smalldevshima/go-monkey/module"
+	"github.com/smalldevshima/go-monkey/object"
+)
+
+/// Functions
+
+// newModuleSymbolTable creates the outermost table of a module, which defines the builtins only,
+// so that the bindings of the importing program are not visible within the module.
+// Names that are not bound by the module are allocated as globals of the given table.
+func newModuleSymbolTable(global *SymbolTable) *SymbolTable {
+	s := &SymbolTable{store: make(map[string]Symbol), owner: global.owner}
+	for index, builtin := range evaluator.Builtins() {
+		s.DefineBuiltin(index, builtin.Name)
+	}
+	return s
+}
+
+// loader returns the module loader of the compiler, creating a default one if it has none.
+func (c *Compiler) loader() *module.Loader {
+	if c.Loader == nil {
+		c.Loader = &module.Loader{}
+	}
+	return c.Loader
+}
+
+// compileImportStatement compiles the module imported by the statement, unless it already is,
+// and emits the import of the module, which evaluates it on first use only.
+func (c *Compiler) compileImportStatement(is *ast.ImportStatement) error {
+	from := c.Path
+	if len(c.importing) != 0 {
+		from = c.importing[len(c.importing)-1]
+	}
+	mod, err := c.loader().Load(is.Path.Value, from)
+	if err != nil {
+		return fmt.Errorf("cannot import %q at %s: %s", is.Path.Value, is.Pos(), err)
+	}
+
+	// * the module is cached in a global, whose name cannot clash with any identifier
+	global := c.symbolTable.Global().owner
+	cache := global.Define(fmt.Sprintf(object.F_MODULE_FRAME, mod.Path))
+
+	index, ok := c.modules[mod.Path]
+	if !ok {
+		if index, err = c.compileModule(mod, cache); err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpImport, cache.Index, index)
+	c.emitSet(c.symbolTable.Define(is.Name.Value))
+	return nil
+}
+
+// compileModule compiles the program of the module into a function constant and returns its index.
+// The function binds the top-level bindings of the module in its locals and returns the module,
+// after caching it in the given global symbol.
+func (c *Compiler) compileModule(mod *module.Module, cache Symbol) (int, error) {
+	importing := c.importing
+	if len(importing) == 0 && c.Path != "" {
+		importing = []string{filepath.Clean(c.Path)}
+	}
+	for index, path := range importing {
+		if path == mod.Path {
+			cycle := append(append([]string{}, importing[index:]...), mod.Path)
+			return 0, fmt.Errorf("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	previous := c.importing
+	c.importing = append(importing, mod.Path)
+	outer := c.symbolTable
+	c.symbolTable = newModuleSymbolTable(c.symbolTable.Global().owner)
+	c.enterScope()
+	defer func() {
+		c.importing = previous
+		c.symbolTable = outer
+	}()
+
+	fn := &object.CompiledFunction{Name: fmt.Sprintf(object.F_MODULE_FRAME, mod.Path), Body: mod.Program.String()}
+
+	c.hoist(mod.Program.Statements)
+	for _, stmt := range mod.Program.Statements {
+		if err := c.Compile(stmt); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, name := range mod.Exports {
+		if err := c.emitConstant(&object.String{Value: name}); err != nil {
+			return 0, err
+		}
+		symbol, _ := c.symbolTable.Resolve(name)
+		c.emitGet(symbol)
+	}
+	path, err := c.addConstant(&object.String{Value: mod.Path})
+	if err != nil {
+		return 0, err
+	}
+	c.emit(code.OpModule, path, len(mod.Exports))
+	c.emitSet(cache)
+	c.emitGet(cache)
+	c.emit(code.OpReturnValue)
+
+	fn.NumLocals = c.symbolTable.NumDefinitions()
+	fn.LocalNames = append([]string{}, c.symbolTable.Names()...)
+	fn.Instructions, fn.Lines = c.leaveScope()
+
+	index, err := c.addConstant(fn)
+	if err != nil {
+		return 0, err
+	}
+	if c.modules == nil {
+		c.modules = make(map[string]int)
+	}
+	c.modules[mod.Path] = index
+	return index, nil
+}
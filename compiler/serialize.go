@@ -0,0 +1,451 @@
+package compiler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/smalldevshima/go-monkey/code"
+	"github.com/smalldevshima/go-monkey/evaluator"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Constants / Variables
+
+// Magic is the header that every serialized program starts with.
+const Magic = "\x00MKC"
+
+// FormatVersion is the version of the serialization format and of the instruction set.
+// It has to be increased whenever either of them changes, so that outdated files are rejected instead of misinterpreted.
+const FormatVersion uint16 = 2
+
+// FileExtension is the conventional extension of files containing a serialized program.
+const FileExtension = ".mkc"
+
+// Tags of serialized constants
+const (
+	tagInteger byte = iota + 1
+	tagString
+	tagCompiledFunction
+)
+
+// maxLength limits the length of serialized sequences.
+// Sequences are read incrementally, so that the memory allocated for a corrupted length is bounded by the size of the input.
+const maxLength = 1 << 28
+
+// ErrNotBytecode is returned by ReadBytecode if the input does not start with Magic.
+var ErrNotBytecode = errors.New("input is no serialized monkey program")
+
+/// Functions
+
+// IsBytecode reports whether the data starts with the header of a serialized program.
+func IsBytecode(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(Magic))
+}
+
+// ReadBytecode reads a program serialized by WriteTo.
+// Programs serialized with a different format version, or compiled against different builtins, are rejected.
+// So are instructions referring to missing constants, builtins or local bindings, or jumping into other instructions.
+// The use of the stack and of free variables is not verified, so programs must not come from untrusted sources.
+func ReadBytecode(r io.Reader) (*Bytecode, error) {
+	d := &decoder{r: bufio.NewReader(r)}
+
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(d.r, magic); err != nil || string(magic) != Magic {
+		return nil, ErrNotBytecode
+	}
+
+	var version uint16
+	if err := binary.Read(d.r, binary.BigEndian, &version); err != nil {
+		return nil, fmt.Errorf("could not read format version: %w", err)
+	}
+	if version != FormatVersion {
+		return nil, fmt.Errorf("unsupported format version %d, expected %d", version, FormatVersion)
+	}
+
+	builtins := d.strings()
+	if d.err == nil {
+		if err := checkBuiltins(builtins); err != nil {
+			return nil, err
+		}
+	}
+
+	bytecode := &Bytecode{
+		Instructions: d.bytes(),
+		Lines:        d.lines(),
+		GlobalNames:  d.strings(),
+	}
+
+	count := d.length()
+	for i := 0; i < count && d.err == nil; i++ {
+		bytecode.Constants = append(bytecode.Constants, d.constant())
+	}
+
+	if d.err != nil {
+		if d.err == io.EOF {
+			d.err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("could not read program: %w", d.err)
+	}
+
+	if err := checkProgram(bytecode); err != nil {
+		return nil, fmt.Errorf("invalid program: %w", err)
+	}
+	return bytecode, nil
+}
+
+// checkBuiltins checks that the builtins a program was compiled against are the ones available,
+// since builtins are referred to by their index.
+func checkBuiltins(names []string) error {
+	builtins := evaluator.Builtins()
+	if len(names) != len(builtins) {
+		return fmt.Errorf("program was compiled against %d builtins, but %d are available", len(names), len(builtins))
+	}
+	for index, name := range names {
+		if builtins[index].Name != name {
+			return fmt.Errorf("program was compiled against builtin %q at index %d, but found %q", name, index, builtins[index].Name)
+		}
+	}
+	return nil
+}
+
+// checkProgram checks the instructions of the program and of its compiled functions,
+// so that a corrupted program is rejected instead of crashing the VM.
+func checkProgram(bytecode *Bytecode) error {
+	if err := checkInstructions(bytecode.Instructions, bytecode.Constants, len(bytecode.GlobalNames), nil); err != nil {
+		return err
+	}
+
+	for index, constant := range bytecode.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		if fn.NumRequired > fn.NumParameters || fn.NumParameters > fn.NumLocals || len(fn.LocalNames) < fn.NumLocals ||
+			(fn.HasRest && fn.NumParameters >= fn.NumLocals) {
+			return fmt.Errorf("constant %d: inconsistent parameters and local bindings", index)
+		}
+		if err := checkInstructions(fn.Instructions, bytecode.Constants, len(bytecode.GlobalNames), fn); err != nil {
+			return fmt.Errorf("constant %d: %w", index, err)
+		}
+	}
+
+	return nil
+}
+
+// checkInstructions checks that the instructions are complete, only refer to existing constants of the expected types,
+// the given number of globals, builtins and local bindings of fn, which is nil for the program,
+// and only jump to the start of an instruction or to the end.
+func checkInstructions(ins code.Instructions, constants []object.Object, numGlobals int, fn *object.CompiledFunction) error {
+	numLocals := 0
+	if fn != nil {
+		numLocals = fn.NumLocals
+	}
+
+	checkConstant := func(index int, valid func(object.Object) bool) error {
+		if index >= len(constants) || !valid(constants[index]) {
+			return fmt.Errorf("invalid constant %d", index)
+		}
+		return nil
+	}
+	checkGlobal := func(index int) error {
+		if index >= numGlobals {
+			return fmt.Errorf("invalid global %d", index)
+		}
+		return nil
+	}
+	anyObject := func(object.Object) bool { return true }
+	isString := func(obj object.Object) bool { _, ok := obj.(*object.String); return ok }
+	isFunction := func(obj object.Object) bool { _, ok := obj.(*object.CompiledFunction); return ok }
+
+	// offsets of the starts of instructions and of the jump targets
+	starts := map[int]bool{len(ins): true}
+	var targets []int
+
+	for offset := 0; offset < len(ins); {
+		def, err := code.Lookup(ins[offset])
+		if err != nil {
+			return fmt.Errorf("offset %d: %w", offset, err)
+		}
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if offset+1+width > len(ins) {
+			return fmt.Errorf("offset %d: incomplete instruction %s", offset, def.Name)
+		}
+		operands, _ := code.ReadOperands(def, ins[offset+1:])
+
+		switch code.Opcode(ins[offset]) {
+		case code.OpConstant:
+			err = checkConstant(operands[0], anyObject)
+		case code.OpProperty:
+			err = checkConstant(operands[0], isString)
+		case code.OpClosure:
+			err = checkConstant(operands[0], isFunction)
+		case code.OpImport:
+			err = checkGlobal(operands[0])
+			if err == nil {
+				err = checkConstant(operands[1], isFunction)
+			}
+		case code.OpModule:
+			err = checkConstant(operands[0], isString)
+		case code.OpGetGlobal, code.OpSetGlobal:
+			err = checkGlobal(operands[0])
+		case code.OpGetBuiltin:
+			if operands[0] >= len(evaluator.Builtins()) {
+				err = fmt.Errorf("invalid builtin %d", operands[0])
+			}
+		case code.OpGetLocal, code.OpSetLocal:
+			if operands[0] >= numLocals {
+				err = fmt.Errorf("invalid local binding %d", operands[0])
+			}
+		case code.OpJumpIfBound:
+			if operands[0] >= numLocals {
+				err = fmt.Errorf("invalid local binding %d", operands[0])
+			}
+			targets = append(targets, operands[1])
+		case code.OpJump, code.OpJumpNotTruthy, code.OpJumpNull, code.OpJumpNotNull:
+			targets = append(targets, operands[0])
+		case code.OpTry:
+			// * zero means there is no such branch, which is the start of an instruction anyway
+			targets = append(targets, operands...)
+		}
+		if err != nil {
+			return fmt.Errorf("offset %d: %s: %w", offset, def.Name, err)
+		}
+
+		starts[offset] = true
+		offset += 1 + width
+	}
+
+	for _, target := range targets {
+		if !starts[target] {
+			return fmt.Errorf("jump to offset %d, which is no instruction", target)
+		}
+	}
+	return nil
+}
+
+/// Types
+
+// WriteTo serializes the program, including the constants and line tables, to w.
+// It implements io.WriterTo.
+func (b *Bytecode) WriteTo(w io.Writer) (int64, error) {
+	e := &encoder{}
+	e.buf.WriteString(Magic)
+	binary.Write(&e.buf, binary.BigEndian, FormatVersion)
+
+	builtins := evaluator.Builtins()
+	e.uint(len(builtins))
+	for _, builtin := range builtins {
+		e.string(builtin.Name)
+	}
+
+	e.bytes(b.Instructions)
+	e.lines(b.Lines)
+	e.strings(b.GlobalNames)
+
+	e.uint(len(b.Constants))
+	for _, constant := range b.Constants {
+		if err := e.constant(constant); err != nil {
+			return 0, err
+		}
+	}
+
+	return e.buf.WriteTo(w)
+}
+
+// encoder writes the parts of a serialized program.
+// Integers are written as varints and sequences are prefixed by their length.
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) uint(value int) {
+	var scratch [binary.MaxVarintLen64]byte
+	e.buf.Write(scratch[:binary.PutUvarint(scratch[:], uint64(value))])
+}
+
+func (e *encoder) int(value int64) {
+	var scratch [binary.MaxVarintLen64]byte
+	e.buf.Write(scratch[:binary.PutVarint(scratch[:], value)])
+}
+
+func (e *encoder) bool(value bool) {
+	if value {
+		e.buf.WriteByte(1)
+	} else {
+		e.buf.WriteByte(0)
+	}
+}
+
+func (e *encoder) bytes(value []byte) {
+	e.uint(len(value))
+	e.buf.Write(value)
+}
+
+func (e *encoder) string(value string) {
+	e.uint(len(value))
+	e.buf.WriteString(value)
+}
+
+func (e *encoder) strings(values []string) {
+	e.uint(len(values))
+	for _, value := range values {
+		e.string(value)
+	}
+}
+
+func (e *encoder) lines(lines code.LineTable) {
+	e.uint(len(lines))
+	for _, entry := range lines {
+		e.uint(entry.Offset)
+		e.uint(entry.Position.Line)
+		e.uint(entry.Position.Column)
+	}
+}
+
+func (e *encoder) constant(obj object.Object) error {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		e.buf.WriteByte(tagInteger)
+		e.int(obj.Value)
+	case *object.String:
+		e.buf.WriteByte(tagString)
+		e.string(obj.Value)
+	case *object.CompiledFunction:
+		e.buf.WriteByte(tagCompiledFunction)
+		e.string(obj.Name)
+		e.bytes(obj.Instructions)
+		e.lines(obj.Lines)
+		e.uint(obj.NumLocals)
+		e.uint(obj.NumParameters)
+		e.uint(obj.NumRequired)
+		e.bool(obj.HasRest)
+		e.strings(obj.LocalNames)
+		e.strings(obj.Parameters)
+		e.string(obj.Body)
+	default:
+		return fmt.Errorf("cannot serialize constant of type %s", obj.Type())
+	}
+	return nil
+}
+
+// decoder reads the parts of a serialized program.
+// The first error is kept in err, after which all reads return zero values.
+type decoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *decoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	value, err := binary.ReadUvarint(d.r)
+	d.err = err
+	return value
+}
+
+func (d *decoder) int() int64 {
+	if d.err != nil {
+		return 0
+	}
+	value, err := binary.ReadVarint(d.r)
+	d.err = err
+	return value
+}
+
+// length reads the length of a sequence.
+func (d *decoder) length() int {
+	length := d.uint()
+	if length > maxLength {
+		d.err = fmt.Errorf("invalid length %d", length)
+		return 0
+	}
+	return int(length)
+}
+
+func (d *decoder) bool() bool {
+	if d.err != nil {
+		return false
+	}
+	value, err := d.r.ReadByte()
+	d.err = err
+	return value != 0
+}
+
+// bytes reads a sequence of bytes, which grows with the bytes actually read, instead of being allocated up front.
+func (d *decoder) bytes() []byte {
+	length := d.length()
+	if d.err != nil {
+		return nil
+	}
+	var value bytes.Buffer
+	_, d.err = io.CopyN(&value, d.r, int64(length))
+	return value.Bytes()
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) strings() []string {
+	count := d.length()
+	var values []string
+	for i := 0; i < count && d.err == nil; i++ {
+		values = append(values, d.string())
+	}
+	return values
+}
+
+func (d *decoder) lines() code.LineTable {
+	count := d.length()
+	var lines code.LineTable
+	for i := 0; i < count && d.err == nil; i++ {
+		lines = append(lines, code.LineEntry{
+			Offset:   int(d.uint()),
+			Position: token.Position{Line: int(d.uint()), Column: int(d.uint())},
+		})
+	}
+	return lines
+}
+
+func (d *decoder) constant() object.Object {
+	if d.err != nil {
+		return nil
+	}
+	tag, err := d.r.ReadByte()
+	if err != nil {
+		d.err = err
+		return nil
+	}
+
+	switch tag {
+	case tagInteger:
+		return &object.Integer{Value: d.int()}
+	case tagString:
+		return &object.String{Value: d.string()}
+	case tagCompiledFunction:
+		return &object.CompiledFunction{
+			Name:          d.string(),
+			Instructions:  d.bytes(),
+			Lines:         d.lines(),
+			NumLocals:     int(d.uint()),
+			NumParameters: int(d.uint()),
+			NumRequired:   int(d.uint()),
+			HasRest:       d.bool(),
+			LocalNames:    d.strings(),
+			Parameters:    d.strings(),
+			Body:          d.string(),
+		}
+	}
+
+	d.err = fmt.Errorf("unknown constant tag %d", tag)
+	return nil
+}
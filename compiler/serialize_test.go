@@ -0,0 +1,192 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/smalldevshima/go-monkey/code"
+	"github.com/smalldevshima/go-monkey/lexer"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/parser"
+)
+
+/// Tests
+
+func TestSerializeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"constants", `1; -9223372036854775807; "string with	tab"; true; null`},
+		{"nested-functions", `let outer = fn(a, b = 2, ...rest) { let inner = fn(c) { a + b + c }; inner(len(rest)) }; outer(1)`},
+		{"try", `try { throw "x" } catch (e) { e["message"] } finally { 1 }`},
+		{"multiline", "let x = 1;\nlet y = fn() {\n\tx\n};\ny()"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bytecode := testCompile(t, test.input)
+
+			var buf bytes.Buffer
+			if _, err := bytecode.WriteTo(&buf); err != nil {
+				t.Fatalf("could not serialize: %s", err)
+			}
+			if !IsBytecode(buf.Bytes()) {
+				t.Fatalf("serialized program does not start with magic header")
+			}
+
+			decoded, err := ReadBytecode(&buf)
+			if err != nil {
+				t.Fatalf("could not deserialize: %s", err)
+			}
+
+			if decoded.Disassemble() != bytecode.Disassemble() {
+				t.Errorf("programs differ.\nexpected:\n%s\ngot:\n%s", bytecode.Disassemble(), decoded.Disassemble())
+			}
+			for i, constant := range bytecode.Constants {
+				if decoded.Constants[i].Inspect() != constant.Inspect() {
+					t.Errorf("constant %d differs. expected=%s, got=%s", i, constant.Inspect(), decoded.Constants[i].Inspect())
+				}
+			}
+		})
+	}
+}
+
+func TestReadBytecodeErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := testCompile(t, `let f = fn(x) { x * 2 }; f("a")`).WriteTo(&buf); err != nil {
+		t.Fatalf("could not serialize: %s", err)
+	}
+	valid := buf.Bytes()
+
+	otherVersion := append([]byte{}, valid...)
+	binary.BigEndian.PutUint16(otherVersion[len(Magic):], FormatVersion+1)
+
+	otherBuiltins := append([]byte{}, valid...)
+	index := bytes.Index(otherBuiltins, []byte("len"))
+	copy(otherBuiltins[index:], "LEN")
+
+	// * the instructions of an empty program claim a length of 2^28, but the input ends instead
+	hugeLength := testSerialize(t, &Bytecode{})
+	hugeLength = append(hugeLength[:len(hugeLength)-4], 0x80, 0x80, 0x80, 0x80, 0x01)
+
+	integer := &object.Integer{Value: 1}
+	function := &object.CompiledFunction{
+		Instructions: append(code.Make(code.OpGetLocal, 1), code.Make(code.OpReturnValue)...),
+		NumLocals:    1,
+		LocalNames:   []string{"x"},
+	}
+
+	tests := []struct {
+		name     string
+		input    []byte
+		expected string
+	}{
+		{"empty", []byte{}, ErrNotBytecode.Error()},
+		{"source-code", []byte("let x = 1;"), ErrNotBytecode.Error()},
+		{"other-version", otherVersion, "unsupported format version 3, expected 2"},
+		{"other-builtins", otherBuiltins, `program was compiled against builtin "LEN"`},
+		{"truncated", valid[:len(valid)-3], "could not read program: unexpected EOF"},
+		{"no-version", []byte(Magic), "could not read format version: EOF"},
+		{"huge-length", hugeLength, "could not read program: unexpected EOF"},
+		{
+			"invalid/opcode",
+			testSerialize(t, &Bytecode{Instructions: code.Instructions{255}}),
+			"invalid program: offset 0: opcode 255 undefined",
+		},
+		{
+			"invalid/incomplete",
+			testSerialize(t, &Bytecode{Instructions: code.Make(code.OpConstant, 0)[:2], Constants: []object.Object{integer}}),
+			"invalid program: offset 0: incomplete instruction OpConstant",
+		},
+		{
+			"invalid/constant-index",
+			testSerialize(t, &Bytecode{Instructions: code.Make(code.OpConstant, 1), Constants: []object.Object{integer}}),
+			"invalid program: offset 0: OpConstant: invalid constant 1",
+		},
+		{
+			"invalid/constant-type",
+			testSerialize(t, &Bytecode{Instructions: code.Make(code.OpClosure, 0), Constants: []object.Object{integer}}),
+			"invalid program: offset 0: OpClosure: invalid constant 0",
+		},
+		{
+			"invalid/global/get",
+			testSerialize(t, &Bytecode{Instructions: code.Make(code.OpGetGlobal, 5), GlobalNames: []string{"x"}}),
+			"invalid program: offset 0: OpGetGlobal: invalid global 5",
+		},
+		{
+			"invalid/global/set",
+			testSerialize(t, &Bytecode{Instructions: append(code.Make(code.OpNull), code.Make(code.OpSetGlobal, 1)...), GlobalNames: []string{"x"}}),
+			"invalid program: offset 1: OpSetGlobal: invalid global 1",
+		},
+		{
+			"invalid/global/import",
+			testSerialize(t, &Bytecode{Instructions: code.Make(code.OpImport, 0, 0), Constants: []object.Object{&object.CompiledFunction{}}}),
+			"invalid program: offset 0: OpImport: invalid global 0",
+		},
+		{
+			"invalid/builtin",
+			testSerialize(t, &Bytecode{Instructions: code.Make(code.OpGetBuiltin, 200)}),
+			"invalid program: offset 0: OpGetBuiltin: invalid builtin 200",
+		},
+		{
+			"invalid/local",
+			testSerialize(t, &Bytecode{Constants: []object.Object{function}}),
+			"invalid program: constant 0: offset 0: OpGetLocal: invalid local binding 1",
+		},
+		{
+			"invalid/jump-target",
+			testSerialize(t, &Bytecode{
+				Instructions: append(code.Make(code.OpJump, 4), code.Make(code.OpConstant, 0)...),
+				Constants:    []object.Object{integer},
+			}),
+			"invalid program: jump to offset 4, which is no instruction",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadBytecode(bytes.NewReader(test.input))
+			if err == nil {
+				t.Fatalf("no error returned")
+			}
+			if !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("error is wrong. expected to contain %q, got=%q", test.expected, err)
+			}
+		})
+	}
+
+	if _, err := ReadBytecode(bytes.NewReader([]byte("x"))); !errors.Is(err, ErrNotBytecode) {
+		t.Errorf("error is not ErrNotBytecode. got=%v", err)
+	}
+}
+
+/// helpers
+
+func testSerialize(t *testing.T, bytecode *Bytecode) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := bytecode.WriteTo(&buf); err != nil {
+		t.Fatalf("could not serialize: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func testCompile(t *testing.T, input string) *Bytecode {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return compiler.Bytecode()
+}
@@ -0,0 +1,128 @@
+package compiler
+
+/// Constants / Variables
+
+// Symbol scopes
+const (
+	// GlobalScope symbols are stored in the globals of the VM
+	GlobalScope SymbolScope = "GLOBAL"
+	// LocalScope symbols are stored in the scope of the current function call
+	LocalScope SymbolScope = "LOCAL"
+	// FreeScope symbols are local symbols of an enclosing function
+	FreeScope SymbolScope = "FREE"
+	// BuiltinScope symbols refer to builtin functions
+	BuiltinScope SymbolScope = "BUILTIN"
+)
+
+/// Functions
+
+// NewSymbolTable creates the table of global symbols.
+func NewSymbolTable() *SymbolTable {
+	s := &SymbolTable{store: make(map[string]Symbol)}
+	s.owner = s
+	return s
+}
+
+// NewEnclosedSymbolTable creates the table of the local symbols of a function.
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// NewBlockSymbolTable creates a table for symbols that are only visible within a block, like the parameter of a catch-branch.
+// Its symbols are stored alongside the symbols of the enclosing function, or the globals.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	return &SymbolTable{Outer: outer, store: make(map[string]Symbol), owner: outer.owner}
+}
+
+/// Types
+
+type SymbolScope string
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	// Depth is the number of functions between the current one and the one defining a FreeScope symbol
+	Depth int
+}
+
+// SymbolTable maps the identifiers of one scope to their storage location.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store map[string]Symbol
+	// the table that the storage of the symbols is allocated from, either the table itself or the enclosing function's table
+	owner *SymbolTable
+	// the names of the symbols allocated from this table, by index
+	names []string
+}
+
+// Define returns the symbol for the name in this table, allocating a new one if the name is not yet defined in it.
+// Defining a name again in the same table reuses the existing symbol, just like a let statement overwrites a binding of an Environment.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && symbol.Scope != BuiltinScope {
+		return symbol
+	}
+	return s.allocate(name)
+}
+
+// allocate defines a new symbol for the name in this table, even if the name is already defined in it.
+func (s *SymbolTable) allocate(name string) Symbol {
+	symbol := Symbol{Name: name, Index: len(s.owner.names), Scope: LocalScope}
+	if s.owner.Outer == nil {
+		symbol.Scope = GlobalScope
+	}
+	s.owner.names = append(s.owner.names, name)
+	s.store[name] = symbol
+	return symbol
+}
+
+// DefineBuiltin defines the name of the builtin with the given index.
+// Builtins are shadowed by symbols defined with the same name.
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// Resolve looks up the name in this table and all enclosing tables.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	depth := 0
+	for table := s; table != nil; table = table.Outer {
+		symbol, ok := table.store[name]
+		if ok {
+			if symbol.Scope == LocalScope && depth > 0 {
+				symbol.Scope = FreeScope
+				symbol.Depth = depth
+			}
+			return symbol, true
+		}
+
+		// * leaving the table of a function
+		if table.owner == table {
+			depth++
+		}
+	}
+	return Symbol{}, false
+}
+
+// Global returns the table of global symbols.
+func (s *SymbolTable) Global() *SymbolTable {
+	table := s
+	for table.Outer != nil {
+		table = table.Outer
+	}
+	return table
+}
+
+// NumDefinitions returns the number of symbols allocated from this table.
+func (s *SymbolTable) NumDefinitions() int {
+	return len(s.owner.names)
+}
+
+// Names returns the names of the symbols allocated from this table, by index.
+func (s *SymbolTable) Names() []string {
+	return s.owner.names
+}
@@ -0,0 +1,90 @@
+package compiler
+
+import "testing"
+
+/// Tests
+
+func TestDefine(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	b := global.Define("b")
+	again := global.Define("a")
+
+	local := NewEnclosedSymbolTable(global)
+	c := local.Define("c")
+
+	block := NewBlockSymbolTable(local)
+	d := block.Define("d")
+	shadowed := block.Define("c")
+
+	tests := []struct {
+		name     string
+		actual   Symbol
+		expected Symbol
+	}{
+		{"global", a, Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"second-global", b, Symbol{Name: "b", Scope: GlobalScope, Index: 1}},
+		{"redefined", again, Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"local", c, Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+		{"block", d, Symbol{Name: "d", Scope: LocalScope, Index: 1}},
+		{"block-shadowing", shadowed, Symbol{Name: "c", Scope: LocalScope, Index: 2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.actual != test.expected {
+				t.Errorf("symbol is wrong. expected=%+v, got=%+v", test.expected, test.actual)
+			}
+		})
+	}
+
+	if local.NumDefinitions() != 3 {
+		t.Errorf("local table has wrong number of definitions. expected=3, got=%d", local.NumDefinitions())
+	}
+}
+
+func TestResolve(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	first := NewEnclosedSymbolTable(global)
+	first.Define("b")
+	block := NewBlockSymbolTable(first)
+	block.Define("c")
+
+	second := NewEnclosedSymbolTable(block)
+	second.Define("d")
+	second.Define("len")
+
+	tests := []struct {
+		name     string
+		table    *SymbolTable
+		expected Symbol
+	}{
+		{"a", second, Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"b", second, Symbol{Name: "b", Scope: FreeScope, Index: 0, Depth: 1}},
+		{"c", second, Symbol{Name: "c", Scope: FreeScope, Index: 1, Depth: 1}},
+		{"c", block, Symbol{Name: "c", Scope: LocalScope, Index: 1}},
+		{"b", block, Symbol{Name: "b", Scope: LocalScope, Index: 0}},
+		{"d", second, Symbol{Name: "d", Scope: LocalScope, Index: 0}},
+		{"len", second, Symbol{Name: "len", Scope: LocalScope, Index: 1}},
+		{"len", first, Symbol{Name: "len", Scope: BuiltinScope, Index: 0}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, ok := test.table.Resolve(test.name)
+			if !ok {
+				t.Fatalf("name %s not resolvable", test.name)
+			}
+			if actual != test.expected {
+				t.Errorf("symbol is wrong. expected=%+v, got=%+v", test.expected, actual)
+			}
+		})
+	}
+
+	if _, ok := second.Resolve("undefined"); ok {
+		t.Errorf("undefined name is resolvable")
+	}
+}
@@ -4,7 +4,8 @@ import "github.com/smalldevshima/go-monkey/object"
 
 var builtins = map[string]*object.Builtin{
 	"len": {
-		Fn: B_LEN,
+		Name: "len",
+		Fn:   B_LEN,
 	},
 }
 
@@ -17,6 +18,8 @@ var B_LEN object.BuiltinFunction = func(args ...object.Object) object.Object {
 	switch arg := args[0].(type) {
 	case *object.String:
 		return &object.Integer{Value: int64(len(arg.Value))}
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
 	default:
 		return newError(ERR_BUILTIN_TYPE_ERROR, 0, "len", object.O_STRING, arg.Type())
 	}
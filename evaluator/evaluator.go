@@ -1,23 +1,57 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/module"
 	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/resolver"
+	"github.com/smalldevshima/go-monkey/token"
 )
 
 // Constants / Variables
 
 // Error format strings
 const (
-	ERR_PREFIX_UNKNOWN     ErrorFormat = "unknown operator: %s%s"
-	ERR_INFIX_UNKNOWN      ErrorFormat = "unknown operator: %s %s %s"
-	ERR_INFIX_MISMATCH     ErrorFormat = "type mismatch: %s %s %s"
-	ERR_IDENTIFIER_UNKNOWN ErrorFormat = "unknown identifier: %s"
-	ERR_NOT_A_FUNCTION     ErrorFormat = "cannot call expression of type: %s"
-	ERR_ARG_COUNT_MISMATCH ErrorFormat = "function %q expects %d arguments. got=%d"
-	ERR_BUILTIN_TYPE_ERROR ErrorFormat = "argument %d of call to builtin %q expects type %s, got %s"
+	ERR_PREFIX_UNKNOWN      ErrorFormat = "unknown operator: %s%s"
+	ERR_INFIX_UNKNOWN       ErrorFormat = "unknown operator: %s %s %s"
+	ERR_INFIX_MISMATCH      ErrorFormat = "type mismatch: %s %s %s"
+	ERR_IDENTIFIER_UNKNOWN  ErrorFormat = "unknown identifier: %s"
+	ERR_NOT_A_FUNCTION      ErrorFormat = "cannot call expression of type: %s"
+	ERR_ARG_COUNT_MISMATCH  ErrorFormat = "function %q expects %d arguments. got=%d"
+	ERR_ARG_COUNT_RANGE     ErrorFormat = "function %q expects %d to %d arguments. got=%d"
+	ERR_ARG_COUNT_MINIMUM   ErrorFormat = "function %q expects at least %d arguments. got=%d"
+	ERR_SPREAD_NOT_ARRAY    ErrorFormat = "cannot spread value of type: %s"
+	ERR_BUILTIN_TYPE_ERROR  ErrorFormat = "argument %d of call to builtin %q expects type %s, got %s"
+	ERR_METHOD_TYPE_ERROR   ErrorFormat = "argument %d of call to method %q expects type %s, got %s"
+	ERR_INDEX_UNSUPPORTED   ErrorFormat = "index operator not supported: %s[%s]"
+	ERR_PROPERTY_UNKNOWN    ErrorFormat = "cannot access property %q of type: %s"
+	ERR_UNHASHABLE          ErrorFormat = "unusable as hash key: %s"
+	ERR_DIVISION_BY_ZERO    ErrorFormat = "division by zero: %d / 0"
+	ERR_UNQUOTE_UNSUPPORTED ErrorFormat = "cannot unquote value of type: %s"
+	ERR_MACRO_NOT_DEFINED   ErrorFormat = "macro literals can only be bound by top-level let statements"
+	ERR_IMPORT_FAILED       ErrorFormat = "cannot import %q: %s"
+	ERR_IMPORT_CYCLE        ErrorFormat = "import cycle: %s"
+	ERR_EXPORT_UNKNOWN      ErrorFormat = "module %q has no export %q"
+
+	ERR_CALL_DEPTH_EXCEEDED      ErrorFormat = "maximum call depth %d exceeded"
+	ERR_STEP_LIMIT_EXCEEDED      ErrorFormat = "maximum number of evaluation steps %d exceeded"
+	ERR_COLLECTION_SIZE_EXCEEDED ErrorFormat = "maximum collection size %d exceeded. got=%d"
+	ERR_MEMORY_LIMIT_EXCEEDED    ErrorFormat = "maximum allocation of %d bytes exceeded"
+	ERR_TIMEOUT                  ErrorFormat = "evaluation timed out"
+	ERR_CANCELED                 ErrorFormat = "evaluation canceled"
+)
+
+// Keys of the hash that caught errors are bound to in catch-branches
+const (
+	ERROR_KEY_MESSAGE = "message"
+	ERROR_KEY_KIND    = "kind"
+	ERROR_KEY_LINE    = "line"
+	ERROR_KEY_COLUMN  = "column"
+	ERROR_KEY_VALUE   = "value"
 )
 
 var (
@@ -28,6 +62,40 @@ var (
 
 	// FALSY_VALUES is a list of all object values considered falsy in Monkey
 	FALSY_VALUES = []object.Object{NULL, FALSE}
+
+	// O_TAIL_CALL is the type of the internal tailCall object
+	O_TAIL_CALL object.ObjectType = "@tail_call@"
+
+	// errorKinds maps every error format to the kind of the errors created from it
+	errorKinds = map[ErrorFormat]object.ErrorKind{
+		ERR_PREFIX_UNKNOWN:      object.K_TYPE,
+		ERR_INFIX_UNKNOWN:       object.K_TYPE,
+		ERR_INFIX_MISMATCH:      object.K_TYPE,
+		ERR_IDENTIFIER_UNKNOWN:  object.K_REFERENCE,
+		ERR_NOT_A_FUNCTION:      object.K_TYPE,
+		ERR_ARG_COUNT_MISMATCH:  object.K_ARGUMENT,
+		ERR_ARG_COUNT_RANGE:     object.K_ARGUMENT,
+		ERR_ARG_COUNT_MINIMUM:   object.K_ARGUMENT,
+		ERR_SPREAD_NOT_ARRAY:    object.K_TYPE,
+		ERR_BUILTIN_TYPE_ERROR:  object.K_ARGUMENT,
+		ERR_METHOD_TYPE_ERROR:   object.K_ARGUMENT,
+		ERR_INDEX_UNSUPPORTED:   object.K_TYPE,
+		ERR_PROPERTY_UNKNOWN:    object.K_TYPE,
+		ERR_UNHASHABLE:          object.K_TYPE,
+		ERR_DIVISION_BY_ZERO:    object.K_ARITHMETIC,
+		ERR_UNQUOTE_UNSUPPORTED: object.K_TYPE,
+		ERR_MACRO_NOT_DEFINED:   object.K_TYPE,
+		ERR_IMPORT_FAILED:       object.K_IMPORT,
+		ERR_IMPORT_CYCLE:        object.K_IMPORT,
+		ERR_EXPORT_UNKNOWN:      object.K_REFERENCE,
+
+		ERR_CALL_DEPTH_EXCEEDED:      object.K_RESOURCE,
+		ERR_STEP_LIMIT_EXCEEDED:      object.K_RESOURCE,
+		ERR_COLLECTION_SIZE_EXCEEDED: object.K_RESOURCE,
+		ERR_MEMORY_LIMIT_EXCEEDED:    object.K_RESOURCE,
+		ERR_TIMEOUT:                  object.K_RESOURCE,
+		ERR_CANCELED:                 object.K_CANCELED,
+	}
 )
 
 // Functions
@@ -44,7 +112,14 @@ func isTruthy(obj object.Object) bool {
 }
 
 func newError(format ErrorFormat, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(string(format), a...)}
+	return &object.Error{Message: fmt.Sprintf(string(format), a...), Kind: errorKinds[format]}
+}
+
+// newFatalError creates an error that cannot be caught by try-catch expressions.
+func newFatalError(format ErrorFormat, a ...interface{}) *object.Error {
+	err := newError(format, a...)
+	err.Fatal = true
+	return err
 }
 
 func isError(obj object.Object) bool {
@@ -54,27 +129,161 @@ func isError(obj object.Object) bool {
 	return false
 }
 
+// isAbrupt reports whether the evaluation of an expression was cut short by an error or a return statement within it,
+// in which case the result has to be passed on instead of being used as value.
+func isAbrupt(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.O_ERROR || obj.Type() == object.O_RETURN_VALUE
+	}
+	return false
+}
+
+// Eval evaluates the given node in the given environment using a new Evaluator with default limits.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
+
+// New returns an Evaluator with the default limits.
+func New() *Evaluator {
+	return &Evaluator{MaxCallDepth: DEFAULT_MAX_CALL_DEPTH, MaxTailCalls: DEFAULT_MAX_TAIL_CALLS}
+}
+
+// Eval evaluates the given node in the given environment.
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	return e.EvalContext(context.Background(), node, env)
+}
+
+// EvalContext evaluates the given node in the given environment.
+// The evaluation is aborted with a fatal error when the context is done or any limit of the Evaluator is exceeded.
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	if e.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.MaxDuration)
+		defer cancel()
+	}
+
+	e.ctx = ctx
+	e.depth = 0
+	e.steps = 0
+	e.allocated = 0
+
+	if program, ok := node.(*ast.Program); ok {
+		e.resolve(program)
+	}
+
+	if err := e.checkContext(); err != nil {
+		return err
+	}
+	return e.eval(node, env)
+}
+
+// resolve replaces the bindings and scopes of the Evaluator with the ones of the program.
+// Identifiers of previously evaluated programs, like the bodies of functions defined by them, are looked up by name.
+func (e *Evaluator) resolve(program *ast.Program) {
+	if e.unresolved {
+		e.bindings, e.scopes = nil, nil
+		return
+	}
+	resolution := resolver.Resolve(program)
+	e.bindings = resolution.Bindings
+	e.scopes = resolution.Scopes
+}
+
+// newEnvironment creates an environment enclosed by outer, which stores the bindings of the scope in slots if it was resolved.
+func (e *Evaluator) newEnvironment(outer *object.Environment, scope *resolver.Scope) *object.Environment {
+	if scope == nil {
+		return object.NewEnclosedEnvironment(outer)
+	}
+	return object.NewSlotEnvironment(outer, scope.Names)
+}
+
+// setBinding binds the value to the identifier declaring a binding, in its slot if it was resolved.
+func (e *Evaluator) setBinding(ident *ast.Identifier, val object.Object, env *object.Environment) {
+	if binding, ok := e.bindings[ident]; ok {
+		env.SetAt(binding.Slot, ident.Value, val)
+		return
+	}
+	env.Set(ident.Value, val)
+}
+
+// eval counts the evaluation step and evaluates the node.
+// Errors resulting from the evaluation are annotated with the position of the innermost node that produced them.
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
+	result, _ := e.evalChain(node, env)
+	return result
+}
+
+// evalChain evaluates the node, which may be a link of a chain of index, property and call expressions like "a?.b[c](d)".
+// An optional link that finds null skips all following links up to the end of the chain or the enclosing parentheses,
+// so that the whole chain evaluates to null. It reports whether that happened.
+func (e *Evaluator) evalChain(node ast.Node, env *object.Environment) (object.Object, bool) {
+	if err := e.step(); err != nil {
+		if node != nil {
+			err.Position = node.Pos()
+		}
+		return err, false
+	}
+
+	var result object.Object
+	skipped := false
+	switch node := node.(type) {
+	case *ast.IndexExpression:
+		result, skipped = e.evalIndexLink(node, env)
+	case *ast.PropertyExpression:
+		result, skipped = e.evalPropertyLink(node, env)
+	case *ast.CallExpression:
+		result, skipped = e.evalCallLink(node, env)
+	default:
+		result = e.evalNode(node, env)
+	}
+	if err, ok := result.(*object.Error); ok && !err.Position.IsValid() && node != nil {
+		err.Position = node.Pos()
+	}
+	return result, skipped && !ast.IsGrouped(node)
+}
+
+func (e *Evaluator) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// * Statements:
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		return e.evalProgram(node.Statements, env)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node.Statements, env)
+		return e.evalBlockStatement(node.Statements, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		val := e.eval(node.Expression, env)
+		// * a named function literal in statement position declares the function
+		if fnLit, ok := node.Expression.(*ast.FunctionLiteral); ok && fnLit.Name != nil {
+			e.setBinding(fnLit.Name, val, env)
+		}
+		return val
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		val := e.eval(node.ReturnValue, env)
+		if isAbrupt(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.ThrowStatement:
+		val := e.eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+		return errorFromObject(val)
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		val := e.eval(node.Value, env)
+		if isAbrupt(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		// * anonymous function literals are named after the identifier they are bound to
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+				fn.Name = node.Name.Value
+			}
+		}
+		e.setBinding(node.Name, val, env)
+	case *ast.ImportStatement:
+		return e.evalImportStatement(node, env)
+	case *ast.ExportStatement:
+		return e.eval(node.Statement, env)
 
 	// * Literal expressions:
 	case *ast.BooleanLiteral:
@@ -82,43 +291,63 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		return e.allocate(&object.String{Value: node.Value})
+	case *ast.NullLiteral:
+		return NULL
+	case *ast.ArrayLiteral:
+		elements, err := e.evalExpressions(node.Elements, env)
+		if err != nil {
+			return err
+		}
+		if err := e.checkCollectionSize(len(elements)); err != nil {
+			return err
+		}
+		return e.allocate(&object.Array{Elements: elements})
+	case *ast.HashLiteral:
+		return e.evalHashLiteral(node, env)
 	case *ast.FunctionLiteral:
-		params := node.Parameters
-		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		fn := &object.Function{Parameters: node.Parameters, Defaults: node.Defaults, Rest: node.Rest, Body: node.Body, Env: env}
+		if node.Name != nil {
+			fn.Name = node.Name.Value
+		}
+		if scope, ok := e.scopes[node]; ok {
+			fn.SlotNames = scope.Names
+		}
+		return fn
+	case *ast.MacroLiteral:
+		// * macro definitions are removed from the program by DefineMacros
+		return newError(ERR_MACRO_NOT_DEFINED)
 
 	// * Operator expressions:
 	case *ast.PrefixExpression:
-		operand := Eval(node.Right, env)
-		if isError(operand) {
+		operand := e.eval(node.Right, env)
+		if isAbrupt(operand) {
 			return operand
 		}
 		return evalPrefixExpression(node.Operator, operand)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		if node.Operator == "??" {
+			return e.evalNullishExpression(node, env)
+		}
+		left := e.eval(node.Left, env)
+		if isAbrupt(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.eval(node.Right, env)
+		if isAbrupt(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return e.allocate(evalInfixExpression(node.Operator, left, right))
 
 	// * Control flow expressions:
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
+	case *ast.TryExpression:
+		return e.evalTryExpression(node, env)
 
-	// * Identifiers, function calls:
+	// * Identifiers (index and property access and function calls are evaluated by evalChain):
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
-	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
-		}
-		return evalCallExpression(function, node.Arguments, env)
+		return e.evalIdentifier(node, env)
 	}
 
 	return nil
@@ -131,11 +360,11 @@ func nativeBooleanToObject(input bool) *object.Boolean {
 	return FALSE
 }
 
-func evalProgram(statements []ast.Statement, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(statements []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, stmt := range statements {
-		result = Eval(stmt, env)
+		result = e.eval(stmt, env)
 
 		// * return early, if result is an object.ReturnValue or an object.Error
 		switch result := result.(type) {
@@ -149,11 +378,13 @@ func evalProgram(statements []ast.Statement, env *object.Environment) object.Obj
 	return result
 }
 
-func evalBlockStatement(statements []ast.Statement, env *object.Environment) object.Object {
+// evalBlockStatement evaluates the statements of a block.
+// Blocks that are empty or do not end in an expression statement evaluate to null.
+func (e *Evaluator) evalBlockStatement(statements []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, stmt := range statements {
-		result = Eval(stmt, env)
+		result = e.eval(stmt, env)
 
 		if result != nil {
 			// * return early, if result type is object.O_RETURN_VALUE or object.O_ERRIR
@@ -163,6 +394,9 @@ func evalBlockStatement(statements []ast.Statement, env *object.Environment) obj
 		}
 	}
 
+	if result == nil {
+		return NULL
+	}
 	return result
 }
 
@@ -196,6 +430,11 @@ func evalDashOperatorExpression(operand object.Object) object.Object {
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
 	// * need to switch on both the type of left and right
+	// * null can be compared against values of any type
+	case operator == "==" && (left == NULL || right == NULL):
+		return nativeBooleanToObject(left == right)
+	case operator == "!=" && (left == NULL || right == NULL):
+		return nativeBooleanToObject(left != right)
 	case left.Type() != right.Type():
 		return newError(ERR_INFIX_MISMATCH, left.Type(), operator, right.Type())
 	case left.Type() == object.O_INTEGER && right.Type() == object.O_INTEGER:
@@ -227,6 +466,9 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		newInt = leftInt * rightInt
 	case "/":
+		if rightInt == 0 {
+			return newError(ERR_DIVISION_BY_ZERO, leftInt)
+		}
 		newInt = leftInt / rightInt
 	case "==":
 		return nativeBooleanToObject(leftInt == rightInt)
@@ -257,22 +499,198 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	return &object.String{Value: newString}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+// evalNullishExpression returns the left operand, unless it is null.
+// Only in that case the right operand is evaluated and returned.
+func (e *Evaluator) evalNullishExpression(ie *ast.InfixExpression, env *object.Environment) object.Object {
+	left := e.eval(ie.Left, env)
+	if isError(left) || left != NULL {
+		return left
+	}
+	return e.eval(ie.Right, env)
+}
+
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for _, pair := range node.Pairs {
+		key := e.eval(pair.Key, env)
+		if isAbrupt(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError(ERR_UNHASHABLE, key.Type())
+		}
+
+		value := e.eval(pair.Value, env)
+		if isAbrupt(value) {
+			return value
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+		if err := e.checkCollectionSize(len(pairs)); err != nil {
+			return err
+		}
+	}
+
+	return e.allocate(&object.Hash{Pairs: pairs})
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.O_ARRAY && index.Type() == object.O_INTEGER:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.O_HASH:
+		return evalHashIndexExpression(left, index)
+	}
+	return newError(ERR_INDEX_UNSUPPORTED, left.Type(), index.Type())
+}
+
+// evalArrayIndexExpression returns the element at the given index or null if the index is out of bounds.
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	idx := index.(*object.Integer).Value
+
+	if idx < 0 || idx >= int64(len(elements)) {
+		return NULL
+	}
+	return elements[idx]
+}
+
+// evalHashIndexExpression returns the value stored for the given key or null if there is none.
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError(ERR_UNHASHABLE, index.Type())
+	}
+
+	pair, ok := hash.(*object.Hash).Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+	return pair.Value
+}
+
+// evalPropertyExpression looks up the given property name as export of a module, as string key of a hash
+// or as method registered for the type of the object, in this order.
+// Missing properties of hashes are null, just like missing keys.
+func evalPropertyExpression(obj object.Object, property string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Module:
+		return evalModuleProperty(obj, property)
+	case *object.Hash:
+		if pair, ok := obj.Pairs[(&object.String{Value: property}).HashKey()]; ok {
+			return pair.Value
+		}
+	}
+
+	if method, ok := lookupMethod(obj, property); ok {
+		return method
+	}
+	if obj.Type() == object.O_HASH {
+		return NULL
+	}
+	return newError(ERR_PROPERTY_UNKNOWN, property, obj.Type())
+}
+
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.eval(ie.Condition, env)
+	if isAbrupt(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Then, env)
+		return e.eval(ie.Then, env)
 	} else if ie.Otherwise != nil {
-		return Eval(ie.Otherwise, env)
+		return e.eval(ie.Otherwise, env)
 	}
 
 	return NULL
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+// evalTryExpression evaluates the try-branch and, if it results in a non-fatal error, the catch-branch.
+// The finally-branch is evaluated afterwards, unless a fatal error occurred.
+// An error or return value of the finally-branch takes precedence over the previous result.
+func (e *Evaluator) evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := e.eval(te.Block, env)
+
+	if err, ok := result.(*object.Error); ok && !err.Fatal && te.Catch != nil {
+		catchEnv := e.newEnvironment(env, e.scopes[te])
+		catchEnv.Set(te.CatchParameter.Value, errorToHash(err))
+		result = e.eval(te.Catch, catchEnv)
+	}
+
+	if err, ok := result.(*object.Error); ok && err.Fatal {
+		return result
+	}
+
+	if te.Finally != nil {
+		finally := e.eval(te.Finally, env)
+		if finally != nil && (isError(finally) || finally.Type() == object.O_RETURN_VALUE) {
+			return finally
+		}
+	}
+
+	return result
+}
+
+// errorToHash converts an error into the hash that is exposed to Monkey code in catch-branches.
+func errorToHash(err *object.Error) *object.Hash {
+	value := err.Value
+	if value == nil {
+		value = NULL
+	}
+
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair), Error: err}
+	for key, val := range map[string]object.Object{
+		ERROR_KEY_MESSAGE: &object.String{Value: err.Message},
+		ERROR_KEY_KIND:    &object.String{Value: string(err.Kind)},
+		ERROR_KEY_LINE:    &object.Integer{Value: int64(err.Position.Line)},
+		ERROR_KEY_COLUMN:  &object.Integer{Value: int64(err.Position.Column)},
+		ERROR_KEY_VALUE:   value,
+	} {
+		keyObj := &object.String{Value: key}
+		hash.Pairs[keyObj.HashKey()] = object.HashPair{Key: keyObj, Value: val}
+	}
+	return hash
+}
+
+// errorFromObject creates the error raised by throwing the given value.
+// Throwing the hash of a catch-branch parameter, as created by errorToHash, rethrows the error it describes.
+// Other hashes are thrown as value, taking the message and kind of the error from their string "message" and "kind".
+func errorFromObject(val object.Object) *object.Error {
+	err := &object.Error{Message: val.Inspect(), Kind: object.K_THROWN, Value: val}
+
+	switch val := val.(type) {
+	case *object.String:
+		err.Message = val.Value
+	case *object.Hash:
+		if val.Error != nil {
+			return &object.Error{Message: val.Error.Message, Kind: val.Error.Kind, Value: val.Error.Value}
+		}
+		if message, ok := evalHashIndexExpression(val, &object.String{Value: ERROR_KEY_MESSAGE}).(*object.String); ok {
+			err.Message = message.Value
+		}
+		if kind, ok := evalHashIndexExpression(val, &object.String{Value: ERROR_KEY_KIND}).(*object.String); ok {
+			err.Kind = object.ErrorKind(kind.Value)
+		}
+	}
+
+	return err
+}
+
+// evalIdentifier looks up the binding of the identifier.
+// Resolved identifiers are looked up directly in the environment defining them.
+// If the binding is not set there, because the let statement defining it has not been evaluated yet,
+// the identifier is looked up like an unresolved one, by searching all environments.
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if binding, ok := e.bindings[node]; ok {
+		if val, ok := env.GetAt(binding.Depth, binding.Slot, node.Value); ok {
+			return val
+		}
+	}
+
 	val, ok := env.Get(node.Value)
 	if ok {
 		return val
@@ -286,64 +704,391 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError(ERR_IDENTIFIER_UNKNOWN, node.Value)
 }
 
-func evalCallExpression(function object.Object, args []ast.Expression, env *object.Environment) object.Object {
-	// define this as local function to avoid duplicate code in switch-case branches
-	parseArguments := func(args ...ast.Expression) (params []object.Object, err *object.Object) {
-		// parse provided argument expressions for parameters
-		params = []object.Object{}
-		for _, arg := range args {
-			param := Eval(arg, env)
-			if isError(param) {
-				return nil, &param
-			}
-			params = append(params, param)
+// evalExpressions evaluates the given expressions in order, expanding the elements of spread arrays in place.
+// If any of them evaluates to an error or a return value, evaluation stops and that result is returned.
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) ([]object.Object, object.Object) {
+	result := []object.Object{}
+
+	for _, exp := range exps {
+		spread, isSpread := exp.(*ast.SpreadExpression)
+		if isSpread {
+			exp = spread.Value
 		}
-		return
+
+		evaluated := e.eval(exp, env)
+		if isAbrupt(evaluated) {
+			return nil, evaluated
+		}
+
+		if !isSpread {
+			result = append(result, evaluated)
+			continue
+		}
+
+		array, ok := evaluated.(*object.Array)
+		if !ok {
+			err := newError(ERR_SPREAD_NOT_ARRAY, evaluated.Type())
+			err.Position = spread.Pos()
+			return nil, err
+		}
+		result = append(result, array.Elements...)
 	}
 
-	switch fn := function.(type) {
-	case *object.Function:
-		args, err := parseArguments(args...)
-		if err != nil {
-			return *err
+	return result, nil
+}
+
+func (e *Evaluator) evalCallExpression(node *ast.CallExpression, function object.Object, env *object.Environment) object.Object {
+	switch function.(type) {
+	case *object.Function, *object.Builtin, *object.BoundMethod:
+	default:
+		return newError(ERR_NOT_A_FUNCTION, function.Type())
+	}
+
+	args, err := e.evalExpressions(node.Arguments, env)
+	if err != nil {
+		return err
+	}
+
+	return e.applyFunction(node, function, args)
+}
+
+// applyFunction calls the function with the given arguments.
+//
+// Calls in tail position of the function body are not evaluated recursively, but returned as tailCall,
+// which is then applied in the same loop iteration, so that tail recursion runs in constant Go stack.
+// All tail calls share the stack frame of the original call, which is named after the most recently applied function.
+// Tail calls of functions are limited by MaxTailCalls, so that unbounded tail recursion fails like unbounded recursion.
+func (e *Evaluator) applyFunction(call *ast.CallExpression, function object.Object, args []object.Object) object.Object {
+	if e.depth >= e.maxCallDepth() {
+		return newFatalError(ERR_CALL_DEPTH_EXCEEDED, e.maxCallDepth())
+	}
+	e.depth++
+	defer func() { e.depth-- }()
+
+	// name of the function currently occupying the stack frame, empty until a function has been entered
+	frame := ""
+	// the original call or the most recent tail call
+	site := call
+	// number of tail calls of functions applied so far
+	tailCalls := 0
+
+	for {
+		var result object.Object
+
+		switch fn := function.(type) {
+		case *object.Function:
+			if err := checkArgumentCount(fn, len(args)); err != nil {
+				result = err
+				break
+			}
+
+			frame = fn.DisplayName()
+			extendedEnv, err := e.extendFunctionEnvironment(fn, args)
+			if err != nil {
+				result = err
+				break
+			}
+
+			result = e.evalTailStatements(fn.Body.Statements, extendedEnv, true)
+
+		case *object.Builtin:
+			frame = fn.Name
+			result = fn.Fn(args...)
+
+		case *object.BoundMethod:
+			frame = fn.Name
+			// * functions called by the method are called at the same site as the method
+			rt := &object.Runtime{
+				Call: func(function object.Object, args ...object.Object) object.Object {
+					return e.applyFunction(call, function, args)
+				},
+				Allocate: e.allocateValue,
+			}
+			result = fn.Fn(rt, fn.Receiver, args...)
+
+		default:
+			result = newError(ERR_NOT_A_FUNCTION, function.Type())
+		}
+
+		switch res := result.(type) {
+		case *tailCall:
+			if _, ok := res.function.(*object.Function); ok {
+				if tailCalls >= e.maxTailCalls() {
+					err := newFatalError(ERR_CALL_DEPTH_EXCEEDED, e.maxTailCalls())
+					err.Position = res.call.Pos()
+					err.Stack = append(err.Stack, object.Frame{Function: frame, Position: call.Pos()})
+					return err
+				}
+				tailCalls++
+			}
+			function, args, site = res.function, res.args, res.call
+			continue
+		case *object.ReturnValue:
+			return res.Value
+		case *object.Error:
+			if !res.Position.IsValid() {
+				// * errors returned by builtins and argument checks occur at the call site
+				res.Position = site.Pos()
+			}
+			if frame != "" {
+				res.Stack = append(res.Stack, object.Frame{Function: frame, Position: call.Pos()})
+			}
 		}
 
-		if len(args) != len(fn.Parameters) {
-			return newError(ERR_ARG_COUNT_MISMATCH, len(fn.Parameters), len(args))
+		return result
+	}
+}
+
+// evalTailStatements evaluates the statements of a function body or of a branch of an if-expression within it.
+// Return values are in tail position and so is the last statement, if lastIsTail is true.
+// Like blocks, bodies that are empty or do not end in an expression statement evaluate to null.
+func (e *Evaluator) evalTailStatements(statements []ast.Statement, env *object.Environment, lastIsTail bool) object.Object {
+	var result object.Object
+
+	for index, stmt := range statements {
+		tail := lastIsTail && index == len(statements)-1
+
+		switch stmt := stmt.(type) {
+		case *ast.ReturnStatement:
+			val := e.evalTailExpression(stmt.ReturnValue, env, true)
+			if isAbrupt(val) {
+				return val
+			}
+			if _, ok := val.(*tailCall); ok {
+				return val
+			}
+			return &object.ReturnValue{Value: val}
+		case *ast.ExpressionStatement:
+			switch stmt.Expression.(type) {
+			case *ast.CallExpression, *ast.IfExpression:
+				result = e.evalTailExpression(stmt.Expression, env, tail)
+			default:
+				result = e.eval(stmt, env)
+			}
+		default:
+			result = e.eval(stmt, env)
 		}
 
-		extendedEnv := extendFunctionEnvironment(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		if returnValue, ok := evaluated.(*object.ReturnValue); ok {
-			return returnValue.Value
+		if result != nil {
+			// * return early, if the function body is exited
+			if _, ok := result.(*tailCall); ok || result.Type() == object.O_RETURN_VALUE || isError(result) {
+				return result
+			}
 		}
+	}
 
-		return evaluated
+	if result == nil {
+		return NULL
+	}
+	return result
+}
 
-	case *object.Builtin:
-		args, err := parseArguments(args...)
+// evalTailExpression evaluates the expression, returning a tailCall instead of applying a call expression if tail is true.
+// The branches of if-expressions are searched for tail calls as well.
+func (e *Evaluator) evalTailExpression(exp ast.Expression, env *object.Environment, tail bool) object.Object {
+	switch exp := exp.(type) {
+	case *ast.CallExpression:
+		if !tail || isCallOf(exp, QUOTE) {
+			break
+		}
+		function, skipped := e.evalFunction(exp, env)
+		if skipped || isAbrupt(function) {
+			return function
+		}
+		switch function.(type) {
+		case *object.Function, *object.Builtin, *object.BoundMethod:
+		default:
+			return e.eval(exp, env)
+		}
+		args, err := e.evalExpressions(exp.Arguments, env)
 		if err != nil {
-			return *err
+			return err
 		}
+		return &tailCall{function: function, args: args, call: exp}
 
-		return fn.Fn(args...)
+	case *ast.IfExpression:
+		condition := e.eval(exp.Condition, env)
+		if isAbrupt(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return e.evalTailStatements(exp.Then.Statements, env, tail)
+		} else if exp.Otherwise != nil {
+			return e.evalTailStatements(exp.Otherwise.Statements, env, tail)
+		}
+		return NULL
+	}
 
-	default:
-		return newError(ERR_NOT_A_FUNCTION, fn.Type())
+	return e.eval(exp, env)
+}
+
+// evalIndexLink evaluates the index expression as link of a chain, see evalChain.
+func (e *Evaluator) evalIndexLink(ie *ast.IndexExpression, env *object.Environment) (object.Object, bool) {
+	left, skipped := e.evalChain(ie.Left, env)
+	if skipped || isAbrupt(left) {
+		return left, skipped
+	}
+	if ie.Optional && left == NULL {
+		return NULL, true
+	}
+	index := e.eval(ie.Index, env)
+	if isAbrupt(index) {
+		return index, false
 	}
+	return evalIndexExpression(left, index), false
+}
 
+// evalPropertyLink evaluates the property expression as link of a chain, see evalChain.
+func (e *Evaluator) evalPropertyLink(pe *ast.PropertyExpression, env *object.Environment) (object.Object, bool) {
+	obj, skipped := e.evalChain(pe.Object, env)
+	if skipped || isAbrupt(obj) {
+		return obj, skipped
+	}
+	if pe.Optional && obj == NULL {
+		return NULL, true
+	}
+	return evalPropertyExpression(obj, pe.Property.Value), false
 }
 
-func extendFunctionEnvironment(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+// evalCallLink evaluates the call expression as link of a chain, see evalChain.
+func (e *Evaluator) evalCallLink(ce *ast.CallExpression, env *object.Environment) (object.Object, bool) {
+	if isCallOf(ce, QUOTE) {
+		return e.quote(ce, env), false
+	}
+	function, skipped := e.evalFunction(ce, env)
+	if skipped || isAbrupt(function) {
+		return function, skipped
+	}
+	return e.evalCallExpression(ce, function, env), false
+}
+
+// evalFunction evaluates the function of the call as previous link of its chain.
+// Calls written with the pipeline operator start a chain of their own.
+func (e *Evaluator) evalFunction(call *ast.CallExpression, env *object.Environment) (object.Object, bool) {
+	if call.Token.Type == token.PIPE {
+		return e.eval(call.Function, env), false
+	}
+	return e.evalChain(call.Function, env)
+}
+
+// checkArgumentCount returns an error if the function cannot be called with the given number of arguments.
+func checkArgumentCount(fn *object.Function, argc int) *object.Error {
+	required := 0
+	for required < len(fn.Parameters) && (required >= len(fn.Defaults) || fn.Defaults[required] == nil) {
+		required++
+	}
+	optional := len(fn.Parameters) - required
+
+	switch {
+	case fn.Rest != nil && argc < required:
+		return newError(ERR_ARG_COUNT_MINIMUM, fn.DisplayName(), required, argc)
+	case fn.Rest == nil && optional == 0 && argc != required:
+		return newError(ERR_ARG_COUNT_MISMATCH, fn.DisplayName(), required, argc)
+	case fn.Rest == nil && (argc < required || argc > len(fn.Parameters)):
+		return newError(ERR_ARG_COUNT_RANGE, fn.DisplayName(), required, len(fn.Parameters), argc)
+	}
+	return nil
+}
+
+// extendFunctionEnvironment binds the arguments to the parameters of the function in a new environment enclosed by the function's environment.
+// Default values of missing arguments are evaluated in the new environment, so they can refer to preceding parameters.
+// Remaining arguments are collected into an array bound to the rest parameter.
+func (e *Evaluator) extendFunctionEnvironment(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
+	var env *object.Environment
+	if fn.SlotNames != nil {
+		env = object.NewSlotEnvironment(fn.Env, fn.SlotNames)
+	} else {
+		env = object.NewEnclosedEnvironment(fn.Env)
+	}
 
 	for paramIndex, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIndex])
+		if paramIndex < len(args) {
+			env.Set(param.Value, args[paramIndex])
+			continue
+		}
+
+		def := e.eval(fn.Defaults[paramIndex], env)
+		if isAbrupt(def) {
+			return nil, def
+		}
+		env.Set(param.Value, def)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		if err := e.checkCollectionSize(len(rest)); err != nil {
+			return nil, err
+		}
+		restArray := e.allocate(&object.Array{Elements: rest})
+		if isError(restArray) {
+			return nil, restArray
+		}
+		env.Set(fn.Rest.Value, restArray)
 	}
 
-	return env
+	return env, nil
 }
 
 /// Types
 
 type ErrorFormat string
+
+// An Evaluator evaluates Monkey programs while enforcing limits on their resource usage.
+// Exceeding any of the limits aborts the evaluation with a fatal error of kind object.K_RESOURCE.
+// An Evaluator must not be used for multiple evaluations concurrently.
+type Evaluator struct {
+	// MaxCallDepth is the maximum number of nested function calls.
+	// A value of zero means DEFAULT_MAX_CALL_DEPTH.
+	MaxCallDepth int
+	// MaxTailCalls is the maximum number of consecutive tail calls of functions within a single call.
+	// Tail calls replace the calling function instead of nesting and so do not count toward MaxCallDepth,
+	// but exceeding this limit is reported as exceeding the maximum call depth as well.
+	// A value of zero means DEFAULT_MAX_TAIL_CALLS.
+	MaxTailCalls int
+	// MaxSteps is the maximum number of evaluated AST nodes, zero means no limit.
+	MaxSteps int64
+	// MaxDuration is the maximum wall-clock duration of an evaluation, zero means no limit.
+	MaxDuration time.Duration
+	// MaxCollectionSize is the maximum number of elements of a single array or hash, zero means no limit.
+	MaxCollectionSize int
+	// MaxAllocatedBytes is the approximate number of bytes that strings, arrays and hashes created during an evaluation
+	// may occupy in total, zero means no limit.
+	MaxAllocatedBytes int64
+	// Loader loads the modules imported by evaluated programs, nil means a module.Loader without search paths.
+	Loader *module.Loader
+	// Path is the source file of the evaluated program, which relative import paths are resolved against.
+	// It is empty for programs that are not read from a file.
+	Path string
+
+	// context of the current evaluation
+	ctx context.Context
+	// current number of nested function calls
+	depth int
+	// number of evaluated AST nodes in the current evaluation
+	steps int64
+	// approximate number of bytes allocated in the current evaluation
+	allocated int64
+	// the bindings of the identifiers and the scopes of the evaluated program
+	bindings map[*ast.Identifier]resolver.Binding
+	scopes   map[ast.Node]*resolver.Scope
+	// whether programs are evaluated without resolving them, looking up all identifiers by name
+	unresolved bool
+	// the evaluated modules by path, which are shared by all evaluations
+	modules map[string]*object.Module
+	// the paths of the modules being evaluated, starting with the program, to detect import cycles
+	importing []string
+}
+
+// tailCall is the result of a call expression in tail position, which is applied by the caller's applyFunction loop.
+// It never escapes the evaluation of a function body.
+type tailCall struct {
+	function object.Object
+	args     []object.Object
+	call     *ast.CallExpression
+}
+
+func (tc *tailCall) Type() object.ObjectType { return O_TAIL_CALL }
+func (tc *tailCall) Inspect() string         { return fmt.Sprintf("tail call of %s", tc.call) }
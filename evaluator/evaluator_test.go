@@ -2,8 +2,11 @@ package evaluator
 
 import (
 	"fmt"
+	"runtime/debug"
+	"strings"
 	"testing"
 
+	"github.com/smalldevshima/go-monkey/benchmarks"
 	"github.com/smalldevshima/go-monkey/lexer"
 	"github.com/smalldevshima/go-monkey/object"
 	"github.com/smalldevshima/go-monkey/parser"
@@ -207,6 +210,27 @@ func TestReturnStatements(t *testing.T) {
 			} ()`,
 			987,
 		},
+
+		{"within-expression/let/if", "let f = fn() { let r = if (true) { return 4 }; 6 }; f()", 4},
+		{"within-expression/let/try", "let f = fn() { let r = try { return 4 } catch (e) { 0 }; 6 }; f()", 4},
+		{"within-expression/let/finally", "let f = fn() { let r = try { 1 } finally { return 3 }; 6 }; f()", 3},
+		{"within-expression/let/program", "let r = if (true) { return 4 }; 6", 4},
+		{"within-expression/array", "let f = fn() { [1, if (true) { return 4 }]; 6 }; f()", 4},
+		{"within-expression/array/spread", "let f = fn() { [...if (true) { return 4 }]; 6 }; f()", 4},
+		{"within-expression/hash/key", "let f = fn() { {if (true) { return 4 }: 1}; 6 }; f()", 4},
+		{"within-expression/hash/value", "let f = fn() { {1: if (true) { return 4 }}; 6 }; f()", 4},
+		{"within-expression/argument/builtin", "let f = fn() { len(if (true) { return 4 }); 6 }; f()", 4},
+		{"within-expression/argument/function", "let g = fn(x) { 5 }; let f = fn() { g(if (true) { return 4 }); 6 }; f()", 4},
+		{"within-expression/argument/tail-call", "let g = fn(x) { 5 }; let f = fn() { g(if (true) { return 4 }) }; f()", 4},
+		{"within-expression/argument/default", "let f = fn(x = if (true) { return 4 }) { 6 }; f()", 4},
+		{"within-expression/prefix", "let f = fn() { -if (true) { return 4 }; 6 }; f()", 4},
+		{"within-expression/infix", "let f = fn() { 1 + if (true) { return 4 }; 6 }; f()", 4},
+		{"within-expression/condition", "let f = fn() { if (if (true) { return 4 }) { 5 }; 6 }; f()", 4},
+		{"within-expression/index", "let f = fn() { [1][if (true) { return 4 }]; 6 }; f()", 4},
+		{"within-expression/property", "let f = fn() { (if (true) { return 4 }).x; 6 }; f()", 4},
+		{"within-expression/call", "let f = fn() { (if (true) { return 4 })(); 6 }; f()", 4},
+		{"within-expression/throw", "let f = fn() { throw if (true) { return 4 }; 6 }; f()", 4},
+		{"within-expression/return", "let f = fn() { return if (true) { return 4 } + 1; 6 }; f()", 4},
 	}
 
 	for _, test := range tests {
@@ -250,6 +274,11 @@ func TestErrorHandling(t *testing.T) {
 			"true + false;",
 			"unknown operator: @bool@ + @bool@",
 		},
+		{
+			"operator/arithmetic/division-by-zero",
+			"let zero = 0; 10 / zero",
+			"division by zero: 10 / 0",
+		},
 
 		{
 			"block/exit-early",
@@ -362,6 +391,45 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestResolvedIdentifiers(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{"outer-binding", "let x = 1; let f = fn() { fn() { x } }; f()()", 1},
+		{"read-before-let", "let x = 1; let f = fn() { let y = x; let x = 2; y + x }; f()", 3},
+		{"conditional-let/taken", "let x = 1; let f = fn(c) { if (c) { let x = 10 }; x }; f(true)", 10},
+		{"conditional-let/not-taken", "let x = 1; let f = fn(c) { if (c) { let x = 10 }; x }; f(false)", 1},
+		{"catch-parameter", "let e = 5; let r = try { throw 1 } catch (e) { e[\"value\"] }; r + e", 6},
+		{"later-global", "let f = fn() { g() }; let g = fn() { 7 }; f()", 7},
+		{"default-refers-to-parameter", "let f = fn(a, b = a * 2) { b }; f(4)", 8},
+		{"default-refers-to-later-parameter", "let b = 100; let f = fn(a, c = b, b = 1) { c }; f(1)", 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkIntegerObject(t, testEval(test.input), int64(test.expected.(int)))
+
+			evaluator := New()
+			evaluator.unresolved = true
+			unresolved := evaluator.Eval(parser.New(lexer.New(test.input)).ParseProgram(), object.NewEnvironment())
+			checkIntegerObject(t, unresolved, int64(test.expected.(int)))
+		})
+	}
+
+	t.Run("previous-programs", func(t *testing.T) {
+		env := object.NewEnvironment()
+		evaluator := New()
+		for _, input := range []string{"let x = 2;", "let f = fn(y) { fn() { x * y } };", "let x = 3; f(5)()"} {
+			result := evaluator.Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+			if input == "let x = 3; f(5)()" {
+				checkIntegerObject(t, result, 15)
+			}
+		}
+	})
+}
+
 func TestFunctionObject(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -479,6 +547,98 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestNamedFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{"declaration", "fn add(a, b) { a + b }; add(1, 2)", 3},
+		{"declaration/recursive", "fn fac(n) { if (n < 2) { 1 } else { n * fac(n - 1) } }; fac(5)", 120},
+		{"expression/not-declared", "let f = fn g() { 1 }; g", "unknown identifier: g"},
+
+		{"arity/declared", "fn add(a, b) { a + b }; add(1)", `function "add" expects 2 arguments. got=1`},
+		{"arity/inferred", "let add = fn(a, b) { a + b }; add(1, 2, 3)", `function "add" expects 2 arguments. got=3`},
+		{"arity/anonymous", "fn(a) { a }()", `function "<anonymous>" expects 1 arguments. got=0`},
+		{"arity/keeps-name", "fn add(a, b) { a + b }; let plus = add; plus(1)", `function "add" expects 2 arguments. got=1`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEval(test.input)
+			switch expected := test.expected.(type) {
+			case int:
+				checkIntegerObject(t, evaluated, int64(expected))
+			case string:
+				checkErrorObject(t, evaluated, expected)
+			}
+		})
+	}
+
+	inspectTests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"inspect/anonymous", "fn(x) { x }", "fn(x) {\nx;\n}"},
+		{"inspect/declared", "fn id(x) { x }", "fn id(x) {\nx;\n}"},
+		{"inspect/inferred", "let id = fn(x) { x }; id", "fn id(x) {\nx;\n}"},
+	}
+
+	for _, test := range inspectTests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEval(test.input)
+			if evaluated.Inspect() != test.expected {
+				t.Errorf("evaluated.Inspect is wrong.\nexpected:\n%s\ngot:\n%s", test.expected, evaluated.Inspect())
+			}
+		})
+	}
+}
+
+func TestFunctionDefaultsRestAndSpread(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{"default/used", "let f = fn(a, b = 10) { a + b }; f(1)", 11},
+		{"default/overridden", "let f = fn(a, b = 10) { a + b }; f(1, 2)", 3},
+		{"default/refers-to-parameter", "let f = fn(a, b = a * 2) { a + b }; f(3)", 9},
+		{"default/closure", "let base = 100; let f = fn(a = base) { a }; let base = 200; f()", 200},
+		{"default/evaluated-per-call", "let f = fn(a = []) { a }; f() == f()", false},
+		{"default/error", "let f = fn(a = unknown) { a }; f()", "unknown identifier: unknown"},
+
+		{"rest/empty", "let f = fn(...rest) { len(rest) }; f()", 0},
+		{"rest/collects", "let f = fn(a, ...rest) { rest[1] }; f(1, 2, 3)", 3},
+		{"rest/with-default", "let f = fn(a, b = 2, ...rest) { a + b + len(rest) }; f(1)", 3},
+
+		{"spread/call", "let add = fn(a, b, c) { a + b + c }; add(...[1, 2, 3])", 6},
+		{"spread/call-mixed", "let add = fn(a, b, c) { a + b + c }; add(1, ...[2], 3)", 6},
+		{"spread/builtin", "len(...[[1, 2]])", 2},
+		{"spread/array", "let xs = [2, 3]; [1, ...xs, 4][2]", 3},
+		{"spread/array-length", "let xs = [2, 3]; len([...xs, ...xs])", 4},
+		{"spread/not-array", "let f = fn(...rest) { rest }; f(...1)", "cannot spread value of type: @int@"},
+
+		{"arity/range", "let f = fn(a, b = 1) { a }; f()", `function "f" expects 1 to 2 arguments. got=0`},
+		{"arity/range-too-many", "let f = fn(a, b = 1) { a }; f(1, 2, 3)", `function "f" expects 1 to 2 arguments. got=3`},
+		{"arity/minimum", "let f = fn(a, ...rest) { a }; f()", `function "f" expects at least 1 arguments. got=0`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEval(test.input)
+			switch expected := test.expected.(type) {
+			case int:
+				checkIntegerObject(t, evaluated, int64(expected))
+			case bool:
+				checkBooleanObject(t, evaluated, expected)
+			case string:
+				checkErrorObject(t, evaluated, expected)
+			}
+		})
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -488,6 +648,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{"len/empty-string", `len("")`, 0},
 		{"len/non-empty-string/1", `len("four")`, 4},
 		{"len/non-empty-string/2", `len("hello world")`, 11},
+		{"len/empty-array", `len([])`, 0},
+		{"len/non-empty-array", `len([1, "two", true])`, 3},
 		{"len/wrong-type/int", `len(1)`, `argument 0 of call to builtin "len" expects type @string@, got @int@`},
 		{"len/wrong-type/bool", `len(true)`, `argument 0 of call to builtin "len" expects type @string@, got @bool@`},
 		{"len/wrong-arg-count", `len("one", "two")`, `function "len" expects 1 arguments. got=2`},
@@ -507,6 +669,577 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestNullExpressions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{"literal", "null", nil},
+		{"eq/null-null", "null == null", true},
+		{"eq/null-int", "null == 0", false},
+		{"neq/int-null", "0 != null", true},
+		{"bang", "!null", true},
+
+		{"coalesce/null", "null ?? 5", 5},
+		{"coalesce/non-null", "3 ?? 5", 3},
+		{"coalesce/false", "false ?? true", false},
+		{"coalesce/chained", "null ?? null ?? 7", 7},
+		{"coalesce/short-circuit", "1 ?? unknown", 1},
+		{"coalesce/error", "unknown ?? 1", "unknown identifier: unknown"},
+
+		{"no-value/function/empty", "let f = fn() { }; f()", nil},
+		{"no-value/function/let", "let f = fn() { let x = 1 }; f()", nil},
+		{"no-value/infix", "let f = fn() { let x = 1 }; f() + 1", "type mismatch: @null@ + @int@"},
+		{"no-value/array", "let f = fn() { }; [f()][0]", nil},
+		{"no-value/method", "(fn() {})().upper()", `cannot access property "upper" of type: @null@`},
+		{"no-value/caught", "try { let f = fn() { }; f() + 1 } catch (e) { 7 }", 7},
+		{"no-value/if", "if (true) { let x = 1 }", nil},
+		{"no-value/try", "try { } finally { }", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEval(test.input)
+			switch expected := test.expected.(type) {
+			case int:
+				checkIntegerObject(t, evaluated, int64(expected))
+			case bool:
+				checkBooleanObject(t, evaluated, expected)
+			case string:
+				checkErrorObject(t, evaluated, expected)
+			default:
+				checkNullObject(t, evaluated)
+			}
+		})
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := testEval("[1, 2 * 2, 3 + 3]")
+	array, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("evaluated is not *object.Array. got=%T: %+v", evaluated, evaluated)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("array.Elements does not contain 3 elements. got=%d", len(array.Elements))
+	}
+	checkIntegerObject(t, array.Elements[0], 1)
+	checkIntegerObject(t, array.Elements[1], 4)
+	checkIntegerObject(t, array.Elements[2], 6)
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	hash, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("evaluated is not *object.Hash. got=%T: %+v", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong number of pairs. expected=%d, got=%d", len(expected), len(hash.Pairs))
+	}
+	for expectedKey, expectedValue := range expected {
+		pair, ok := hash.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in hash.Pairs")
+			continue
+		}
+		checkIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{"array/first", "[1, 2, 3][0]", 1},
+		{"array/last", "[1, 2, 3][2]", 3},
+		{"array/expression", "let i = 1; [1, 2, 3][i + 1]", 3},
+		{"array/out-of-bounds", "[1, 2, 3][3]", nil},
+		{"array/negative", "[1, 2, 3][-1]", nil},
+
+		{"hash/string", `{"foo": 5}["foo"]`, 5},
+		{"hash/missing", `{"foo": 5}["bar"]`, nil},
+		{"hash/integer", `{5: 5}[5]`, 5},
+		{"hash/boolean", `{true: 5}[true]`, 5},
+		{"hash/unhashable", `{"foo": 5}[fn(x) { x }]`, "unusable as hash key: @function@"},
+
+		{"unsupported/null", `null[0]`, "index operator not supported: @null@[@int@]"},
+		{"unsupported/integer", `1[0]`, "index operator not supported: @int@[@int@]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEval(test.input)
+			switch expected := test.expected.(type) {
+			case int:
+				checkIntegerObject(t, evaluated, int64(expected))
+			case string:
+				checkErrorObject(t, evaluated, expected)
+			default:
+				checkNullObject(t, evaluated)
+			}
+		})
+	}
+}
+
+func TestOptionalChaining(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{"index/null", "null?[0]", nil},
+		{"index/null/short-circuit", "null?[unknown]", nil},
+		{"index/array", "[1, 2]?[1]", 2},
+		{"index/chained", `let h = {"a": {"b": 3}}; h?["a"]?["b"]`, 3},
+		{"index/chained/missing", `let h = {"a": {"b": 3}}; h?["x"]?["b"]`, nil},
+
+		{"property/null", "null?.field", nil},
+		{"property/hash", `{"field": 4}?.field`, 4},
+		{"property/missing", `{"field": 4}?.other`, nil},
+		{"property/chained", `let cfg = {"db": {"port": 5432}}; cfg?.db?.port`, 5432},
+		{"property/chained/missing", `let cfg = {}; cfg?.db?.port`, nil},
+		{"property/default", `let cfg = {}; cfg?.db?.port ?? 3306`, 3306},
+		{"property/unsupported", `1?.field`, `cannot access property "field" of type: @int@`},
+
+		{"short-circuit/property", "let n = null; n?.a.b", nil},
+		{"short-circuit/index", "let n = null; n?.a[0][1]", nil},
+		{"short-circuit/call", "let n = null; n?.f(unknown)", nil},
+		{"short-circuit/mixed", `let cfg = {"db": null}; cfg.db?["hosts"][0].name`, nil},
+		{"short-circuit/default", "let n = null; n?.a.b ?? 3", 3},
+		{"short-circuit/tail-call", "let f = fn(n) { n?.g() }; f(null)", nil},
+		{"short-circuit/non-null", `let cfg = {"a": {"b": 6}}; cfg?.a.b`, 6},
+		{"short-circuit/later-link", `let cfg = {"a": null}; cfg?.a.b`, `cannot access property "b" of type: @null@`},
+		{"short-circuit/parenthesized-argument", "let n = null; let f = fn(x) { x ?? 8 }; f(n?.a.b)", 8},
+		{"short-circuit/pipeline", "let n = null; n?.a |> fn(x) { 9 }", 9},
+		{"short-circuit/grouped/property", "let n = null; (n?.x).y", `cannot access property "y" of type: @null@`},
+		{"short-circuit/grouped/index", "let n = null; (n?[0])[1]", "index operator not supported: @null@[@int@]"},
+		{"short-circuit/grouped/within", "let n = null; (n?.x.y) ?? 5", 5},
+		{"short-circuit/grouped/nested", "let n = null; ((n?.x).y)?.z", `cannot access property "y" of type: @null@`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEval(test.input)
+			switch expected := test.expected.(type) {
+			case int:
+				checkIntegerObject(t, evaluated, int64(expected))
+			case string:
+				checkErrorObject(t, evaluated, expected)
+			default:
+				checkNullObject(t, evaluated)
+			}
+		})
+	}
+}
+
+func TestMethodCalls(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{"string/upper", `"abc".upper()`, "ABC"},
+		{"string/lower", `"AbC".lower()`, "abc"},
+		{"string/trim", `"  abc ".trim()`, "abc"},
+		{"string/split", `"a,b,c".split(",").join("-")`, "a-b-c"},
+		{"string/contains", `"abc".contains("b")`, true},
+		{"string/chained", `" Abc ".trim().upper()`, "ABC"},
+		{"array/map", `[1, 2, 3].map(fn(x) { x * 2 }).join(",")`, "2,4,6"},
+		{"array/filter", `[1, 2, 3, 4].filter(fn(x) { x > 2 }).join(",")`, "3,4"},
+		{"array/reduce", `[1, 2, 3, 4].reduce(fn(sum, x) { sum + x }, 0)`, 10},
+		{"array/reduce/empty", `[].reduce(fn(sum, x) { sum + x }, 5)`, 5},
+		{"array/push", `let a = [1]; let b = a.push(2); len(a) + len(b)`, 3},
+		{"array/closure", `let n = 3; [1, 2].map(fn(x) { x + n }).join(" ")`, "4 5"},
+		{"hash/has", `{"a": 1}.has("a")`, true},
+		{"hash/has/missing", `{"a": 1}.has("b")`, false},
+		{"hash/key-shadows-method", `{"has": 5}.has`, 5},
+		{"bound", `let up = "abc".upper; up()`, "ABC"},
+		{"in-function", `let f = fn(s) { s.upper() }; f("x")`, "X"},
+
+		{"error/arg-count", `"abc".upper(1)`, `function "upper" expects 0 arguments. got=1`},
+		{"error/arg-type", `"abc".split(1)`, `argument 0 of call to method "split" expects type @string@, got @int@`},
+		{"error/unknown", `"abc".foo()`, `cannot access property "foo" of type: @string@`},
+		{"error/unsupported", `1.upper()`, `cannot access property "upper" of type: @int@`},
+		{"error/callback", `[1, 2].map(fn(x) { x + true })`, "type mismatch: @int@ + @bool@"},
+		{"error/caught", `try { [1].map(fn(x) { throw "oops" }) } catch (e) { e.message }`, "oops"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEval(test.input)
+			switch expected := test.expected.(type) {
+			case int:
+				checkIntegerObject(t, evaluated, int64(expected))
+			case bool:
+				checkBooleanObject(t, evaluated, expected)
+			case string:
+				if err, ok := evaluated.(*object.Error); ok {
+					checkErrorObject(t, err, expected)
+					return
+				}
+				checkStringObject(t, evaluated, expected)
+			}
+		})
+	}
+}
+
+func TestPipelineExpressions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{"bare", `let double = fn(x) { x * 2 }; 3 |> double`, 6},
+		{"call", `let sub = fn(a, b) { a - b }; 10 |> sub(3)`, 7},
+		{"chained", `let double = fn(x) { x * 2 }; let sub = fn(a, b) { a - b }; 5 |> sub(1) |> double |> sub(2)`, 6},
+		{"builtin", `"four" |> len`, 4},
+		{"function-literal", `2 |> fn(x) { x + 1 }`, 3},
+		{"precedence", `let double = fn(x) { x * 2 }; 1 + 2 |> double == 6`, true},
+		{"method", `"a,b" |> fn(s) { s.split(",") } |> len`, 2},
+		{"spread", `let sum = fn(a, b, c) { a + b + c }; 1 |> sum(...[2, 3])`, 6},
+		{"defaults", `let f = fn(a, b = 10) { a + b }; 1 |> f`, 11},
+		{"not-a-function", `1 |> 2`, "cannot call expression of type: @int@"},
+		{"argument-count", `let f = fn(a, b) { a }; 1 |> f(2, 3)`, `function "f" expects 2 arguments. got=3`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEval(test.input)
+			switch expected := test.expected.(type) {
+			case int:
+				checkIntegerObject(t, evaluated, int64(expected))
+			case bool:
+				checkBooleanObject(t, evaluated, expected)
+			case string:
+				checkErrorObject(t, evaluated, expected)
+			}
+		})
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{"no-error", "try { 1 } catch (e) { 2 }", 1},
+		{"catch/runtime-error", "try { 1 + true } catch (e) { 2 }", 2},
+		{"catch/message", `try { unknown } catch (e) { e["message"] }`, "unknown identifier: unknown"},
+		{"catch/kind/reference", `try { unknown } catch (e) { e["kind"] }`, "reference"},
+		{"catch/kind/type", `try { -true } catch (e) { e?.kind }`, "type"},
+		{"catch/position", "try {\n  1 + true\n} catch (e) { [e[\"line\"], e[\"column\"]] }", []int64{2, 5}},
+		{"catch/fallback", `let cfg = {}; try { cfg["port"] + 1 } catch (e) { 8080 }`, 8080},
+		{"catch/scoped-parameter", `let e = 1; try { throw "x" } catch (e) { 2 }; e`, 1},
+		{"catch/in-function", `let f = fn() { throw "deep" }; try { f() } catch (e) { e["message"] }`, "deep"},
+
+		{"throw/string", `try { throw "oops" } catch (e) { e["message"] }`, "oops"},
+		{"throw/kind", `try { throw "oops" } catch (e) { e["kind"] }`, "thrown"},
+		{"throw/value", `try { throw 42 } catch (e) { e["value"] }`, 42},
+		{"throw/uncaught", `throw "oops"; 1`, &object.Error{Message: "oops"}},
+		{"throw/rethrow", `try { try { unknown } catch (e) { throw e } } catch (e) { e["kind"] }`, "reference"},
+		{"throw/custom-kind", `try { throw {"message": "m", "kind": "validation"} } catch (e) { e["kind"] }`, "validation"},
+		{"throw/hash/message", `try { throw {"message": "bad", "code": 42} } catch (e) { e.message }`, "bad"},
+		{"throw/hash/value", `try { throw {"message": "bad", "code": 42} } catch (e) { e.value.code }`, 42},
+		{"throw/rethrow/value", `try { try { throw 7 } catch (e) { throw e } } catch (e) { e.value }`, 7},
+		{"throw/rethrow/copy", `try { try { unknown } catch (e) { throw {"message": e.message} } } catch (e) { e.kind }`, "thrown"},
+
+		{"finally/runs", "let f = fn() { try { 1 } finally { 2 } }; f()", 1},
+		{"finally/after-catch", "try { throw 1 } catch (e) { 2 } finally { 3 }", 2},
+		{"finally/propagates-error", `try { throw "a" } finally { 1 }`, &object.Error{Message: "a"}},
+		{"finally/overrides-return", "let f = fn() { try { return 1 } finally { return 2 } }; f()", 2},
+		{"finally/overrides-error", `try { throw "a" } finally { throw "b" }`, &object.Error{Message: "b"}},
+
+		{"return/from-try", "let f = fn() { try { return 1; 2 } catch (e) { 3 }; 4 }; f()", 1},
+		{"return/from-catch", "let f = fn() { try { throw 1 } catch (e) { return 3 }; 4 }; f()", 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEval(test.input)
+			switch expected := test.expected.(type) {
+			case int:
+				checkIntegerObject(t, evaluated, int64(expected))
+			case string:
+				checkStringObject(t, evaluated, expected)
+			case []int64:
+				array, ok := evaluated.(*object.Array)
+				if !ok {
+					t.Fatalf("evaluated is not *object.Array. got=%T: %+v", evaluated, evaluated)
+				}
+				if len(array.Elements) != len(expected) {
+					t.Fatalf("array.Elements does not contain %d elements. got=%d", len(expected), len(array.Elements))
+				}
+				for index, value := range expected {
+					checkIntegerObject(t, array.Elements[index], value)
+				}
+			case *object.Error:
+				checkErrorObject(t, evaluated, expected.Message)
+			}
+		})
+	}
+}
+
+func TestFatalErrorsAreNotCaught(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("fatal", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return &object.Error{Message: "limit exceeded", Fatal: true}
+	}})
+
+	program := parser.New(lexer.New("let x = 1; try { fatal() } catch (e) { 2 } finally { let x = 3 }")).ParseProgram()
+	evaluated := Eval(program, env)
+	checkErrorObject(t, evaluated, "limit exceeded")
+
+	x, _ := env.Get("x")
+	checkIntegerObject(t, x, 1)
+}
+
+func TestErrorStackTraces(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		traceback string
+	}{
+		{
+			"top-level",
+			"1;\n-true",
+			"ERROR: unknown operator: -@bool@\n\tat <program> (2:1)",
+		},
+		{
+			// * the call of inner is a tail call, which replaces the frame of outer
+			"nested-calls",
+			"let inner = fn(x) {\n  x + y\n};\nlet outer = fn() {\n  inner(1)\n};\nouter();",
+			"ERROR: unknown identifier: y\n\tat inner (2:7)\n\tat <program> (7:6)",
+		},
+		{
+			"nested-calls/non-tail",
+			"let inner = fn(x) {\n  x + y\n};\nlet outer = fn() {\n  inner(1) + 1\n};\nouter();",
+			"ERROR: unknown identifier: y\n\tat inner (2:7)\n\tat outer (5:8)\n\tat <program> (7:6)",
+		},
+		{
+			"tail-call/argument-count",
+			"let inner = fn(x) { x };\nlet outer = fn() {\n  inner()\n};\nouter();",
+			"ERROR: function \"inner\" expects 1 arguments. got=0\n\tat outer (3:8)\n\tat <program> (5:6)",
+		},
+		{
+			"anonymous",
+			"fn() { throw \"oops\" }()",
+			"ERROR: oops\n\tat <anonymous> (1:8)\n\tat <program> (1:22)",
+		},
+		{
+			// * the builtin is tail-called, so it replaces the frame of f
+			"builtin",
+			"let f = fn(s) { len(s) }; f(1)",
+			"ERROR: argument 0 of call to builtin \"len\" expects type @string@, got @int@\n\tat len (1:20)\n\tat <program> (1:28)",
+		},
+		{
+			"builtin/non-tail",
+			"let f = fn(s) { return len(s) + 1 }; f(1)",
+			"ERROR: argument 0 of call to builtin \"len\" expects type @string@, got @int@\n\tat len (1:27)\n\tat f (1:27)\n\tat <program> (1:39)",
+		},
+		{
+			"method/callback",
+			"let f = fn(x) {\n  x + true\n};\n[1].map(f)",
+			"ERROR: type mismatch: @int@ + @bool@\n\tat f (2:5)\n\tat map (4:8)\n\tat <program> (4:8)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEval(test.input)
+			err, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Fatalf("evaluated is not *object.Error. got=%T: (%+v)", evaluated, evaluated)
+			}
+			if err.Traceback() != test.traceback {
+				t.Errorf("err.Traceback is wrong.\nexpected:\n%s\ngot:\n%s", test.traceback, err.Traceback())
+			}
+		})
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	// * limit the Go stack, so that non-optimized recursion would overflow it
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+
+	tests := []struct {
+		name     string
+		input    string
+		expected int64
+	}{
+		{
+			"if-else/last-expression",
+			"let loop = fn(n, acc) { if (n == 0) { acc } else { loop(n - 1, acc + 1) } }; loop(200000, 0)",
+			200000,
+		},
+		{
+			"return/early",
+			"let loop = fn(n, acc) { if (n == 0) { return acc; }; return loop(n - 1, acc + 2); }; loop(200000, 0)",
+			400000,
+		},
+		{
+			"mutual-recursion",
+			`
+			fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+			fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+			if (isEven(100001)) { 1 } else { 0 }
+			`,
+			0,
+		},
+		{
+			"builtin",
+			"let f = fn(s) { len(s) }; f(\"four\")",
+			4,
+		},
+		{
+			"non-tail/still-works",
+			"let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(100)",
+			5050,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEval(test.input)
+			checkIntegerObject(t, evaluated, test.expected)
+		})
+	}
+}
+
+func TestCallDepthLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		maxDepth int
+		expected interface{}
+	}{
+		{"default/exceeded", "let f = fn(x) { f(x) + 1 }; f(1)", 0, "maximum call depth 10000 exceeded"},
+		{"custom/exceeded", "let f = fn(x) { f(x) + 1 }; f(1)", 50, "maximum call depth 50 exceeded"},
+		{"custom/within", "let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(49)", 50, 1225},
+		{"tail-calls/not-counted", "let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } }; loop(1000)", 50, 0},
+		{"tail-calls/unbounded", "let f = fn(x) { f(x) }; f(1)", 0, "maximum call depth 1000000 exceeded"},
+		{"uncatchable", "let f = fn(x) { f(x) + 1 }; try { f(1) } catch (e) { 0 }", 50, "maximum call depth 50 exceeded"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			program := parser.New(lexer.New(test.input)).ParseProgram()
+			evaluator := &Evaluator{MaxCallDepth: test.maxDepth}
+			evaluated := evaluator.Eval(program, object.NewEnvironment())
+
+			switch expected := test.expected.(type) {
+			case int:
+				checkIntegerObject(t, evaluated, int64(expected))
+			case string:
+				checkErrorObject(t, evaluated, expected)
+				err := evaluated.(*object.Error)
+				if !err.Fatal || err.Kind != object.K_RESOURCE {
+					t.Errorf("err is not a fatal resource error. got Fatal=%v, Kind=%q", err.Fatal, err.Kind)
+				}
+			}
+
+			if evaluator.depth != 0 {
+				t.Errorf("evaluator.depth is not reset after evaluation. got=%d", evaluator.depth)
+			}
+		})
+	}
+
+	t.Run("traceback/truncated", func(t *testing.T) {
+		evaluated := (&Evaluator{MaxCallDepth: 100}).Eval(parser.New(lexer.New("let f = fn(x) { f(x) + 1 }; f(1)")).ParseProgram(), object.NewEnvironment())
+		err, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Fatalf("evaluated is not *object.Error. got=%T: (%+v)", evaluated, evaluated)
+		}
+		lines := strings.Split(err.Traceback(), "\n")
+		// * message, shown frames, omission note and program frame
+		if len(lines) != 1+object.TRACEBACK_MAX_FRAMES+1+1 {
+			t.Fatalf("traceback has wrong number of lines. got=%d:\n%s", len(lines), err.Traceback())
+		}
+		if lines[1+object.TRACEBACK_MAX_FRAMES/2] != "\t... 80 frames omitted ..." {
+			t.Errorf("traceback omission line is wrong. got=%q", lines[1+object.TRACEBACK_MAX_FRAMES/2])
+		}
+	})
+}
+
+/// Benchmarks
+
+func BenchmarkPrograms(b *testing.B) {
+	for _, program := range benchmarks.Programs() {
+		parsed := parser.New(lexer.New(program.Source)).ParseProgram()
+
+		b.Run(program.Name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if result := Eval(parsed, object.NewEnvironment()); result.Type() == object.O_ERROR {
+					b.Fatal(result.Inspect())
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkEnvironments compares looking up all identifiers by name with looking up resolved identifiers in slots.
+func BenchmarkEnvironments(b *testing.B) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"fibonacci", "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(18)"},
+		{"closures", `
+			let adder = fn(x) { fn(y) { x + y } };
+			let compose = fn(f, g) { fn(x) { g(f(x)) } };
+			let sum = fn(n, acc) {
+				if (n == 0) { return acc; }
+				let step = compose(adder(n), adder(1));
+				sum(n - 1, step(acc))
+			};
+			sum(5000, 0)`},
+	}
+
+	for _, test := range tests {
+		program := parser.New(lexer.New(test.input)).ParseProgram()
+
+		b.Run(test.name+"/names", func(b *testing.B) {
+			evaluator := New()
+			evaluator.unresolved = true
+			for i := 0; i < b.N; i++ {
+				evaluator.Eval(program, object.NewEnvironment())
+			}
+		})
+
+		b.Run(test.name+"/slots", func(b *testing.B) {
+			evaluator := New()
+			for i := 0; i < b.N; i++ {
+				evaluator.Eval(program, object.NewEnvironment())
+			}
+		})
+	}
+}
+
 /// helpers
 
 func testEval(input string) object.Object {
@@ -0,0 +1,127 @@
+package evaluator
+
+import (
+	"context"
+
+	"github.com/smalldevshima/go-monkey/object"
+)
+
+/// Constants / Variables
+
+// DEFAULT_MAX_CALL_DEPTH is the maximum number of nested function calls, if the Evaluator does not configure it.
+// It is chosen to stay far below the default maximum Go stack size.
+const DEFAULT_MAX_CALL_DEPTH = 10000
+
+// DEFAULT_MAX_TAIL_CALLS is the maximum number of consecutive tail calls, if the Evaluator does not configure it.
+// Tail calls run in constant stack, so it only stops tail recursion that never terminates.
+const DEFAULT_MAX_TAIL_CALLS = 1000000
+
+// CONTEXT_CHECK_INTERVAL is the number of evaluation steps after which the context of the evaluation is checked again.
+// Checking the context on every step would dominate the evaluation time.
+const CONTEXT_CHECK_INTERVAL = 1024
+
+// Approximate sizes in bytes used to account the memory allocated by an evaluation.
+// They follow the layout of the Go values backing the objects on 64-bit platforms.
+const (
+	SIZE_STRING_HEADER = 16
+	SIZE_ARRAY_HEADER  = 24
+	SIZE_ARRAY_ELEMENT = 16
+	SIZE_HASH_HEADER   = 48
+	SIZE_HASH_PAIR     = 64
+)
+
+/// Functions
+
+func (e *Evaluator) maxCallDepth() int {
+	if e.MaxCallDepth <= 0 {
+		return DEFAULT_MAX_CALL_DEPTH
+	}
+	return e.MaxCallDepth
+}
+
+func (e *Evaluator) maxTailCalls() int {
+	if e.MaxTailCalls <= 0 {
+		return DEFAULT_MAX_TAIL_CALLS
+	}
+	return e.MaxTailCalls
+}
+
+// step counts an evaluation step and returns a fatal error if the evaluation has to be aborted.
+func (e *Evaluator) step() *object.Error {
+	e.steps++
+
+	if e.MaxSteps > 0 && e.steps > e.MaxSteps {
+		return newFatalError(ERR_STEP_LIMIT_EXCEEDED, e.MaxSteps)
+	}
+
+	if e.steps%CONTEXT_CHECK_INTERVAL == 0 {
+		return e.checkContext()
+	}
+
+	return nil
+}
+
+// checkContext returns a fatal error if the context of the evaluation is done.
+func (e *Evaluator) checkContext() *object.Error {
+	if e.ctx == nil {
+		return nil
+	}
+
+	switch e.ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return newFatalError(ERR_TIMEOUT)
+	default:
+		return newFatalError(ERR_CANCELED)
+	}
+}
+
+// checkCollectionSize returns a fatal error if an array or hash of the given size must not be created.
+func (e *Evaluator) checkCollectionSize(size int) *object.Error {
+	if e.MaxCollectionSize > 0 && size > e.MaxCollectionSize {
+		return newFatalError(ERR_COLLECTION_SIZE_EXCEEDED, e.MaxCollectionSize, size)
+	}
+	return nil
+}
+
+// allocate accounts the approximate size of a newly created object.
+// It returns the object itself, or a fatal error if the allocation exceeds the memory quota of the evaluation.
+func (e *Evaluator) allocate(obj object.Object) object.Object {
+	e.allocated += objectSize(obj)
+	if e.MaxAllocatedBytes > 0 && e.allocated > e.MaxAllocatedBytes {
+		return newFatalError(ERR_MEMORY_LIMIT_EXCEEDED, e.MaxAllocatedBytes)
+	}
+	return obj
+}
+
+// allocateValue accounts an object created outside of the evaluator, like by a method,
+// checking the size of arrays and hashes as well.
+func (e *Evaluator) allocateValue(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Array:
+		if err := e.checkCollectionSize(len(obj.Elements)); err != nil {
+			return err
+		}
+	case *object.Hash:
+		if err := e.checkCollectionSize(len(obj.Pairs)); err != nil {
+			return err
+		}
+	}
+	return e.allocate(obj)
+}
+
+// objectSize returns the approximate number of bytes occupied by the object itself, excluding the objects it references.
+// Objects other than strings, arrays and hashes are not accounted.
+func objectSize(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.String:
+		return SIZE_STRING_HEADER + int64(len(obj.Value))
+	case *object.Array:
+		return SIZE_ARRAY_HEADER + SIZE_ARRAY_ELEMENT*int64(len(obj.Elements))
+	case *object.Hash:
+		return SIZE_HASH_HEADER + SIZE_HASH_PAIR*int64(len(obj.Pairs))
+	default:
+		return 0
+	}
+}
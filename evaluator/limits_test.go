@@ -0,0 +1,191 @@
+package evaluator
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/smalldevshima/go-monkey/lexer"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/parser"
+)
+
+/// Constants / Variables
+
+// infiniteLoop is only ended by exceeding MaxTailCalls, since tail calls are evaluated in constant stack.
+// Tests of other limits set MaxTailCalls to unboundedTailCalls, so that the loop outlives them.
+const infiniteLoop = "let loop = fn(x) { loop(x) }; loop(1)"
+
+// unboundedTailCalls is a limit of tail calls that infiniteLoop does not reach in practice
+const unboundedTailCalls = math.MaxInt
+
+/// Tests
+
+func TestStepLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		maxSteps int64
+		expected interface{}
+	}{
+		{"within", "1 + 2", 10, 3},
+		{"exceeded/expression", "1 + 2 + 3 + 4", 4, "maximum number of evaluation steps 4 exceeded"},
+		{"exceeded/infinite-loop", infiniteLoop, 10000, "maximum number of evaluation steps 10000 exceeded"},
+		{"exceeded/uncatchable", "try { " + infiniteLoop + " } catch (e) { 1 }", 500, "maximum number of evaluation steps 500 exceeded"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEvalWith(context.Background(), &Evaluator{MaxSteps: test.maxSteps}, test.input)
+			switch expected := test.expected.(type) {
+			case int:
+				checkIntegerObject(t, evaluated, int64(expected))
+			case string:
+				checkResourceError(t, evaluated, expected, object.K_RESOURCE)
+			}
+		})
+	}
+
+	t.Run("reset-between-evaluations", func(t *testing.T) {
+		evaluator := &Evaluator{MaxSteps: 10}
+		for i := 0; i < 3; i++ {
+			checkIntegerObject(t, testEvalWith(context.Background(), evaluator, "1 + 2"), 3)
+		}
+	})
+}
+
+func TestDurationLimit(t *testing.T) {
+	start := time.Now()
+	evaluated := testEvalWith(context.Background(), &Evaluator{MaxDuration: 20 * time.Millisecond, MaxTailCalls: unboundedTailCalls}, infiniteLoop)
+	checkResourceError(t, evaluated, "evaluation timed out", object.K_RESOURCE)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("evaluation was not aborted in time. took=%s", elapsed)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		evaluated := testEvalWith(ctx, &Evaluator{MaxTailCalls: unboundedTailCalls}, infiniteLoop)
+		checkResourceError(t, evaluated, "evaluation timed out", object.K_RESOURCE)
+	})
+
+	t.Run("canceled/before", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		evaluated := testEvalWith(ctx, New(), "1")
+		checkResourceError(t, evaluated, "evaluation canceled", object.K_CANCELED)
+	})
+
+	t.Run("canceled/during", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		evaluated := testEvalWith(ctx, &Evaluator{MaxTailCalls: unboundedTailCalls}, infiniteLoop)
+		checkResourceError(t, evaluated, "evaluation canceled", object.K_CANCELED)
+	})
+}
+
+func TestCollectionSizeLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{"array/within", "len([1, 2, 3])", 3},
+		{"array/exceeded", "[1, 2, 3, 4]", "maximum collection size 3 exceeded. got=4"},
+		{"array/spread", "let xs = [1, 2]; [...xs, ...xs]", "maximum collection size 3 exceeded. got=4"},
+		{"hash/within", `len([{"a": 1, "b": 2, "c": 3}])`, 1},
+		{"hash/exceeded", `{"a": 1, "b": 2, "c": 3, "d": 4}`, "maximum collection size 3 exceeded. got=4"},
+		{"rest/exceeded", "let f = fn(...rest) { rest }; f(1, 2, 3, 4)", "maximum collection size 3 exceeded. got=4"},
+		{"method/push/within", "len([1, 2].push(3))", 3},
+		{"method/push/exceeded", "[1, 2, 3].push(4)", "maximum collection size 3 exceeded. got=4"},
+		{"method/split/within", `len("a,b,c".split(","))`, 3},
+		{"method/split/exceeded", `"a,b,c,d".split(",")`, "maximum collection size 3 exceeded. got=4"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEvalWith(context.Background(), &Evaluator{MaxCollectionSize: 3}, test.input)
+			switch expected := test.expected.(type) {
+			case int:
+				checkIntegerObject(t, evaluated, int64(expected))
+			case string:
+				checkResourceError(t, evaluated, expected, object.K_RESOURCE)
+			}
+		})
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	const doubling = `let grow = fn(s, n) { if (n == 0) { s } else { grow(s + s, n - 1) } }; `
+	const joinDoubling = `let grow = fn(s, n) { if (n == 0) { s } else { grow([s, s].join(""), n - 1) } }; `
+
+	tests := []struct {
+		name     string
+		input    string
+		maxBytes int64
+		expected interface{}
+	}{
+		{"string/within", `len("abc" + "def")`, 100, 6},
+		{"string/exceeded", `"abcdefghij" + "abcdefghij"`, 60, "maximum allocation of 60 bytes exceeded"},
+		{"string/growing", doubling + `len(grow("x", 30))`, 1 << 20, "maximum allocation of 1048576 bytes exceeded"},
+		{"string/uncatchable", doubling + `try { grow("x", 30) } catch (e) { 1 }`, 1 << 20, "maximum allocation of 1048576 bytes exceeded"},
+		{"array/within", "len([1, 2, 3])", 100, 3},
+		{"array/exceeded", "[1, 2, 3, 4, 5, 6, 7, 8]", 100, "maximum allocation of 100 bytes exceeded"},
+		{"hash/exceeded", `{1: 1, 2: 2}`, 100, "maximum allocation of 100 bytes exceeded"},
+		{"rest/exceeded", "let f = fn(...rest) { 1 }; f(1, 2, 3, 4, 5, 6, 7, 8)", 100, "maximum allocation of 100 bytes exceeded"},
+		{"method/join/within", joinDoubling + `len(grow("x", 3))`, 1 << 20, 8},
+		{"method/join/growing", joinDoubling + `len(grow("x", 30))`, 1 << 20, "maximum allocation of 1048576 bytes exceeded"},
+		{"method/upper/exceeded", `"abcdefghij".upper()`, 40, "maximum allocation of 40 bytes exceeded"},
+		{"method/split/exceeded", `"a,b,c,d,e,f".split(",")`, 100, "maximum allocation of 100 bytes exceeded"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEvalWith(context.Background(), &Evaluator{MaxAllocatedBytes: test.maxBytes}, test.input)
+			switch expected := test.expected.(type) {
+			case int:
+				checkIntegerObject(t, evaluated, int64(expected))
+			case string:
+				checkResourceError(t, evaluated, expected, object.K_RESOURCE)
+			}
+		})
+	}
+
+	t.Run("reset-between-evaluations", func(t *testing.T) {
+		evaluator := &Evaluator{MaxAllocatedBytes: 100}
+		for i := 0; i < 3; i++ {
+			checkIntegerObject(t, testEvalWith(context.Background(), evaluator, `len("abc" + "def")`), 6)
+		}
+	})
+}
+
+/// helpers
+
+func testEvalWith(ctx context.Context, evaluator *Evaluator, input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return evaluator.EvalContext(ctx, program, env)
+}
+
+func checkResourceError(t *testing.T, obj object.Object, message string, kind object.ErrorKind) {
+	t.Helper()
+	checkErrorObject(t, obj, message)
+
+	err := obj.(*object.Error)
+	if !err.Fatal {
+		t.Errorf("err is not fatal")
+	}
+	if err.Kind != kind {
+		t.Errorf("err.Kind is wrong. expected=%q, got=%q", kind, err.Kind)
+	}
+}
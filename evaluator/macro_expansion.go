@@ -0,0 +1,105 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/object"
+)
+
+/// Functions
+
+// DefineMacros removes the top-level let statements binding macro literals from the program
+// and binds the defined macros in env instead.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := []ast.Statement{}
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			statements = append(statements, stmt)
+			continue
+		}
+		macro, ok := let.Value.(*ast.MacroLiteral)
+		if !ok {
+			statements = append(statements, stmt)
+			continue
+		}
+		env.Set(let.Name.Value, &object.Macro{Parameters: macro.Parameters, Body: macro.Body, Env: env})
+	}
+	program.Statements = statements
+}
+
+// ExpandMacros replaces the calls of macros bound in env by the result of the macro.
+// The arguments of a call are not evaluated, but bound to the parameters of the macro as object.Quote.
+// The macro has to return a quote, whose expression replaces the call.
+//
+// Calls of macros are expanded after the macro calls in their arguments.
+// The expanded expressions are not expanded again. The program is modified in place and returned.
+//
+// The macros are evaluated by a new Evaluator with default limits.
+func ExpandMacros(program *ast.Program, env *object.Environment) (*ast.Program, error) {
+	return New().ExpandMacros(program, env)
+}
+
+// ExpandMacros expands the calls of macros like the function ExpandMacros,
+// but evaluates the macros with the limits of the Evaluator, which apply to each expanded call separately.
+func (e *Evaluator) ExpandMacros(program *ast.Program, env *object.Environment) (*ast.Program, error) {
+	var err error
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || err != nil {
+			return node
+		}
+		ident, ok := call.Function.(*ast.Identifier)
+		if !ok {
+			return node
+		}
+		obj, ok := env.Get(ident.Value)
+		if !ok {
+			return node
+		}
+		macro, ok := obj.(*object.Macro)
+		if !ok {
+			return node
+		}
+
+		var expanded ast.Expression
+		expanded, err = e.expandMacro(ident.Value, macro, call)
+		if err != nil {
+			return node
+		}
+		return expanded
+	})
+	return program, err
+}
+
+// expandMacro evaluates the macro with the arguments of the call and returns the resulting expression.
+func (e *Evaluator) expandMacro(name string, macro *object.Macro, call *ast.CallExpression) (ast.Expression, error) {
+	if len(call.Arguments) != len(macro.Parameters) {
+		return nil, fmt.Errorf("macro %q at %s expects %d arguments. got=%d", name, call.Pos(), len(macro.Parameters), len(call.Arguments))
+	}
+
+	env := object.NewEnclosedEnvironment(macro.Env)
+	for index, param := range macro.Parameters {
+		env.Set(param.Value, &object.Quote{Node: call.Arguments[index]})
+	}
+
+	result := e.Eval(macro.Body, env)
+	if returnValue, ok := result.(*object.ReturnValue); ok {
+		result = returnValue.Value
+	}
+
+	switch result := result.(type) {
+	case *object.Error:
+		return nil, fmt.Errorf("macro %q at %s failed: %s", name, call.Pos(), result.Message)
+	case *object.Quote:
+		if exp, ok := result.Node.(ast.Expression); ok {
+			return exp, nil
+		}
+	}
+	resultType := object.ObjectType("nil")
+	if result != nil {
+		resultType = result.Type()
+	}
+	return nil, fmt.Errorf("macro %q at %s has to return a quoted expression, got %s", name, call.Pos(), resultType)
+}
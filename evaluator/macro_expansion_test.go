@@ -0,0 +1,245 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/lexer"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/parser"
+)
+
+/// Tests
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(t, input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+	for _, name := range []string{"number", "function"} {
+		if _, ok := env.Get(name); ok {
+			t.Errorf("%q should not be defined", name)
+		}
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not *object.Macro. got=%T (%+v)", obj, obj)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+	if macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Errorf("parameters are wrong. got=%q, %q", macro.Parameters[0], macro.Parameters[1])
+	}
+	if macro.Body.String() != "(x + y);" {
+		t.Errorf("body is wrong. expected=%q, got=%q", "(x + y);", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`
+			let infixExpression = macro() { quote(1 + 2); };
+			infixExpression();
+			`,
+			`(1 + 2)`,
+		},
+		{
+			`
+			let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+			reverse(2 + 2, 10 - 5);
+			`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+			let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+			unless(10 > 5, puts("not greater"), puts("greater"));
+			`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			`
+			let double = macro(x) { quote(unquote(x) * 2); };
+			let twice = macro(x) { quote(unquote(x) + unquote(x)); };
+			twice(double(1));
+			`,
+			`(1 * 2) + (1 * 2)`,
+		},
+		{
+			`
+			let constant = macro() { quote(unquote(40 + 2)); };
+			let f = fn() { constant() };
+			`,
+			`let f = fn() { 42 }`,
+		},
+	}
+
+	for _, test := range tests {
+		expected := testParseProgram(t, test.expected)
+		program := testParseProgram(t, test.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Errorf("could not expand macros of %q: %s", test.input, err)
+			continue
+		}
+
+		if !ast.Equal(expanded, expected, ast.EqualOptions{IgnorePositions: true, IgnoreTokens: true}) {
+			t.Errorf("expanded program is wrong. expected=%q, got=%q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let m = macro(x) { x }; m()`,
+			`macro "m" at 1:26 expects 1 arguments. got=0`,
+		},
+		{
+			`let m = macro() { 1 }; m()`,
+			`macro "m" at 1:25 has to return a quoted expression, got @int@`,
+		},
+		{
+			`let m = macro() { if (false) { quote(1) } }; m()`,
+			`macro "m" at 1:47 has to return a quoted expression, got @null@`,
+		},
+		{
+			`let m = macro() { quote(unquote(y)) }; m()`,
+			`macro "m" at 1:41 failed: unknown identifier: y`,
+		},
+	}
+
+	for _, test := range tests {
+		program := testParseProgram(t, test.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("expected error for %q", test.input)
+			continue
+		}
+		if err.Error() != test.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", test.input, test.expected, err)
+		}
+	}
+}
+
+func TestExpandMacrosLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		evaluator *Evaluator
+		input     string
+		expected  string
+	}{
+		{
+			"steps",
+			&Evaluator{MaxSteps: 1000, MaxTailCalls: unboundedTailCalls},
+			"let m = macro() { " + infiniteLoop + " }; m()",
+			`macro "m" at 1:61 failed: maximum number of evaluation steps 1000 exceeded`,
+		},
+		{
+			"collection-size",
+			&Evaluator{MaxCollectionSize: 3},
+			"let m = macro() { [1, 2, 3, 4] }; m()",
+			`macro "m" at 1:36 failed: maximum collection size 3 exceeded. got=4`,
+		},
+		{
+			"memory",
+			&Evaluator{MaxAllocatedBytes: 60},
+			`let m = macro() { "abcdefghij" + "abcdefghij" }; m()`,
+			`macro "m" at 1:51 failed: maximum allocation of 60 bytes exceeded`,
+		},
+		{
+			"within",
+			&Evaluator{MaxSteps: 1000, MaxCollectionSize: 3, MaxAllocatedBytes: 100},
+			`let m = macro() { [1, 2, 3]; quote("abc" + "def") }; m()`,
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			program := testParseProgram(t, test.input)
+
+			env := object.NewEnvironment()
+			DefineMacros(program, env)
+			_, err := test.evaluator.ExpandMacros(program, env)
+			switch {
+			case test.expected == "" && err != nil:
+				t.Errorf("unexpected error: %s", err)
+			case test.expected != "" && err == nil:
+				t.Errorf("expected error %q", test.expected)
+			case err != nil && err.Error() != test.expected:
+				t.Errorf("wrong error. expected=%q, got=%q", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestEvalExpandedMacros(t *testing.T) {
+	input := `
+	let unless = macro(condition, consequence, alternative) {
+		quote(if (!(unquote(condition))) {
+			unquote(consequence);
+		} else {
+			unquote(alternative);
+		});
+	};
+	let x = 0;
+	unless(x > 5, x + 1, x + 2);
+	`
+
+	program := testParseProgram(t, input)
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+	if _, err := ExpandMacros(program, env); err != nil {
+		t.Fatalf("could not expand macros: %s", err)
+	}
+
+	checkIntegerObject(t, Eval(program, object.NewEnvironment()), 1)
+}
+
+/// helpers
+
+func testParseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("could not parse %q: %v", input, p.Errors())
+	}
+	return program
+}
@@ -0,0 +1,196 @@
+package evaluator
+
+import (
+	"strings"
+
+	"github.com/smalldevshima/go-monkey/object"
+)
+
+/// Constants / Variables
+
+// methods maps every object type to its methods by name
+var methods = map[object.ObjectType]map[string]object.MethodFunction{
+	object.O_STRING: {
+		"upper":    M_STRING_UPPER,
+		"lower":    M_STRING_LOWER,
+		"trim":     M_STRING_TRIM,
+		"split":    M_STRING_SPLIT,
+		"contains": M_STRING_CONTAINS,
+	},
+	object.O_ARRAY: {
+		"map":    M_ARRAY_MAP,
+		"filter": M_ARRAY_FILTER,
+		"reduce": M_ARRAY_REDUCE,
+		"push":   M_ARRAY_PUSH,
+		"join":   M_ARRAY_JOIN,
+	},
+	object.O_HASH: {
+		"has": M_HASH_HAS,
+	},
+}
+
+var M_STRING_UPPER object.MethodFunction = func(rt *object.Runtime, receiver object.Object, args ...object.Object) object.Object {
+	if err := checkMethodArguments("upper", args); err != nil {
+		return err
+	}
+	return rt.Allocate(&object.String{Value: strings.ToUpper(receiver.(*object.String).Value)})
+}
+
+var M_STRING_LOWER object.MethodFunction = func(rt *object.Runtime, receiver object.Object, args ...object.Object) object.Object {
+	if err := checkMethodArguments("lower", args); err != nil {
+		return err
+	}
+	return rt.Allocate(&object.String{Value: strings.ToLower(receiver.(*object.String).Value)})
+}
+
+var M_STRING_TRIM object.MethodFunction = func(rt *object.Runtime, receiver object.Object, args ...object.Object) object.Object {
+	if err := checkMethodArguments("trim", args); err != nil {
+		return err
+	}
+	return rt.Allocate(&object.String{Value: strings.TrimSpace(receiver.(*object.String).Value)})
+}
+
+var M_STRING_SPLIT object.MethodFunction = func(rt *object.Runtime, receiver object.Object, args ...object.Object) object.Object {
+	if err := checkMethodArguments("split", args, object.O_STRING); err != nil {
+		return err
+	}
+	parts := strings.Split(receiver.(*object.String).Value, args[0].(*object.String).Value)
+	elements := make([]object.Object, len(parts))
+	for index, part := range parts {
+		element := rt.Allocate(&object.String{Value: part})
+		if isError(element) {
+			return element
+		}
+		elements[index] = element
+	}
+	return rt.Allocate(&object.Array{Elements: elements})
+}
+
+var M_STRING_CONTAINS object.MethodFunction = func(rt *object.Runtime, receiver object.Object, args ...object.Object) object.Object {
+	if err := checkMethodArguments("contains", args, object.O_STRING); err != nil {
+		return err
+	}
+	return nativeBooleanToObject(strings.Contains(receiver.(*object.String).Value, args[0].(*object.String).Value))
+}
+
+// M_ARRAY_MAP returns a new array with the results of calling the function with every element.
+var M_ARRAY_MAP object.MethodFunction = func(rt *object.Runtime, receiver object.Object, args ...object.Object) object.Object {
+	if err := checkMethodArguments("map", args, ""); err != nil {
+		return err
+	}
+	elements := receiver.(*object.Array).Elements
+	mapped := make([]object.Object, len(elements))
+	for index, element := range elements {
+		result := rt.Call(args[0], element)
+		if isError(result) {
+			return result
+		}
+		mapped[index] = result
+	}
+	return rt.Allocate(&object.Array{Elements: mapped})
+}
+
+// M_ARRAY_FILTER returns a new array with the elements for which the function returns a truthy value.
+var M_ARRAY_FILTER object.MethodFunction = func(rt *object.Runtime, receiver object.Object, args ...object.Object) object.Object {
+	if err := checkMethodArguments("filter", args, ""); err != nil {
+		return err
+	}
+	filtered := []object.Object{}
+	for _, element := range receiver.(*object.Array).Elements {
+		result := rt.Call(args[0], element)
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) {
+			filtered = append(filtered, element)
+		}
+	}
+	return rt.Allocate(&object.Array{Elements: filtered})
+}
+
+// M_ARRAY_REDUCE combines the elements from first to last by calling the function with the result so far and the element,
+// starting with the initial value.
+var M_ARRAY_REDUCE object.MethodFunction = func(rt *object.Runtime, receiver object.Object, args ...object.Object) object.Object {
+	if err := checkMethodArguments("reduce", args, "", ""); err != nil {
+		return err
+	}
+	result := args[1]
+	for _, element := range receiver.(*object.Array).Elements {
+		result = rt.Call(args[0], result, element)
+		if isError(result) {
+			return result
+		}
+	}
+	return result
+}
+
+// M_ARRAY_PUSH returns a new array with the value appended, leaving the receiver unchanged.
+var M_ARRAY_PUSH object.MethodFunction = func(rt *object.Runtime, receiver object.Object, args ...object.Object) object.Object {
+	if err := checkMethodArguments("push", args, ""); err != nil {
+		return err
+	}
+	elements := receiver.(*object.Array).Elements
+	pushed := make([]object.Object, len(elements), len(elements)+1)
+	copy(pushed, elements)
+	return rt.Allocate(&object.Array{Elements: append(pushed, args[0])})
+}
+
+// M_ARRAY_JOIN returns the inspected elements separated by the given string.
+var M_ARRAY_JOIN object.MethodFunction = func(rt *object.Runtime, receiver object.Object, args ...object.Object) object.Object {
+	if err := checkMethodArguments("join", args, object.O_STRING); err != nil {
+		return err
+	}
+	elements := receiver.(*object.Array).Elements
+	parts := make([]string, len(elements))
+	for index, element := range elements {
+		parts[index] = element.Inspect()
+	}
+	return rt.Allocate(&object.String{Value: strings.Join(parts, args[0].(*object.String).Value)})
+}
+
+// M_HASH_HAS reports whether the hash contains the given key, even if its value is null.
+var M_HASH_HAS object.MethodFunction = func(rt *object.Runtime, receiver object.Object, args ...object.Object) object.Object {
+	if err := checkMethodArguments("has", args, ""); err != nil {
+		return err
+	}
+	key, ok := args[0].(object.Hashable)
+	if !ok {
+		return newError(ERR_UNHASHABLE, args[0].Type())
+	}
+	_, ok = receiver.(*object.Hash).Pairs[key.HashKey()]
+	return nativeBooleanToObject(ok)
+}
+
+/// Functions
+
+// RegisterMethod registers the method for all values of the object type, replacing any method with the same name.
+// Methods are shared by all evaluations and have to be registered before programs are run, e.g. in an init function.
+func RegisterMethod(objectType object.ObjectType, name string, fn object.MethodFunction) {
+	if methods[objectType] == nil {
+		methods[objectType] = make(map[string]object.MethodFunction)
+	}
+	methods[objectType][name] = fn
+}
+
+// lookupMethod returns the method of the object with the given name bound to the object.
+func lookupMethod(obj object.Object, name string) (*object.BoundMethod, bool) {
+	fn, ok := methods[obj.Type()][name]
+	if !ok {
+		return nil, false
+	}
+	return &object.BoundMethod{Name: name, Receiver: obj, Fn: fn}, true
+}
+
+// checkMethodArguments returns an error unless the arguments of the method have the given types.
+// An empty type accepts arguments of any type.
+func checkMethodArguments(name string, args []object.Object, types ...object.ObjectType) *object.Error {
+	if len(args) != len(types) {
+		return newError(ERR_ARG_COUNT_MISMATCH, name, len(types), len(args))
+	}
+	for index, arg := range args {
+		if types[index] != "" && arg.Type() != types[index] {
+			return newError(ERR_METHOD_TYPE_ERROR, index, name, types[index], arg.Type())
+		}
+	}
+	return nil
+}
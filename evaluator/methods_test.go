@@ -0,0 +1,22 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/smalldevshima/go-monkey/object"
+)
+
+/// Tests
+
+func TestRegisterMethod(t *testing.T) {
+	RegisterMethod(object.O_INTEGER, "twice", func(rt *object.Runtime, receiver object.Object, args ...object.Object) object.Object {
+		if err := checkMethodArguments("twice", args, ""); err != nil {
+			return err
+		}
+		return rt.Call(args[0], rt.Call(args[0], receiver))
+	})
+	defer delete(methods, object.O_INTEGER)
+
+	evaluated := testEval("let inc = fn(x) { x + 1 }; let n = 5; n.twice(inc)")
+	checkIntegerObject(t, evaluated, 7)
+}
@@ -0,0 +1,113 @@
+package evaluator
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/module"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/resolver"
+)
+
+/// Functions
+
+// loader returns the module loader of the Evaluator, creating a default one if it has none.
+func (e *Evaluator) loader() *module.Loader {
+	if e.Loader == nil {
+		e.Loader = &module.Loader{}
+	}
+	return e.Loader
+}
+
+// evalImportStatement binds the module imported by the statement to its name.
+// The module is evaluated on its first import only, later imports bind the same object.Module.
+func (e *Evaluator) evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	from := e.Path
+	if len(e.importing) != 0 {
+		from = e.importing[len(e.importing)-1]
+	}
+	mod, err := e.loader().Load(node.Path.Value, from)
+	if err != nil {
+		return newError(ERR_IMPORT_FAILED, node.Path.Value, err)
+	}
+
+	val, ok := e.modules[mod.Path]
+	if !ok {
+		result := e.evalModule(mod, node)
+		if isError(result) {
+			return result
+		}
+		val = result.(*object.Module)
+	}
+	e.setBinding(node.Name, val, env)
+	return nil
+}
+
+// evalModule evaluates the program of the module in an environment of its own and collects its exports.
+// Errors raised by the module get a stack frame for the module, which was executing at the import statement.
+func (e *Evaluator) evalModule(mod *module.Module, node *ast.ImportStatement) object.Object {
+	importing := e.importing
+	if len(importing) == 0 && e.Path != "" {
+		importing = []string{filepath.Clean(e.Path)}
+	}
+	for index, path := range importing {
+		if path == mod.Path {
+			cycle := append(append([]string{}, importing[index:]...), mod.Path)
+			return newError(ERR_IMPORT_CYCLE, strings.Join(cycle, " -> "))
+		}
+	}
+
+	if !e.unresolved {
+		e.mergeResolution(resolver.Resolve(mod.Program))
+	}
+
+	env := object.NewEnvironment()
+	previous := e.importing
+	e.importing = append(importing, mod.Path)
+	result := e.eval(mod.Program, env)
+	e.importing = previous
+
+	if err, ok := result.(*object.Error); ok {
+		err.Stack = append(err.Stack, object.Frame{Function: fmt.Sprintf(object.F_MODULE_FRAME, mod.Path), Position: node.Pos()})
+		return err
+	}
+
+	exports := make(map[string]object.Object, len(mod.Exports))
+	for _, name := range mod.Exports {
+		if val, ok := env.Get(name); ok {
+			exports[name] = val
+		}
+	}
+	val := &object.Module{Path: mod.Path, Exports: exports}
+	if e.modules == nil {
+		e.modules = make(map[string]*object.Module)
+	}
+	e.modules[mod.Path] = val
+	return val
+}
+
+// mergeResolution adds the bindings and scopes of a module to the ones of the evaluated program,
+// so that functions defined by the module are evaluated with resolved identifiers as well.
+func (e *Evaluator) mergeResolution(resolution *resolver.Resolution) {
+	if e.bindings == nil {
+		e.bindings = make(map[*ast.Identifier]resolver.Binding)
+		e.scopes = make(map[ast.Node]*resolver.Scope)
+	}
+	for ident, binding := range resolution.Bindings {
+		e.bindings[ident] = binding
+	}
+	for node, scope := range resolution.Scopes {
+		e.scopes[node] = scope
+	}
+}
+
+// evalModuleProperty looks up the export of the module with the given name.
+func evalModuleProperty(mod *object.Module, property string) object.Object {
+	val, ok := mod.Exports[property]
+	if !ok {
+		return newError(ERR_EXPORT_UNKNOWN, mod.Path, property)
+	}
+	return val
+}
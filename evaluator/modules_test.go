@@ -0,0 +1,170 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/smalldevshima/go-monkey/lexer"
+	"github.com/smalldevshima/go-monkey/module"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/parser"
+)
+
+/// Constants / Variables
+
+// testModules are the source files of the modules imported by the module tests
+var testModules = map[string]string{
+	"math.mk": `
+		let twice = fn(f, x) { f(f(x)) };
+		export let double = fn(x) { x * 2 };
+		export let quadruple = fn(x) { twice(double, x) };
+		export let answer = 42;`,
+	"lib/strings.mk": `
+		import "../math.mk" as math;
+		export let greet = fn(name) { "hello " + name };
+		export let answer = if (math.answer == 42) { "forty-two" } else { "unknown" };`,
+	"lib/fail.mk": `
+		let check = fn(x) { if (x) { throw "check failed" } };
+		check(true);`,
+	"cycle/a.mk": `import "b.mk" as b; export let a = 1;`,
+	"cycle/b.mk": `import "a.mk" as a; export let b = 2;`,
+	"hidden.mk":  `export let leak = fn() { secret };`,
+}
+
+/// Tests
+
+func TestImportStatements(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{"export", `import "math.mk" as m; m.answer`, 42},
+		{"function", `import "math.mk" as m; m.double(21)`, 42},
+		{"closure", `import "math.mk" as m; m.quadruple(3)`, 12},
+		{"nested", `import "lib/strings.mk" as s; s.answer`, "forty-two"},
+		{"in-function", `import "math.mk" as m; let f = fn(x) { m.double(x) + 1 }; f(1)`, 3},
+		{"unexported", `import "math.mk" as m; m.twice`, `module "<dir>/math.mk" has no export "twice"`},
+		{"unknown", `import "missing.mk" as m; 1`, `cannot import "missing.mk": cannot find module "missing.mk"`},
+		{"cycle", `import "cycle/a.mk" as a; a.a`, "import cycle: <dir>/cycle/a.mk -> <dir>/cycle/b.mk -> <dir>/cycle/a.mk"},
+		{"isolated", `let secret = 1; import "hidden.mk" as h; h.leak()`, "unknown identifier: secret"},
+		{"failing", `import "lib/fail.mk" as f; 1`, "check failed"},
+	}
+
+	dir := writeModules(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluated := testEvalModule(t, dir, test.input)
+			switch expected := test.expected.(type) {
+			case int:
+				checkIntegerObject(t, evaluated, int64(expected))
+			case string:
+				if err, ok := evaluated.(*object.Error); ok {
+					checkErrorObject(t, err, strings.ReplaceAll(expected, "<dir>", dir))
+					return
+				}
+				checkStringObject(t, evaluated, expected)
+			}
+		})
+	}
+}
+
+func TestImportEvaluatesModulesOnce(t *testing.T) {
+	dir := writeModules(t)
+	e := New()
+	e.Path = filepath.Join(dir, "main.mk")
+	env := object.NewEnvironment()
+
+	first := e.Eval(testParseProgram(t, `import "math.mk" as m; import "./lib/../math.mk" as n; m.double == n.double`), env)
+	checkBooleanObject(t, first, true)
+
+	// * modules are shared by all evaluations of the Evaluator
+	second := e.Eval(testParseProgram(t, `import "lib/strings.mk" as s; import "math.mk" as o; m == o`), env)
+	checkBooleanObject(t, second, true)
+}
+
+func TestImportFromFS(t *testing.T) {
+	e := New()
+	e.Loader = &module.Loader{
+		FS: fstest.MapFS{
+			"rules/limits.mk":    {Data: []byte(`import "shared/math.mk" as math; export let max = math.double(5);`)},
+			"lib/shared/math.mk": {Data: []byte(`export let double = fn(x) { x * 2 };`)},
+		},
+		SearchPaths: []string{"lib"},
+	}
+	e.Path = "rules/main.mk"
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "limits.mk" as limits; limits.max`, 10},
+		{`import "/lib/shared/math.mk" as math; math.double(2)`, 4},
+		{`import "../../outside.mk" as outside; 1`, `cannot import "../../outside.mk": cannot find module "../../outside.mk"`},
+	}
+
+	for _, test := range tests {
+		evaluated := e.Eval(testParseProgram(t, test.input), object.NewEnvironment())
+		switch expected := test.expected.(type) {
+		case int:
+			checkIntegerObject(t, evaluated, int64(expected))
+		case string:
+			checkErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestImportErrorStackTraces(t *testing.T) {
+	dir := writeModules(t)
+	evaluated := testEvalModule(t, dir, "let x = 1;\nimport \"lib/fail.mk\" as f;")
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := strings.Join([]string{
+		"ERROR: check failed",
+		"\tat check (2:32)",
+		"\tat <module " + filepath.Join(dir, "lib", "fail.mk") + "> (3:8)",
+		"\tat <program> (2:1)",
+	}, "\n")
+	if err.Traceback() != expected {
+		t.Errorf("traceback is wrong.\nexpected=%q\ngot=     %q", expected, err.Traceback())
+	}
+}
+
+/// helpers
+
+// writeModules writes the test modules to a temporary directory and returns the directory.
+func writeModules(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, source := range testModules {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// testEvalModule evaluates the input as if it was read from the file main.mk in the directory.
+func testEvalModule(t *testing.T, dir string, input string) object.Object {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	e := New()
+	e.Loader = &module.Loader{}
+	e.Path = filepath.Join(dir, "main.mk")
+	return e.Eval(program, object.NewEnvironment())
+}
@@ -0,0 +1,86 @@
+package evaluator
+
+import (
+	"sort"
+
+	"github.com/smalldevshima/go-monkey/object"
+)
+
+// The functions in this file expose the semantics of operators, builtins and errors to other engines
+// executing Monkey programs, like the vm package, so that they produce identical results to the Evaluator.
+
+/// Constants / Variables
+
+// sortedBuiltins contains all builtins sorted by name
+var sortedBuiltins = func() []*object.Builtin {
+	sorted := make([]*object.Builtin, 0, len(builtins))
+	for _, builtin := range builtins {
+		sorted = append(sorted, builtin)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}()
+
+/// Functions
+
+// Builtins returns all builtin functions sorted by name.
+// The returned slice must not be modified.
+func Builtins() []*object.Builtin {
+	return sortedBuiltins
+}
+
+// IsTruthy reports whether the value is considered true in conditions.
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// NativeBooleanToObject returns the boolean object for the given value.
+func NativeBooleanToObject(input bool) *object.Boolean {
+	return nativeBooleanToObject(input)
+}
+
+// NewError creates an error of the kind associated with the format.
+func NewError(format ErrorFormat, a ...interface{}) *object.Error {
+	return newError(format, a...)
+}
+
+// NewFatalError creates an error of the kind associated with the format that cannot be caught by try-catch expressions.
+func NewFatalError(format ErrorFormat, a ...interface{}) *object.Error {
+	return newFatalError(format, a...)
+}
+
+// PrefixOperation applies the prefix operator to the operand.
+func PrefixOperation(operator string, operand object.Object) object.Object {
+	return evalPrefixExpression(operator, operand)
+}
+
+// InfixOperation applies the infix operator to the operands.
+// The "??" operator is not supported, since it does not evaluate its right operand in all cases.
+func InfixOperation(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// IndexOperation returns the element of left at the given index.
+func IndexOperation(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// PropertyOperation returns the property of the object with the given name.
+func PropertyOperation(obj object.Object, property string) object.Object {
+	return evalPropertyExpression(obj, property)
+}
+
+// ObjectSize returns the approximate number of bytes that creating the object accounts toward the MaxAllocatedBytes of an Evaluator.
+func ObjectSize(obj object.Object) int64 {
+	return objectSize(obj)
+}
+
+// ErrorToHash converts an error into the hash that catch-branches bind their parameter to.
+func ErrorToHash(err *object.Error) *object.Hash {
+	return errorToHash(err)
+}
+
+// ErrorFromObject creates the error raised by throwing the given value.
+func ErrorFromObject(val object.Object) *object.Error {
+	return errorFromObject(val)
+}
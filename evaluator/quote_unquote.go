@@ -0,0 +1,97 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Constants / Variables
+
+// Names of the special forms, whose arguments are not evaluated like the ones of functions
+const (
+	// QUOTE is the name of the special form returning its argument as object.Quote instead of evaluating it
+	QUOTE = "quote"
+	// UNQUOTE is the name of the special form that is replaced within quoted expressions by its evaluated argument
+	UNQUOTE = "unquote"
+)
+
+/// Functions
+
+// isCallOf reports whether the call expression calls the identifier with the given name.
+func isCallOf(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+// quote returns the argument of the quote call as object.Quote.
+// The argument is cloned, so that the quoted node can be inserted into the program any number of times,
+// and calls of unquote within it are replaced by the node of their evaluated argument.
+func (e *Evaluator) quote(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 1 {
+		return newError(ERR_ARG_COUNT_MISMATCH, QUOTE, 1, len(call.Arguments))
+	}
+
+	var err object.Object
+	node := ast.Rewrite(ast.Clone(call.Arguments[0]), func(node ast.Node) ast.Node {
+		unquote, ok := node.(*ast.CallExpression)
+		if !ok || !isCallOf(unquote, UNQUOTE) || err != nil {
+			return node
+		}
+		if len(unquote.Arguments) != 1 {
+			err = newError(ERR_ARG_COUNT_MISMATCH, UNQUOTE, 1, len(unquote.Arguments))
+			return node
+		}
+
+		val := e.eval(unquote.Arguments[0], env)
+		if isError(val) {
+			err = val
+			return node
+		}
+		replacement := objectToNode(val, unquote.Pos())
+		if replacement == nil {
+			err = newError(ERR_UNQUOTE_UNSUPPORTED, val.Type())
+			return node
+		}
+		return replacement
+	})
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: node}
+}
+
+// objectToNode converts the value to the expression producing it, located at the given position.
+// Quotes are converted to a clone of the quoted expression.
+// It returns nil if the value cannot be expressed as literal, like functions.
+func objectToNode(obj object.Object, position token.Position) ast.Expression {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		if obj.Value < 0 {
+			literal := fmt.Sprintf(object.F_INTEGER, -obj.Value)
+			return &ast.PrefixExpression{
+				Token:    token.Token{Type: token.DASH, Literal: "-", Position: position},
+				Operator: "-",
+				Right:    &ast.IntegerLiteral{Token: token.Token{Type: token.INTEGER, Literal: literal, Position: position}, Value: -obj.Value},
+			}
+		}
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INTEGER, Literal: obj.Inspect(), Position: position}, Value: obj.Value}
+	case *object.Boolean:
+		if obj.Value {
+			return &ast.BooleanLiteral{Token: token.Token{Type: token.TRUE, Literal: "true", Position: position}, Value: true}
+		}
+		return &ast.BooleanLiteral{Token: token.Token{Type: token.FALSE, Literal: "false", Position: position}, Value: false}
+	case *object.String:
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: obj.Value, Position: position}, Value: obj.Value}
+	case *object.Null:
+		return &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null", Position: position}}
+	case *object.Quote:
+		if exp, ok := obj.Node.(ast.Expression); ok {
+			return ast.Clone(exp).(ast.Expression)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/smalldevshima/go-monkey/object"
+)
+
+/// Tests
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+		{`let f = fn() { quote(x) }; f()`, `x`},
+	}
+
+	for _, test := range tests {
+		checkQuoteObject(t, testEval(test.input), test.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`quote(unquote(1 - 4))`, `(-3)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote("a" + "b"))`, `ab`},
+		{`quote(unquote(null))`, `null`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let quotedInfix = quote(4 + 4); quote(unquote(4 + 4) + unquote(quotedInfix))`, `(8 + (4 + 4))`},
+	}
+
+	for _, test := range tests {
+		checkQuoteObject(t, testEval(test.input), test.expected)
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote()`, `function "quote" expects 1 arguments. got=0`},
+		{`quote(unquote(1, 2))`, `function "unquote" expects 1 arguments. got=2`},
+		{`quote(unquote(fn() {}))`, `cannot unquote value of type: @function@`},
+		{`quote(unquote(x))`, `unknown identifier: x`},
+		{`unquote(1)`, `unknown identifier: unquote`},
+		{`let m = macro() { 1 }; 1`, `macro literals can only be bound by top-level let statements`},
+	}
+
+	for _, test := range tests {
+		checkErrorObject(t, testEval(test.input), test.expected)
+	}
+}
+
+// TestQuoteClones checks that every evaluation of quote returns new nodes,
+// so that they can be inserted into the program any number of times.
+func TestQuoteClones(t *testing.T) {
+	first := testEval(`let f = fn() { quote(x + 1) }; [f(), f()]`).(*object.Array)
+	a, b := first.Elements[0].(*object.Quote), first.Elements[1].(*object.Quote)
+	if a.Node == b.Node {
+		t.Errorf("quote returned the same node twice")
+	}
+}
+
+/// helpers
+
+func checkQuoteObject(t *testing.T, obj object.Object, expected string) {
+	t.Helper()
+	quote, ok := obj.(*object.Quote)
+	if !ok {
+		t.Fatalf("obj is not *object.Quote. got=%T: (%+v)", obj, obj)
+	}
+	if quote.Node == nil {
+		t.Fatalf("quote.Node is nil")
+	}
+
+	if quote.Node.String() != expected {
+		t.Errorf("quote.Node.String is wrong. expected=%q, got=%q", expected, quote.Node.String())
+	}
+}
@@ -0,0 +1,790 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/lexer"
+	"github.com/smalldevshima/go-monkey/parser"
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Constants / Variables
+
+const (
+	// LineWidth is the width that lists are wrapped at, and that blocks are kept on a single line within
+	LineWidth = 100
+	// TabWidth is the width of an indentation tab when measuring lines
+	TabWidth = 4
+)
+
+// atomic is the precedence of expressions that never have to be parenthesized
+const atomic = parser.INDEX + 1
+
+/// Functions
+
+// Source formats the Monkey program in src.
+// It returns an error if the program cannot be parsed.
+func Source(src []byte) ([]byte, error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parser has %d errors, first: %s", len(p.Errors()), p.Errors()[0])
+	}
+	return []byte(Node(program)), nil
+}
+
+// Node returns the canonical source of the node.
+//
+// Statements are written on separate lines and blocks are indented with tabs,
+// unless a block consists of a single expression, return or throw statement and fits on the line.
+// Expressions are only parenthesized where the precedence of operators requires it.
+// Argument lists, array and hash literals are wrapped with one element per line, if they exceed the LineWidth or contain comments.
+//
+// Programs are written with their comments and single blank lines between statements are preserved.
+//
+// Parsing the source of a node produces the same node, apart from tokens and positions.
+// This holds for every node that can be produced by the parser,
+// but not for string literals containing '"' or negative integer literals, which have no source form.
+func Node(node ast.Node) string {
+	p := &printer{}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		p.comments = node.Comments
+		p.statements(node.Statements, token.Position{}, true)
+		if p.buf.Len() > 0 {
+			p.buf.WriteByte('\n')
+		}
+	case ast.Statement:
+		p.statement(node)
+	case ast.Expression:
+		p.expression(node, parser.LOWEST)
+	}
+
+	return p.buf.String()
+}
+
+// precedence returns the precedence of the operator of the expression.
+func precedence(exp ast.Expression) parser.Precedence {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.OperatorPrecedence(exp.Operator)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.IntegerLiteral:
+		// * negative literals are only created by tools like the optimizer, and are read as prefix expressions
+		if exp.Value < 0 {
+			return parser.PREFIX
+		}
+	case *ast.CallExpression:
+		if isPipeline(exp) {
+			return parser.PIPE
+		}
+		return parser.CALL
+	case *ast.IndexExpression, *ast.PropertyExpression:
+		return parser.CALL
+	}
+	return atomic
+}
+
+// isPipeline reports whether the call was written with the pipeline operator, passing its first argument on the left,
+// and can be printed as such.
+func isPipeline(ce *ast.CallExpression) bool {
+	if ce.Token.Type != token.PIPE || len(ce.Arguments) == 0 {
+		return false
+	}
+	if _, spread := ce.Arguments[0].(*ast.SpreadExpression); spread {
+		return false
+	}
+	if len(ce.Arguments) == 1 && !ce.Rparen.IsValid() {
+		// * a call on the right of the operator would take the value as its first argument instead
+		call, ok := ce.Function.(*ast.CallExpression)
+		return !ok || isPipeline(call)
+	}
+	return true
+}
+
+// isBarePipeline reports whether the pipeline passes its value into an expression other than a call, like "x |> f".
+func isBarePipeline(ce *ast.CallExpression) bool {
+	return isPipeline(ce) && len(ce.Arguments) == 1 && !ce.Rparen.IsValid()
+}
+
+// parenthesize reports whether the expression has to be parenthesized as operand of an operator with the minimum precedence.
+// Expressions ending with a block are also parenthesized as left operand, so that they are not mistaken for statements,
+// and grouped chains keep their parentheses as operand of a link, which must not be skipped by their optional links.
+func parenthesize(exp ast.Expression, minimum parser.Precedence, left bool) bool {
+	return precedence(exp) < minimum || left && endsWithBlock(exp) || minimum >= parser.CALL && ast.IsGrouped(exp)
+}
+
+// startsWithOperator reports whether the source of the expression starts with a token that is also an infix operator,
+// so that it would continue an expression preceding it.
+func startsWithOperator(exp ast.Expression, minimum parser.Precedence, left bool) bool {
+	if parenthesize(exp, minimum, left) {
+		return true
+	}
+
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		return exp.Operator == "-"
+	case *ast.IntegerLiteral:
+		return exp.Value < 0
+	case *ast.InfixExpression:
+		return startsWithOperator(exp.Left, parser.OperatorPrecedence(exp.Operator), true)
+	case *ast.CallExpression:
+		if isPipeline(exp) {
+			return startsWithOperator(exp.Arguments[0], parser.PIPE, true)
+		}
+		return startsWithOperator(exp.Function, parser.CALL, true)
+	case *ast.IndexExpression:
+		return startsWithOperator(exp.Left, parser.CALL, true)
+	case *ast.PropertyExpression:
+		return startsWithOperator(exp.Object, parser.CALL, true)
+	case *ast.ArrayLiteral:
+		return true
+	}
+	return false
+}
+
+// endsWithBlock reports whether the source of the expression ends with a block.
+func endsWithBlock(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IfExpression, *ast.TryExpression, *ast.FunctionLiteral, *ast.MacroLiteral:
+		return true
+	}
+	return false
+}
+
+// needsSemicolon reports whether the expression statement has to be terminated by a semicolon.
+// The last statement of a block has none, like statements ending with a block, unless the next statement would continue them.
+func needsSemicolon(stmt *ast.ExpressionStatement, next ast.Statement, program bool) bool {
+	if endsWithBlock(stmt.Expression) {
+		es, ok := next.(*ast.ExpressionStatement)
+		return ok && startsWithOperator(es.Expression, parser.LOWEST, false)
+	}
+	return next != nil || program
+}
+
+// end returns the position of the last token of the node, as far as it is known.
+func end(node ast.Node) token.Position {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return end(node.Value)
+	case *ast.ReturnStatement:
+		return end(node.ReturnValue)
+	case *ast.ThrowStatement:
+		return end(node.Value)
+	case *ast.ImportStatement:
+		return node.Name.Pos()
+	case *ast.ExportStatement:
+		return end(node.Statement)
+	case *ast.ExpressionStatement:
+		return end(node.Expression)
+	case *ast.BlockStatement:
+		if node.Rbrace.IsValid() {
+			return node.Rbrace
+		}
+		if len(node.Statements) > 0 {
+			return end(node.Statements[len(node.Statements)-1])
+		}
+	case *ast.StringLiteral:
+		position := node.Pos()
+		position.Line += strings.Count(node.Value, "\n")
+		return position
+	case *ast.PrefixExpression:
+		return end(node.Right)
+	case *ast.InfixExpression:
+		return end(node.Right)
+	case *ast.SpreadExpression:
+		return end(node.Value)
+	case *ast.PropertyExpression:
+		return node.Property.Pos()
+	case *ast.IndexExpression:
+		if node.Rbracket.IsValid() {
+			return node.Rbracket
+		}
+		return end(node.Index)
+	case *ast.CallExpression:
+		if node.Rparen.IsValid() {
+			return node.Rparen
+		}
+		if isBarePipeline(node) {
+			return end(node.Function)
+		}
+		if len(node.Arguments) > 0 {
+			return end(node.Arguments[len(node.Arguments)-1])
+		}
+	case *ast.ArrayLiteral:
+		if node.Rbracket.IsValid() {
+			return node.Rbracket
+		}
+		if len(node.Elements) > 0 {
+			return end(node.Elements[len(node.Elements)-1])
+		}
+	case *ast.HashLiteral:
+		if node.Rbrace.IsValid() {
+			return node.Rbrace
+		}
+		if len(node.Pairs) > 0 {
+			return end(node.Pairs[len(node.Pairs)-1].Value)
+		}
+	case *ast.FunctionLiteral:
+		return end(node.Body)
+	case *ast.MacroLiteral:
+		return end(node.Body)
+	case *ast.IfExpression:
+		if node.Otherwise != nil {
+			return end(node.Otherwise)
+		}
+		return end(node.Then)
+	case *ast.TryExpression:
+		if node.Finally != nil {
+			return end(node.Finally)
+		}
+		if node.Catch != nil {
+			return end(node.Catch)
+		}
+		return end(node.Block)
+	}
+	return node.Pos()
+}
+
+// before reports whether the position a is known and comes before b.
+func before(a, b token.Position) bool {
+	return a.IsValid() && (a.Line < b.Line || a.Line == b.Line && a.Column < b.Column)
+}
+
+// width returns the width of the text, where tabs count TabWidth.
+func width(text []byte) int {
+	w := 0
+	for _, r := range string(text) {
+		if r == '\t' {
+			w += TabWidth
+		} else {
+			w++
+		}
+	}
+	return w
+}
+
+/// Types
+
+// printer writes the canonical source of nodes to buf.
+//
+// Layouts that depend on the width of lines are tried by writing the single-line layout first,
+// and restoring a snapshot to write the multi-line layout if it does not fit.
+type printer struct {
+	buf    bytes.Buffer
+	indent int
+	// comments contains the comments that are not written yet, in source order
+	comments []*ast.Comment
+	// flat is true while a single-line layout is tried, so that lists are not wrapped
+	flat bool
+}
+
+// snapshot is the state of a printer before trying a layout.
+type snapshot struct {
+	length   int
+	comments []*ast.Comment
+}
+
+func (p *printer) save() snapshot {
+	return snapshot{length: p.buf.Len(), comments: p.comments}
+}
+
+func (p *printer) restore(s snapshot) {
+	p.buf.Truncate(s.length)
+	p.comments = s.comments
+}
+
+// fits reports whether the text written since the snapshot fits into the LineWidth.
+// Only its first line is measured, unless single is true, in which case the text must not span multiple lines.
+func (p *printer) fits(s snapshot, single bool) bool {
+	text := p.buf.Bytes()[s.length:]
+	if index := bytes.IndexByte(text, '\n'); index >= 0 {
+		if single {
+			return false
+		}
+		text = text[:index]
+	}
+
+	line := p.buf.Bytes()[:s.length]
+	line = line[bytes.LastIndexByte(line, '\n')+1:]
+	return width(line)+width(text) <= LineWidth
+}
+
+func (p *printer) write(text string) {
+	p.buf.WriteString(text)
+}
+
+// newline starts a new line with the current indentation.
+func (p *printer) newline() {
+	p.buf.WriteByte('\n')
+	p.buf.WriteString(strings.Repeat("\t", p.indent))
+}
+
+// commentBefore reports whether the next comment to be written comes before the position.
+func (p *printer) commentBefore(position token.Position) bool {
+	return len(p.comments) > 0 && position.IsValid() && before(p.comments[0].Pos(), position)
+}
+
+// commentWithin reports whether any comment that is not written yet comes after opening and before closing.
+func (p *printer) commentWithin(opening, closing token.Position) bool {
+	for _, comment := range p.comments {
+		if !before(comment.Pos(), closing) {
+			return false
+		}
+		if !before(comment.Pos(), opening) {
+			return true
+		}
+	}
+	return false
+}
+
+// commentLines writes the comments before the position on lines of their own.
+func (p *printer) commentLines(position token.Position) {
+	for p.commentBefore(position) {
+		p.newline()
+		p.write(p.comments[0].Text())
+		p.comments = p.comments[1:]
+	}
+}
+
+// statements writes the statements on separate lines, each preceded by the comments before it.
+// A comment on the last line of a statement is written after it, as are comments inside of it that were not written in a nested block.
+// Comments after the statements are written if they come before the closing position, or all remaining ones for programs.
+func (p *printer) statements(stmts []ast.Statement, closing token.Position, program bool) {
+	first := true
+	last := token.Position{}
+
+	// * start the line of an item, preserving a single blank line between items
+	startLine := func(position token.Position) {
+		if !first || !program {
+			p.buf.WriteByte('\n')
+		}
+		if !first && last.IsValid() && position.Line > last.Line+1 {
+			p.buf.WriteByte('\n')
+		}
+		p.buf.WriteString(strings.Repeat("\t", p.indent))
+		first = false
+	}
+	writeComment := func() {
+		comment := p.comments[0]
+		p.comments = p.comments[1:]
+		startLine(comment.Pos())
+		p.write(comment.Text())
+		if comment.Pos().Line > last.Line {
+			last = comment.Pos()
+		}
+	}
+
+	for index, stmt := range stmts {
+		var next ast.Statement
+		if index+1 < len(stmts) {
+			next = stmts[index+1]
+		}
+
+		for p.commentBefore(stmt.Pos()) {
+			writeComment()
+		}
+
+		startLine(stmt.Pos())
+		p.statement(stmt)
+		if es, ok := stmt.(*ast.ExpressionStatement); ok && needsSemicolon(es, next, program) {
+			p.write(";")
+		}
+		last = end(stmt)
+
+		trailing := true
+		for len(p.comments) > 0 && last.IsValid() && p.comments[0].Pos().Line <= last.Line {
+			if next != nil && !before(p.comments[0].Pos(), next.Pos()) {
+				break
+			}
+			// * comments after the closing brace of a block on the line of its last statement follow the block
+			if next == nil && !program && !before(p.comments[0].Pos(), closing) {
+				break
+			}
+			if trailing && p.comments[0].Pos().Line == last.Line {
+				p.write(" " + p.comments[0].Text())
+				p.comments = p.comments[1:]
+				continue
+			}
+			trailing = false
+			writeComment()
+		}
+	}
+
+	for p.commentBefore(closing) || program && len(p.comments) > 0 {
+		writeComment()
+	}
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let " + stmt.Name.Value + " = ")
+		p.expression(stmt.Value, parser.LOWEST)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(stmt.ReturnValue, parser.LOWEST)
+		p.write(";")
+	case *ast.ThrowStatement:
+		p.write("throw ")
+		p.expression(stmt.Value, parser.LOWEST)
+		p.write(";")
+	case *ast.ImportStatement:
+		p.write("import ")
+		p.expression(stmt.Path, parser.LOWEST)
+		p.write(" as " + stmt.Name.Value + ";")
+	case *ast.ExportStatement:
+		p.write("export ")
+		p.statement(stmt.Statement)
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, parser.LOWEST)
+	case *ast.BlockStatement:
+		p.block(stmt)
+	}
+}
+
+// expression writes the expression, which is parenthesized if its precedence is lower than the minimum.
+func (p *printer) expression(exp ast.Expression, minimum parser.Precedence) {
+	if precedence(exp) < minimum {
+		p.parenthesized(exp)
+		return
+	}
+
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.write(exp.Value)
+	case *ast.IntegerLiteral:
+		// * keep the literal as written, unless it was changed by a tool
+		if value, err := strconv.ParseInt(exp.Token.Literal, 0, 64); err == nil && value == exp.Value {
+			p.write(exp.Token.Literal)
+		} else {
+			p.write(strconv.FormatInt(exp.Value, 10))
+		}
+	case *ast.StringLiteral:
+		p.write(`"` + exp.Value + `"`)
+	case *ast.BooleanLiteral:
+		p.write(strconv.FormatBool(exp.Value))
+	case *ast.NullLiteral:
+		p.write("null")
+	case *ast.PrefixExpression:
+		p.write(exp.Operator)
+		// * keep "-(-x)" from looking like a decrement
+		if exp.Operator == "-" && startsWithOperator(exp.Right, parser.PREFIX, false) {
+			p.parenthesized(exp.Right)
+		} else {
+			p.expression(exp.Right, parser.PREFIX)
+		}
+	case *ast.InfixExpression:
+		// * operators are left-associative, so the right operand needs parentheses for equal precedence
+		precedence := parser.OperatorPrecedence(exp.Operator)
+		p.leftOperand(exp.Left, precedence)
+		p.write(" " + exp.Operator + " ")
+		p.expression(exp.Right, precedence+1)
+	case *ast.CallExpression:
+		arguments := exp.Arguments
+		if isPipeline(exp) {
+			p.leftOperand(arguments[0], parser.PIPE)
+			p.write(" |> ")
+			if isBarePipeline(exp) {
+				p.expression(exp.Function, parser.PIPE+1)
+				return
+			}
+			arguments = arguments[1:]
+		}
+		p.leftOperand(exp.Function, parser.CALL)
+		p.list("(", ")", exp.Pos(), exp.Rparen, len(arguments), func(index int) (ast.Node, ast.Node) {
+			return arguments[index], arguments[index]
+		}, func(index int) {
+			p.expression(arguments[index], parser.LOWEST)
+		})
+	case *ast.IndexExpression:
+		p.leftOperand(exp.Left, parser.CALL)
+		if exp.Optional {
+			p.write("?[")
+		} else {
+			p.write("[")
+		}
+		p.expression(exp.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.PropertyExpression:
+		p.leftOperand(exp.Object, parser.CALL)
+		if exp.Optional {
+			p.write("?.")
+		} else {
+			p.write(".")
+		}
+		p.write(exp.Property.Value)
+	case *ast.SpreadExpression:
+		p.write("...")
+		p.expression(exp.Value, parser.LOWEST)
+	case *ast.ArrayLiteral:
+		p.list("[", "]", exp.Pos(), exp.Rbracket, len(exp.Elements), func(index int) (ast.Node, ast.Node) {
+			return exp.Elements[index], exp.Elements[index]
+		}, func(index int) {
+			p.expression(exp.Elements[index], parser.LOWEST)
+		})
+	case *ast.HashLiteral:
+		p.list("{", "}", exp.Pos(), exp.Rbrace, len(exp.Pairs), func(index int) (ast.Node, ast.Node) {
+			return exp.Pairs[index].Key, exp.Pairs[index].Value
+		}, func(index int) {
+			p.expression(exp.Pairs[index].Key, parser.LOWEST)
+			p.write(": ")
+			p.expression(exp.Pairs[index].Value, parser.LOWEST)
+		})
+	case *ast.FunctionLiteral:
+		p.functionLiteral(exp)
+	case *ast.MacroLiteral:
+		p.write("macro")
+		p.list("(", ")", exp.Pos(), exp.Body.Pos(), len(exp.Parameters), func(index int) (ast.Node, ast.Node) {
+			return exp.Parameters[index], exp.Parameters[index]
+		}, func(index int) {
+			p.write(exp.Parameters[index].Value)
+		})
+		p.write(" ")
+		p.blocks([]*ast.BlockStatement{exp.Body}, func(block func(*ast.BlockStatement)) {
+			block(exp.Body)
+		})
+	case *ast.IfExpression:
+		p.blocks([]*ast.BlockStatement{exp.Then, exp.Otherwise}, func(block func(*ast.BlockStatement)) {
+			p.write("if (")
+			p.expression(exp.Condition, parser.LOWEST)
+			p.write(") ")
+			block(exp.Then)
+			if exp.Otherwise != nil {
+				p.continuation("else", exp.Then.Rbrace, exp.Otherwise.Pos())
+				block(exp.Otherwise)
+			}
+		})
+	case *ast.TryExpression:
+		p.blocks([]*ast.BlockStatement{exp.Block, exp.Catch, exp.Finally}, func(block func(*ast.BlockStatement)) {
+			p.write("try ")
+			block(exp.Block)
+			last := exp.Block
+			if exp.Catch != nil {
+				p.continuation("catch ("+exp.CatchParameter.Value+")", last.Rbrace, exp.CatchParameter.Pos())
+				block(exp.Catch)
+				last = exp.Catch
+			}
+			if exp.Finally != nil {
+				p.continuation("finally", last.Rbrace, exp.Finally.Pos())
+				block(exp.Finally)
+			}
+		})
+	}
+}
+
+// leftOperand writes the left operand of an operator with the minimum precedence.
+func (p *printer) leftOperand(exp ast.Expression, minimum parser.Precedence) {
+	if parenthesize(exp, minimum, true) {
+		p.parenthesized(exp)
+		return
+	}
+	p.expression(exp, minimum)
+}
+
+func (p *printer) parenthesized(exp ast.Expression) {
+	p.write("(")
+	p.expression(exp, parser.LOWEST)
+	p.write(")")
+}
+
+func (p *printer) functionLiteral(fl *ast.FunctionLiteral) {
+	p.write("fn")
+	if fl.Name != nil {
+		p.write(" " + fl.Name.Value)
+	}
+
+	count := len(fl.Parameters)
+	if fl.Rest != nil {
+		count++
+	}
+	p.list("(", ")", fl.Pos(), fl.Body.Pos(), count, func(index int) (ast.Node, ast.Node) {
+		if index == len(fl.Parameters) {
+			return fl.Rest, fl.Rest
+		}
+		if def := fl.Default(index); def != nil {
+			return fl.Parameters[index], def
+		}
+		return fl.Parameters[index], fl.Parameters[index]
+	}, func(index int) {
+		if index == len(fl.Parameters) {
+			p.write("..." + fl.Rest.Value)
+			return
+		}
+		p.write(fl.Parameters[index].Value)
+		if def := fl.Default(index); def != nil {
+			p.write(" = ")
+			p.expression(def, parser.LOWEST)
+		}
+	})
+
+	p.write(" ")
+	p.blocks([]*ast.BlockStatement{fl.Body}, func(block func(*ast.BlockStatement)) {
+		block(fl.Body)
+	})
+}
+
+// list writes count items enclosed by open and close and separated by commas, using item to write the item at an index.
+// The items are written on a single line if it fits, otherwise every item is written on its own line.
+//
+// The source of the list spans from opening to closing, and bounds returns the first and last node of the item at an index.
+// Lists with comments that are not written by blocks within the items are always wrapped, keeping the comments with the items:
+// a comment on the last line of an item follows the item, other comments are written on lines of their own.
+func (p *printer) list(open, close string, opening, closing token.Position, count int, bounds func(index int) (ast.Node, ast.Node), item func(index int)) {
+	p.write(open)
+
+	if p.flat {
+		p.listItems(count, item)
+		p.write(close)
+		return
+	}
+
+	if count > 0 {
+		s := p.save()
+		p.flat = true
+		p.listItems(count, item)
+		p.write(close)
+		p.flat = false
+		if p.fits(s, false) && !p.commentWithin(opening, closing) {
+			return
+		}
+		p.restore(s)
+	} else if !p.commentWithin(opening, closing) {
+		p.write(close)
+		return
+	}
+
+	p.indent++
+	for index := 0; index < count; index++ {
+		first, last := bounds(index)
+		p.commentLines(first.Pos())
+		p.newline()
+		item(index)
+
+		next := closing
+		if index < count-1 {
+			p.write(",")
+			following, _ := bounds(index + 1)
+			next = following.Pos()
+		}
+		if p.commentBefore(next) && p.comments[0].Pos().Line == end(last).Line {
+			p.write(" " + p.comments[0].Text())
+			p.comments = p.comments[1:]
+		}
+	}
+	p.commentLines(closing)
+	p.indent--
+	p.newline()
+	p.write(close)
+}
+
+func (p *printer) listItems(count int, item func(index int)) {
+	for index := 0; index < count; index++ {
+		if index > 0 {
+			p.write(", ")
+		}
+		item(index)
+	}
+}
+
+// continuation writes the keyword continuing an expression after the block ending at closing, like "else".
+// Comments between the block and the position of the next one are written before the keyword,
+// on the line of the block if they start there, so the keyword starts a new line after them.
+func (p *printer) continuation(keyword string, closing, next token.Position) {
+	if !p.commentBefore(next) {
+		p.write(" " + keyword + " ")
+		return
+	}
+
+	if p.comments[0].Pos().Line == closing.Line {
+		p.write(" " + p.comments[0].Text())
+		p.comments = p.comments[1:]
+	}
+	p.commentLines(next)
+	p.newline()
+	p.write(keyword + " ")
+}
+
+// blocks calls write with a function writing the given blocks of an expression.
+// The blocks are written on the same line as the expression if all of them can be inlined and the expression fits,
+// otherwise all of them are written on multiple lines.
+func (p *printer) blocks(blocks []*ast.BlockStatement, write func(block func(*ast.BlockStatement))) {
+	inline := true
+	for _, block := range blocks {
+		inline = inline && p.canInline(block)
+	}
+
+	if inline {
+		s := p.save()
+		flat := p.flat
+		p.flat = true
+		write(p.inlineBlock)
+		p.flat = flat
+		if p.fits(s, true) {
+			return
+		}
+		p.restore(s)
+	}
+
+	write(p.block)
+}
+
+// canInline reports whether the block can be written on a single line,
+// which is the case for blocks without comments containing at most one expression, return or throw statement.
+func (p *printer) canInline(block *ast.BlockStatement) bool {
+	if block == nil {
+		return true
+	}
+	if p.commentBefore(block.Rbrace) {
+		return false
+	}
+
+	switch len(block.Statements) {
+	case 0:
+		return true
+	case 1:
+		switch block.Statements[0].(type) {
+		case *ast.ExpressionStatement, *ast.ReturnStatement, *ast.ThrowStatement:
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) inlineBlock(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 {
+		p.write("{}")
+		return
+	}
+	p.write("{ ")
+	p.statement(block.Statements[0])
+	p.write(" }")
+}
+
+// block writes the block with its statements on separate, indented lines.
+// Empty blocks without comments are written as "{}".
+func (p *printer) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 && !p.commentBefore(block.Rbrace) {
+		p.write("{}")
+		return
+	}
+
+	flat := p.flat
+	p.flat = false
+
+	p.write("{")
+	p.indent++
+	p.statements(block.Statements, block.Rbrace, false)
+	p.indent--
+	p.newline()
+	p.write("}")
+
+	p.flat = flat
+}
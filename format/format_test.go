@@ -0,0 +1,504 @@
+package format
+
+import (
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/benchmarks"
+	"github.com/smalldevshima/go-monkey/lexer"
+	"github.com/smalldevshima/go-monkey/parser"
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Constants / Variables
+
+// astFile contains the node types, which the generator of TestRoundTrip has to produce
+const astFile = "../ast/ast.go"
+
+var formatTests = []struct {
+	name     string
+	input    string
+	expected string
+}{
+	{"empty", "", ""},
+	{"statements", "let x=5 ; return x;throw  x", lines("let x = 5;", "return x;", "throw x;")},
+	{"expression-statements", "x\ny", lines("x;", "y;")},
+	{"literals", `[1,"two",true,false,null]`, lines(`[1, "two", true, false, null];`)},
+	{"integers", "010; 7", lines("010;", "7;")},
+	{"hash", `{"a":1,"b":  2}`, lines(`{"a": 1, "b": 2};`)},
+	{"minimal-parentheses", "((a + (b * c)) - ((d - e)))", lines("a + b * c - (d - e);")},
+	{"required-parentheses", "(a + b) * c; a / (b / c); -(a + b); (-a)[0]; (a ?? b) == c", lines(
+		"(a + b) * c;",
+		"a / (b / c);",
+		"-(a + b);",
+		"(-a)[0];",
+		"(a ?? b) == c;",
+	)},
+	{"prefix", "!!a; - -a; -(-a)", lines("!!a;", "-(-a);", "-(-a);")},
+	{"postfix", "f(x)[1](2)?.y?[0]", lines("f(x)[1](2)?.y?[0];")},
+	{"grouped-chains", "(a?.b).c; (a?[0])(1); (a.b).c; (a?.b)", lines("(a?.b).c;", "(a?[0])(1);", "a.b.c;", "a?.b;")},
+	{"block-operands", "(fn(x) { x })(1); (if (a) { b } else { c }) + 1", lines(
+		"(fn(x) { x })(1);",
+		"(if (a) { b } else { c }) + 1;",
+	)},
+	{"functions", "let f = fn ( a , b = 1+1 , ...rest ) { a }; fn named() {}", lines(
+		"let f = fn(a, b = 1 + 1, ...rest) { a };",
+		"fn named() {}",
+	)},
+	{"macros", "let unless = macro ( cond , then ) { quote(if (!unquote(cond)) { unquote(then) }) }", lines(
+		"let unless = macro(cond, then) { quote(if (!unquote(cond)) { unquote(then) }) };",
+	)},
+	{"spread", "f(...args, [...xs])", lines("f(...args, [...xs]);")},
+	{"pipeline", "xs|>filter(odd)|>map(fn(x){x*2}) |>sum; (a ?? b) |> f |> g(1)(2); x |> (f(a)); x |> (y |> f); x |> f()", lines(
+		"xs |> filter(odd) |> map(fn(x) { x * 2 }) |> sum;",
+		"(a ?? b) |> f |> g(1)(2);",
+		"x |> f(a);",
+		"x |> (y |> f);",
+		"x |> f();",
+	)},
+	{"modules", "import \"lib/math.mk\"  as  math\nexport let two=math.add(1,1)", lines(
+		`import "lib/math.mk" as math;`,
+		"export let two = math.add(1, 1);",
+	)},
+	{"multi-line-block", "let f = fn(x) { let y = x; y }", lines(
+		"let f = fn(x) {",
+		"\tlet y = x;",
+		"\ty",
+		"};",
+	)},
+	{"inline-branches", "if (a) { return b; } else { c }", lines("if (a) { return b; } else { c }")},
+	{"multi-line-branches", "if (a) { b } else { let c = 1; c }", lines(
+		"if (a) {",
+		"\tb",
+		"} else {",
+		"\tlet c = 1;",
+		"\tc",
+		"}",
+	)},
+	{"try", "try { a } catch (e) { b } finally { c }", lines("try { a } catch (e) { b } finally { c }")},
+	{"block-statement-separation", "if (a) { b }\nc; if (a) { b }; -c; fn f() {}; [1]", lines(
+		"if (a) { b }",
+		"c;",
+		"if (a) { b };",
+		"-c;",
+		"fn f() {};",
+		"[1];",
+	)},
+	{"nested-blocks", "let f = fn(x) { if (x) { let y = 1; y } else { 2 } }", lines(
+		"let f = fn(x) {",
+		"\tif (x) {",
+		"\t\tlet y = 1;",
+		"\t\ty",
+		"\t} else {",
+		"\t\t2",
+		"\t}",
+		"};",
+	)},
+	{"blank-lines", "let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;", lines("let a = 1;", "", "let b = 2;", "let c = 3;")},
+	{"wrapped-arguments", "function(argumentNumberOne, argumentNumberTwo, argumentNumberThree, argumentNumberFour, argumentNumberFive)", lines(
+		"function(",
+		"\targumentNumberOne,",
+		"\targumentNumberTwo,",
+		"\targumentNumberThree,",
+		"\targumentNumberFour,",
+		"\targumentNumberFive",
+		");",
+	)},
+	{"wrapped-outer-list", "let values = [firstValueInTheList, [secondValueInTheList, thirdValueInTheList], fourthValueInTheList]", lines(
+		"let values = [",
+		"\tfirstValueInTheList,",
+		"\t[secondValueInTheList, thirdValueInTheList],",
+		"\tfourthValueInTheList",
+		"];",
+	)},
+	{"hugged-function-argument", "each(items, fn(item) { let name = item[\"name\"]; log(name) })", lines(
+		"each(items, fn(item) {",
+		"\tlet name = item[\"name\"];",
+		"\tlog(name)",
+		"});",
+	)},
+	{"long-inline-block", "let f = fn(x) { someFunction(argumentNumberOne, argumentNumberTwo) + otherFunction(argumentNumberThree) }", lines(
+		"let f = fn(x) {",
+		"\tsomeFunction(argumentNumberOne, argumentNumberTwo) + otherFunction(argumentNumberThree)",
+		"};",
+	)},
+	{"comments", "// header\n\n// doc\nlet a = 1;   // trailing\nlet f = fn() {\n  // inside\n  a\n  // end\n};\n// last", lines(
+		"// header",
+		"",
+		"// doc",
+		"let a = 1; // trailing",
+		"let f = fn() {",
+		"\t// inside",
+		"\ta",
+		"\t// end",
+		"};",
+		"// last",
+	)},
+	{"comment-in-empty-block", "let f = fn() {\n// nothing\n};", lines("let f = fn() {", "\t// nothing", "};")},
+	{"comment-inside-expression", "let a = 1 + // one\n2;\nb", lines("let a = 1 + 2;", "// one", "b;")},
+	{"comments-in-arguments", "foo(1, // one\n 2 // two\n)", lines("foo(", "\t1, // one", "\t2 // two", ");")},
+	{"comments-in-array", "let a = [\n// first\n1, 2, // two\n\n3\n// end\n];", lines(
+		"let a = [",
+		"\t// first",
+		"\t1,",
+		"\t2, // two",
+		"\t3",
+		"\t// end",
+		"];",
+	)},
+	{"comments-in-hash", "let h = {\"a\": 1, // a\n\"b\": [2 // b\n]};", lines(
+		"let h = {",
+		"\t\"a\": 1, // a",
+		"\t\"b\": [",
+		"\t\t2 // b",
+		"\t]",
+		"};",
+	)},
+	{"comments-in-parameters", "let f = fn(a, // a\nb = 2 // b\n) { a + b };", lines("let f = fn(", "\ta, // a", "\tb = 2 // b", ") { a + b };")},
+	{"comment-in-empty-list", "f(\n// nothing\n);", lines("f(", "\t// nothing", ");")},
+	{"comment-in-hugged-function", "each(items, fn(item) {\n// log\nlog(item) });", lines("each(items, fn(item) {", "\t// log", "\tlog(item)", "});")},
+	{"comment-before-else", "if (x) { a } // not x\nelse { b }\n\nif (x) { a }\n// not x\nelse { b }", lines(
+		"if (x) {",
+		"\ta",
+		"} // not x",
+		"else {",
+		"\tb",
+		"}",
+		"",
+		"if (x) {",
+		"\ta",
+		"}",
+		"// not x",
+		"else {",
+		"\tb",
+		"}",
+	)},
+	{"comment-before-catch-finally", "try { a } // catch\ncatch (e) { b } // finally\nfinally { c }", lines(
+		"try {",
+		"\ta",
+		"} // catch",
+		"catch (e) {",
+		"\tb",
+		"} // finally",
+		"finally {",
+		"\tc",
+		"}",
+	)},
+	{"comments-only", "// a\n\n// b", lines("// a", "", "// b")},
+}
+
+/// Tests
+
+func TestSource(t *testing.T) {
+	for _, test := range formatTests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := Source([]byte(test.input))
+			if err != nil {
+				t.Fatalf("Source returned error: %s", err)
+			}
+			if string(output) != test.expected {
+				t.Errorf("output is wrong.\nexpected:\n%s\ngot:\n%s", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	if _, err := Source([]byte("let = 5;")); err == nil {
+		t.Errorf("Source did not return an error for invalid input")
+	}
+}
+
+func TestNode(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     func(program *ast.Program) ast.Node
+		expected string
+	}{
+		{"statement", func(program *ast.Program) ast.Node { return program.Statements[0] }, "let f = fn(x) { x * (x + 1) };"},
+		{"expression", func(program *ast.Program) ast.Node { return program.Statements[0].(*ast.LetStatement).Value }, "fn(x) { x * (x + 1) }"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			program := parse(t, "let f = fn(x) { x * (x + 1) } // comment")
+			if output := Node(test.node(program)); output != test.expected {
+				t.Errorf("output is wrong. expected=%q, got=%q", test.expected, output)
+			}
+		})
+	}
+}
+
+// TestFormatStable checks that formatting preserves the program and that formatted source is not changed by formatting it again.
+func TestFormatStable(t *testing.T) {
+	inputs := map[string]string{}
+	for _, test := range formatTests {
+		inputs[test.name] = test.input
+	}
+	for _, program := range benchmarks.Programs() {
+		inputs["benchmark/"+program.Name] = program.Source
+	}
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			output, err := Source([]byte(input))
+			if err != nil {
+				t.Fatalf("Source returned error: %s", err)
+			}
+
+			original, formatted := parse(t, input), parse(t, string(output))
+			if !ast.Equal(original, formatted, ast.EqualOptions{IgnorePositions: true, IgnoreTokens: true}) {
+				t.Errorf("formatting changed the program.\nexpected:\n%s\ngot:\n%s", original, formatted)
+			}
+
+			again, err := Source(output)
+			if err != nil {
+				t.Fatalf("Source returned error for formatted source: %s", err)
+			}
+			if string(again) != string(output) {
+				t.Errorf("formatting is not idempotent.\nfirst:\n%s\nsecond:\n%s", output, again)
+			}
+		})
+	}
+}
+
+// TestRoundTrip checks that parsing the source of randomly generated programs produces the same programs.
+// It fails if the generator does not produce every node type declared in astFile.
+func TestRoundTrip(t *testing.T) {
+	count := 2000
+	if testing.Short() {
+		count = 200
+	}
+
+	// names of the node types in the generated programs
+	generated := map[string]bool{}
+
+	for seed := int64(0); seed < int64(count); seed++ {
+		g := &generator{rand: rand.New(rand.NewSource(seed))}
+		program := g.program()
+		ast.Inspect(program, func(node ast.Node) bool {
+			if node != nil {
+				generated[reflect.TypeOf(node).Elem().Name()] = true
+			}
+			return true
+		})
+
+		source := Node(program)
+		p := parser.New(lexer.New(source))
+		parsed := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("seed %d: parser has %d errors, first: %s\nsource:\n%s", seed, len(p.Errors()), p.Errors()[0], source)
+		}
+
+		if !ast.Equal(program, parsed, ast.EqualOptions{IgnorePositions: true, IgnoreTokens: true}) {
+			t.Fatalf("seed %d: parsed program is different.\nsource:\n%s\nexpected:\n%s\ngot:\n%s", seed, source, program, parsed)
+		}
+	}
+
+	for _, name := range declaredNodeTypes(t) {
+		if !generated[name] {
+			t.Errorf("generator does not produce nodes of type ast.%s", name)
+		}
+	}
+}
+
+/// helpers
+
+// lines joins the lines of an expected output.
+func lines(lines ...string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// declaredNodeTypes returns the names of the node types declared in astFile.
+func declaredNodeTypes(t *testing.T) []string {
+	t.Helper()
+	file, err := goparser.ParseFile(gotoken.NewFileSet(), astFile, nil, 0)
+	if err != nil {
+		t.Fatalf("could not parse %s: %s", astFile, err)
+	}
+
+	declared := []string{}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*goast.FuncDecl)
+		if !ok || fn.Recv == nil || fn.Name.Name != "TokenLiteral" {
+			continue
+		}
+		if star, ok := fn.Recv.List[0].Type.(*goast.StarExpr); ok {
+			declared = append(declared, star.X.(*goast.Ident).Name)
+		}
+	}
+	return declared
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has %d errors, first: %s", len(p.Errors()), p.Errors()[0])
+	}
+	return program
+}
+
+// generator generates random programs, which consist of nodes that can be produced by the parser.
+type generator struct {
+	rand  *rand.Rand
+	depth int
+}
+
+func (g *generator) program() *ast.Program {
+	program := &ast.Program{}
+	for i := g.rand.Intn(5); i > 0; i-- {
+		switch g.rand.Intn(8) {
+		// * imports and exports are only allowed at the top level
+		case 0:
+			path := &ast.StringLiteral{Value: "lib.mk"}
+			program.Statements = append(program.Statements, &ast.ImportStatement{Path: path, Name: g.identifier()})
+		case 1:
+			let := &ast.LetStatement{Name: g.identifier(), Value: g.expression()}
+			program.Statements = append(program.Statements, &ast.ExportStatement{Statement: let})
+		default:
+			program.Statements = append(program.Statements, g.statement())
+		}
+	}
+	return program
+}
+
+func (g *generator) statement() ast.Statement {
+	switch g.rand.Intn(6) {
+	case 0:
+		return &ast.LetStatement{Name: g.identifier(), Value: g.expression()}
+	case 1:
+		return &ast.ReturnStatement{ReturnValue: g.expression()}
+	case 2:
+		return &ast.ThrowStatement{Value: g.expression()}
+	}
+	return &ast.ExpressionStatement{Expression: g.expression()}
+}
+
+func (g *generator) block() *ast.BlockStatement {
+	block := &ast.BlockStatement{}
+	for i := g.rand.Intn(3); i > 0; i-- {
+		block.Statements = append(block.Statements, g.statement())
+	}
+	return block
+}
+
+func (g *generator) identifier() *ast.Identifier {
+	names := []string{"a", "b", "value", "fn_", "iffy", "x_y"}
+	return &ast.Identifier{Value: names[g.rand.Intn(len(names))]}
+}
+
+func (g *generator) expressions(spread bool) []ast.Expression {
+	exps := []ast.Expression{}
+	for i := g.rand.Intn(4); i > 0; i-- {
+		if spread && g.rand.Intn(4) == 0 {
+			exps = append(exps, &ast.SpreadExpression{Value: g.expression()})
+		} else {
+			exps = append(exps, g.expression())
+		}
+	}
+	return exps
+}
+
+func (g *generator) expression() ast.Expression {
+	g.depth++
+	defer func() { g.depth-- }()
+
+	kinds := 5
+	if g.depth < 4 {
+		kinds = 17
+	}
+
+	switch g.rand.Intn(kinds) {
+	case 0:
+		return g.identifier()
+	case 1:
+		return &ast.IntegerLiteral{Value: g.rand.Int63n(1000)}
+	case 2:
+		chars := []string{"a", " ", "\\", "\n", "//", "é", "'", "{", "}"}
+		value := ""
+		for i := g.rand.Intn(4); i > 0; i-- {
+			value += chars[g.rand.Intn(len(chars))]
+		}
+		return &ast.StringLiteral{Value: value}
+	case 3:
+		return &ast.BooleanLiteral{Value: g.rand.Intn(2) == 0}
+	case 4:
+		return &ast.NullLiteral{}
+	case 5:
+		operators := []string{"!", "-"}
+		return &ast.PrefixExpression{Operator: operators[g.rand.Intn(len(operators))], Right: g.expression()}
+	case 6, 7:
+		operators := []string{"+", "-", "*", "/", "<", ">", "==", "!=", "??"}
+		return &ast.InfixExpression{Operator: operators[g.rand.Intn(len(operators))], Left: g.expression(), Right: g.expression()}
+	case 8:
+		call := &ast.CallExpression{Function: g.expression(), Arguments: g.expressions(true)}
+		if g.rand.Intn(3) == 0 {
+			call.Token = token.Token{Type: token.PIPE, Literal: "|>"}
+			call.Arguments = append([]ast.Expression{g.expression()}, call.Arguments...)
+		}
+		return call
+	case 9:
+		return &ast.IndexExpression{Left: g.expression(), Index: g.expression(), Optional: g.rand.Intn(2) == 0}
+	case 10:
+		return &ast.PropertyExpression{Object: g.expression(), Property: g.identifier(), Optional: g.rand.Intn(2) == 0}
+	case 11:
+		return &ast.ArrayLiteral{Elements: g.expressions(true)}
+	case 12:
+		hash := &ast.HashLiteral{}
+		for i := g.rand.Intn(3); i > 0; i-- {
+			hash.Pairs = append(hash.Pairs, ast.HashPair{Key: g.expression(), Value: g.expression()})
+		}
+		return hash
+	case 13:
+		fl := &ast.FunctionLiteral{Body: g.block()}
+		if g.rand.Intn(2) == 0 {
+			fl.Name = g.identifier()
+		}
+		// * parameters with default values have to follow the ones without
+		defaults := false
+		for i := g.rand.Intn(4); i > 0; i-- {
+			defaults = defaults || g.rand.Intn(3) == 0
+			var def ast.Expression
+			if defaults {
+				def = g.expression()
+			}
+			fl.Parameters = append(fl.Parameters, g.identifier())
+			fl.Defaults = append(fl.Defaults, def)
+		}
+		if g.rand.Intn(3) == 0 {
+			fl.Rest = g.identifier()
+		}
+		return fl
+	case 14:
+		ie := &ast.IfExpression{Condition: g.expression(), Then: g.block()}
+		if g.rand.Intn(2) == 0 {
+			ie.Otherwise = g.block()
+		}
+		return ie
+	case 15:
+		te := &ast.TryExpression{Block: g.block()}
+		if catch := g.rand.Intn(3); catch > 0 {
+			te.CatchParameter = g.identifier()
+			te.Catch = g.block()
+		}
+		if te.Catch == nil || g.rand.Intn(2) == 0 {
+			te.Finally = g.block()
+		}
+		return te
+	case 16:
+		ml := &ast.MacroLiteral{Body: g.block()}
+		for i := g.rand.Intn(3); i > 0; i-- {
+			ml.Parameters = append(ml.Parameters, g.identifier())
+		}
+		return ml
+	}
+	panic("unreachable")
+}
@@ -34,10 +34,12 @@ type Lexer struct {
 	readPosition int
 	// current char under examination
 	char byte
+	// line and column of the current char
+	line, column int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
@@ -47,14 +49,13 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.skipWhitespace()
 
+	position := token.Position{Line: l.line, Column: l.column}
+
 	switch l.char {
 	//* operators
 	case '=':
 		if l.peekChar() == '=' {
-			char := l.char
-			l.readChar()
-			literal := string(char) + string(l.char)
-			tok = token.Token{Type: token.EQ, Literal: literal}
+			tok = l.readTwoCharToken(token.EQ)
 		} else {
 			tok = newToken(token.ASSIGN, l.char)
 		}
@@ -65,13 +66,15 @@ func (l *Lexer) NextToken() token.Token {
 	case '*':
 		tok = newToken(token.ASTERISK, l.char)
 	case '/':
-		tok = newToken(token.SLASH, l.char)
+		if l.peekChar() == '/' {
+			tok.Type = token.COMMENT
+			tok.Literal = l.readComment()
+		} else {
+			tok = newToken(token.SLASH, l.char)
+		}
 	case '!':
 		if l.peekChar() == '=' {
-			char := l.char
-			l.readChar()
-			literal := string(char) + string(l.char)
-			tok = token.Token{Type: token.NEQ, Literal: literal}
+			tok = l.readTwoCharToken(token.NEQ)
 		} else {
 			tok = newToken(token.BANG, l.char)
 		}
@@ -79,6 +82,24 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LT, l.char)
 	case '>':
 		tok = newToken(token.GT, l.char)
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			tok = l.readTwoCharToken(token.NULLISH)
+		case '[':
+			tok = l.readTwoCharToken(token.OPTIONAL_LBRACKET)
+		case '.':
+			tok = l.readTwoCharToken(token.OPTIONAL_DOT)
+		default:
+			tok = newToken(token.ILLEGAL, l.char)
+		}
+
+	case '|':
+		if l.peekChar() == '>' {
+			tok = l.readTwoCharToken(token.PIPE)
+		} else {
+			tok = newToken(token.ILLEGAL, l.char)
+		}
 
 	//* delimiters
 	case '"':
@@ -88,6 +109,16 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.SEMICOLON, l.char)
 	case ',':
 		tok = newToken(token.COMMA, l.char)
+	case ':':
+		tok = newToken(token.COLON, l.char)
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(1) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.char)
+		}
 	case '(':
 		tok = newToken(token.LPAREN, l.char)
 	case ')':
@@ -109,17 +140,20 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.char) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Position = position
 			// return immediately to not advance read position further
 			return tok
 		} else if isDigit(l.char) {
 			tok.Literal = l.readInteger()
 			tok.Type = token.INTEGER
+			tok.Position = position
 			// return immediately to not advance read position further
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.char)
 		}
 	}
+	tok.Position = position
 	l.readChar()
 	return tok
 }
@@ -129,6 +163,11 @@ func (l *Lexer) NextToken() token.Token {
 //
 // If the read position exceeds the size of the input, then the char field is set to 0.
 func (l *Lexer) readChar() {
+	if l.char == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
 	if l.readPosition >= len(l.input) {
 		l.char = 0
 	} else {
@@ -146,6 +185,21 @@ func (l *Lexer) peekChar() byte {
 	return l.input[l.readPosition]
 }
 
+// peekCharAt returns the character the given offset after the current read position without advancing positions.
+func (l *Lexer) peekCharAt(offset int) byte {
+	if l.readPosition+offset >= len(l.input) {
+		return 0
+	}
+	return l.input[l.readPosition+offset]
+}
+
+// readTwoCharToken consumes the current and the next character and returns them as a token of the given type.
+func (l *Lexer) readTwoCharToken(tokenType token.TokenType) token.Token {
+	char := l.char
+	l.readChar()
+	return token.Token{Type: tokenType, Literal: string(char) + string(l.char)}
+}
+
 // readIdentifier consumes and returns a whole word up to the next character where isLetter=false.
 func (l *Lexer) readIdentifier() string {
 	start := l.position
@@ -175,6 +229,16 @@ func (l *Lexer) readString() string {
 	return l.input[position:l.position]
 }
 
+// readComment consumes and returns a line comment including the leading "//" up to the end of the line.
+// The current char is left at the last character of the comment.
+func (l *Lexer) readComment() string {
+	start := l.position
+	for next := l.peekChar(); next != '\n' && next != '\r' && next != 0; next = l.peekChar() {
+		l.readChar()
+	}
+	return l.input[start:l.readPosition]
+}
+
 // skipWhitespace consumes the input until the next character where isWhitespace=false.
 func (l *Lexer) skipWhitespace() {
 	for isWhitespace(l.char) {
@@ -3,6 +3,7 @@ package lexer
 import (
 	"testing"
 
+	"github.com/smalldevshima/go-monkey/benchmarks"
 	"github.com/smalldevshima/go-monkey/token"
 )
 
@@ -90,7 +91,7 @@ var (
 	}
 	testOperators = lexerTest{
 		name:  "operators",
-		input: `+ - * / ! < > == !=`,
+		input: `+ - * / ! < > == != ?? |> ?[ ?. : ? | ... ..`,
 		expectedTokens: []token.Token{
 			{Type: token.PLUS, Literal: "+"},
 			{Type: token.DASH, Literal: "-"},
@@ -101,19 +102,56 @@ var (
 			{Type: token.GT, Literal: ">"},
 			{Type: token.EQ, Literal: "=="},
 			{Type: token.NEQ, Literal: "!="},
+			{Type: token.NULLISH, Literal: "??"},
+			{Type: token.PIPE, Literal: "|>"},
+			{Type: token.OPTIONAL_LBRACKET, Literal: "?["},
+			{Type: token.OPTIONAL_DOT, Literal: "?."},
+			{Type: token.COLON, Literal: ":"},
+			{Type: token.ILLEGAL, Literal: "?"},
+			{Type: token.ILLEGAL, Literal: "|"},
+			{Type: token.ELLIPSIS, Literal: "..."},
+			{Type: token.DOT, Literal: "."},
+			{Type: token.DOT, Literal: "."},
 		},
 	}
 	testKeywords = lexerTest{
 		name:  "keywords",
-		input: `fn return true false let if else`,
+		input: `fn macro return true false null let if else try catch finally throw import export as`,
 		expectedTokens: []token.Token{
 			{Type: token.FUNCTION, Literal: "fn"},
+			{Type: token.MACRO, Literal: "macro"},
 			{Type: token.RETURN, Literal: "return"},
 			{Type: token.TRUE, Literal: "true"},
 			{Type: token.FALSE, Literal: "false"},
+			{Type: token.NULL, Literal: "null"},
 			{Type: token.LET, Literal: "let"},
 			{Type: token.IF, Literal: "if"},
 			{Type: token.ELSE, Literal: "else"},
+			{Type: token.TRY, Literal: "try"},
+			{Type: token.CATCH, Literal: "catch"},
+			{Type: token.FINALLY, Literal: "finally"},
+			{Type: token.THROW, Literal: "throw"},
+			{Type: token.IMPORT, Literal: "import"},
+			{Type: token.EXPORT, Literal: "export"},
+			{Type: token.AS, Literal: "as"},
+		},
+	}
+	testComments = lexerTest{
+		name:  "line comments",
+		input: "// leading\nlet x = 10 / 2; // trailing\r\n//\nx //last",
+		expectedTokens: []token.Token{
+			{Type: token.COMMENT, Literal: "// leading"},
+			{Type: token.LET, Literal: "let"},
+			{Type: token.IDENTIFIER, Literal: "x"},
+			{Type: token.ASSIGN, Literal: "="},
+			{Type: token.INTEGER, Literal: "10"},
+			{Type: token.SLASH, Literal: "/"},
+			{Type: token.INTEGER, Literal: "2"},
+			{Type: token.SEMICOLON, Literal: ";"},
+			{Type: token.COMMENT, Literal: "// trailing"},
+			{Type: token.COMMENT, Literal: "//"},
+			{Type: token.IDENTIFIER, Literal: "x"},
+			{Type: token.COMMENT, Literal: "//last"},
 		},
 	}
 )
@@ -127,6 +165,7 @@ func TestNextToken(t *testing.T) {
 		testFunctionCall,
 		testOperators,
 		testKeywords,
+		testComments,
 	}
 	for index, lexTest := range lexerTests {
 		good := t.Run(lexTest.name, func(tt *testing.T) {
@@ -159,6 +198,46 @@ func TestNextToken(t *testing.T) {
 	}
 }
 
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n\tx + \"ab\"; // c\n"
+	expected := []token.Position{
+		{Line: 1, Column: 1},
+		{Line: 1, Column: 5},
+		{Line: 1, Column: 7},
+		{Line: 1, Column: 9},
+		{Line: 1, Column: 10},
+		{Line: 2, Column: 2},
+		{Line: 2, Column: 4},
+		{Line: 2, Column: 6},
+		{Line: 2, Column: 10},
+		{Line: 2, Column: 12},
+		{Line: 3, Column: 1},
+	}
+
+	lex := New(input)
+	for index, position := range expected {
+		have := lex.NextToken()
+		if have.Position != position {
+			t.Errorf("token[%d] %q - position wrong. expected=%s, have=%s", index, have.Literal, position, have.Position)
+		}
+	}
+}
+
+/// Benchmarks
+
+func BenchmarkLexer(b *testing.B) {
+	for _, program := range benchmarks.Programs() {
+		b.Run(program.Name, func(b *testing.B) {
+			b.SetBytes(int64(len(program.Source)))
+			for i := 0; i < b.N; i++ {
+				lex := New(program.Source)
+				for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+				}
+			}
+		})
+	}
+}
+
 /// Types
 
 type lexerTest struct {
@@ -1,19 +1,575 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
 
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/benchmarks"
+	"github.com/smalldevshima/go-monkey/compiler"
+	"github.com/smalldevshima/go-monkey/evaluator"
+	"github.com/smalldevshima/go-monkey/format"
+	"github.com/smalldevshima/go-monkey/lexer"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/optimizer"
+	"github.com/smalldevshima/go-monkey/parser"
 	"github.com/smalldevshima/go-monkey/repl"
+	"github.com/smalldevshima/go-monkey/resolver"
+	"github.com/smalldevshima/go-monkey/token"
+	"github.com/smalldevshima/go-monkey/vm"
 )
 
 func main() {
+	flag.Usage = usage
+	engine := flag.String("engine", string(repl.ENGINE_EVAL), "the engine executing programs, either \"eval\" or \"vm\"")
+	optimize := flag.Bool("optimize", false, "fold constant expressions and prune constant branches before executing or compiling programs")
+	cpuProfile := flag.String("cpuprofile", "", "write a cpu profile of the command to the file")
+	memProfile := flag.String("memprofile", "", "write a heap profile to the file after the command finished")
+	searchPath := flag.String("path", os.Getenv("MONKEYPATH"), "the directories searched for imported modules, separated like PATH, defaults to $MONKEYPATH")
+	flag.Parse()
+
+	if *engine != string(repl.ENGINE_EVAL) && *engine != string(repl.ENGINE_VM) {
+		fmt.Fprintf(os.Stderr, "unknown engine %q\n", *engine)
+		os.Exit(2)
+	}
+
+	options := repl.Options{Engine: repl.Engine(*engine), Optimize: *optimize, SearchPaths: filepath.SplitList(*searchPath)}
+
+	if *cpuProfile != "" {
+		file, err := os.Create(*cpuProfile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	exitCode := runCommand(options)
+
+	// * profiles have to be written before exiting, since deferred calls are not run by os.Exit
+	if *cpuProfile != "" {
+		pprof.StopCPUProfile()
+	}
+	if *memProfile != "" {
+		if err := writeHeapProfile(*memProfile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+		}
+	}
+	os.Exit(exitCode)
+}
+
+// runCommand runs the command given by the arguments and returns the exit code for the process.
+func runCommand(options repl.Options) int {
+	switch flag.Arg(0) {
+	case "":
+	case "compile":
+		return compileCommand(flag.Args()[1:], options)
+	case "disasm":
+		return disasmCommand(flag.Args()[1:], options)
+	case "check":
+		return checkCommand(flag.Args()[1:])
+	case "bench":
+		return benchCommand(flag.Args()[1:], options)
+	case "fmt":
+		return fmtCommand(flag.Args()[1:])
+	case "tokens":
+		return tokensCommand(flag.Args()[1:])
+	case "ast":
+		return astCommand(flag.Args()[1:])
+	default:
+		return runScript(flag.Arg(0), options)
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("Hello %s! This is the Monkey programming language REPL!\n", user.Username)
 	fmt.Printf("Feel free to type in some code!\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, options)
+	return 0
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "usage:\n")
+	fmt.Fprintf(out, "  monkey [flags]                     start the REPL\n")
+	fmt.Fprintf(out, "  monkey [flags] <file>              run a program, or a compiled program with the vm\n")
+	fmt.Fprintf(out, "  monkey compile [-o <out>] <file>   compile a program to a %s file\n", compiler.FileExtension)
+	fmt.Fprintf(out, "  monkey disasm <file>               print the bytecode of a program or a compiled program\n")
+	fmt.Fprintf(out, "  monkey check <file>...             report unknown identifiers, shadowed and unused bindings\n")
+	fmt.Fprintf(out, "  monkey bench [<file>...]           benchmark the phases of programs, by default of the built-in benchmark programs\n")
+	fmt.Fprintf(out, "  monkey fmt [-l] [-w] [<file>...]   format programs, or the standard input\n")
+	fmt.Fprintf(out, "  monkey tokens [-json] [<file>]     print the tokens of a program, or of the standard input\n")
+	fmt.Fprintf(out, "  monkey ast [-json] [<file>]        print the syntax tree of a program, or of the standard input\n")
+	fmt.Fprintf(out, "flags:\n")
+	flag.PrintDefaults()
+}
+
+// runScript executes the Monkey program in the given file and returns the exit code for the process.
+// Compiled programs are always executed by the vm.
+func runScript(filename string, options repl.Options) int {
+	input, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if compiler.IsBytecode(input) {
+		bytecode, err := compiler.ReadBytecode(bytes.NewReader(input))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", filename, err)
+			return 1
+		}
+		if !repl.RunBytecode(bytecode, os.Stdout) {
+			return 1
+		}
+		return 0
+	}
+
+	options.Path = filename
+	if !repl.Run(string(input), os.Stdout, options) {
+		return 1
+	}
+	return 0
+}
+
+// compileCommand compiles a Monkey program and writes the bytecode to a file.
+func compileCommand(args []string, options repl.Options) int {
+	flags := flag.NewFlagSet("compile", flag.ExitOnError)
+	output := flags.String("o", "", "the output file, defaults to the input file with the extension "+compiler.FileExtension)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey compile [-o <out>] <file>")
+		return 2
+	}
+
+	filename := flags.Arg(0)
+	bytecode, err := compileFile(filename, options)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(filename, filepath.Ext(filename)) + compiler.FileExtension
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+
+	if _, err := bytecode.WriteTo(file); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// disasmCommand prints the disassembled bytecode of a compiled program, or of a program compiled on the fly.
+func disasmCommand(args []string, options repl.Options) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey disasm <file>")
+		return 2
+	}
+
+	bytecode, err := compileFile(args[0], options)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Print(bytecode.Disassemble())
+	return 0
+}
+
+// checkCommand resolves the Monkey programs and prints their diagnostics.
+// It fails if any program cannot be parsed or has diagnostics of severity error.
+func checkCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey check <file>...")
+		return 2
+	}
+
+	// * quoted expressions may be left in the program and are resolved like calls of builtins
+	builtins := []string{evaluator.QUOTE, evaluator.UNQUOTE}
+	for _, builtin := range evaluator.Builtins() {
+		builtins = append(builtins, builtin.Name)
+	}
+
+	exitCode := 0
+	for _, filename := range args {
+		input, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(input)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			for _, msg := range p.Errors() {
+				fmt.Printf("%s: %s: %s\n", filename, resolver.SEVERITY_ERROR, msg)
+			}
+			exitCode = 1
+			continue
+		}
+
+		program, err = expandMacros(program)
+		if err != nil {
+			fmt.Printf("%s: %s: %s\n", filename, resolver.SEVERITY_ERROR, err)
+			exitCode = 1
+			continue
+		}
+
+		resolution := resolver.Resolve(program, builtins...)
+		for _, diagnostic := range resolution.Diagnostics {
+			fmt.Printf("%s:%s\n", filename, diagnostic)
+		}
+		if resolution.HasErrors() {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// benchCommand benchmarks lexing, parsing and executing the Monkey programs with the selected engine.
+// Without arguments, the built-in benchmark programs are used.
+func benchCommand(args []string, options repl.Options) int {
+	programs := []benchmarks.Program{}
+	if len(args) == 0 {
+		programs = benchmarks.Programs()
+	}
+	for _, filename := range args {
+		input, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		programs = append(programs, benchmarks.Program{Name: filename, Source: string(input)})
+	}
+
+	// benchPhase runs a phase of a program once, returning the result of executing phases
+	type benchPhase struct {
+		name string
+		run  func() object.Object
+	}
+
+	exitCode := 0
+	for _, program := range programs {
+		source := program.Source
+		p := parser.New(lexer.New(source))
+		parsed := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			fmt.Fprintf(os.Stderr, "%s: parser has %d errors, first: %s\n", program.Name, len(p.Errors()), p.Errors()[0])
+			exitCode = 1
+			continue
+		}
+		parsed, err := expandMacros(parsed)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", program.Name, err)
+			exitCode = 1
+			continue
+		}
+		if options.Optimize {
+			parsed = optimizer.Optimize(parsed)
+		}
+
+		phases := []benchPhase{
+			{"lex", func() object.Object {
+				l := lexer.New(source)
+				for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+				}
+				return nil
+			}},
+			{"parse", func() object.Object {
+				parser.New(lexer.New(source)).ParseProgram()
+				return nil
+			}},
+		}
+
+		if options.Engine == repl.ENGINE_VM {
+			comp := compiler.New()
+			if err := comp.Compile(parsed); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: compiler error: %s\n", program.Name, err)
+				exitCode = 1
+				continue
+			}
+			bytecode := comp.Bytecode()
+			phases = append(phases,
+				benchPhase{"compile", func() object.Object {
+					compiler.New().Compile(parsed)
+					return nil
+				}},
+				benchPhase{"run", func() object.Object { return vm.New(bytecode).Run() }},
+			)
+		} else {
+			phases = append(phases, benchPhase{"eval", func() object.Object { return evaluator.Eval(parsed, object.NewEnvironment()) }})
+		}
+
+		for _, phase := range phases {
+			// * a failing program would only benchmark how fast it fails
+			if result := phase.run(); result != nil && result.Type() == object.O_ERROR {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", program.Name, result.Inspect())
+				exitCode = 1
+				break
+			}
+
+			runs, elapsed, allocated, allocs := benchmark(func() { phase.run() })
+			fmt.Printf("%-24s %8d\t%10d ns/op\t%d B/op\t%d allocs/op\n",
+				program.Name+"/"+phase.name, runs, elapsed.Nanoseconds()/int64(runs), allocated/uint64(runs), allocs/uint64(runs))
+		}
+	}
+	return exitCode
+}
+
+// benchmark runs the function repeatedly, doubling the number of runs until they take at least a second.
+// It returns the number of runs of the last round, their duration, and the bytes and number of allocations they made.
+func benchmark(run func()) (int, time.Duration, uint64, uint64) {
+	const minDuration = time.Second
+
+	var before, after runtime.MemStats
+	for runs := 1; ; runs *= 2 {
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		for i := 0; i < runs; i++ {
+			run()
+		}
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+
+		if elapsed >= minDuration || runs >= 1<<30 {
+			return runs, elapsed, after.TotalAlloc - before.TotalAlloc, after.Mallocs - before.Mallocs
+		}
+	}
+}
+
+// fmtCommand formats Monkey programs and prints them, or the standard input if no files are given.
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	list := flags.Bool("l", false, "list the files whose formatting differs instead of printing them")
+	write := flags.Bool("w", false, "write the result to the files instead of printing them")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		output, err := format.Source(input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "<stdin>: %s\n", err)
+			return 1
+		}
+		os.Stdout.Write(output)
+		return 0
+	}
+
+	exitCode := 0
+	for _, filename := range flags.Args() {
+		input, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			continue
+		}
+
+		output, err := format.Source(input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", filename, err)
+			exitCode = 1
+			continue
+		}
+
+		changed := !bytes.Equal(input, output)
+		if *list && changed {
+			fmt.Println(filename)
+		}
+		if *write && changed {
+			info, err := os.Stat(filename)
+			if err == nil {
+				err = os.WriteFile(filename, output, info.Mode().Perm())
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				exitCode = 1
+			}
+		}
+		if !*list && !*write {
+			os.Stdout.Write(output)
+		}
+	}
+	return exitCode
+}
+
+// tokensCommand prints the tokens of a Monkey program one per line, or as JSON array.
+func tokensCommand(args []string) int {
+	flags := flag.NewFlagSet("tokens", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the tokens as JSON array")
+	flags.Parse(args)
+
+	if flags.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey tokens [-json] [<file>]")
+		return 2
+	}
+	name, input, err := readInput(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	tokens := []token.Token{}
+	l := lexer.New(string(input))
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	if *asJSON {
+		return printJSON(name, tokens)
+	}
+	for _, tok := range tokens {
+		fmt.Printf("%s\t%s\t%q\n", tok.Position, tok.Type, tok.Literal)
+	}
+	return 0
+}
+
+// astCommand prints the syntax tree of a Monkey program as indented list of nodes, or as JSON.
+// The JSON representation is described in package ast and can be decoded into an ast.Program.
+func astCommand(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the syntax tree as JSON")
+	flags.Parse(args)
+
+	if flags.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey ast [-json] [<file>]")
+		return 2
+	}
+	name, input, err := readInput(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(input)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, msg)
+		}
+		return 1
+	}
+
+	if *asJSON {
+		return printJSON(name, program)
+	}
+	depth := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		fmt.Printf("%s%s %s %q\n", strings.Repeat("  ", depth), strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."), node.Pos(), node.TokenLiteral())
+		depth++
+		return true
+	})
+	return 0
+}
+
+// readInput reads the file given by the first argument, or the standard input if there is none.
+// It returns the name of the input for messages.
+func readInput(args []string) (string, []byte, error) {
+	if len(args) == 0 {
+		input, err := io.ReadAll(os.Stdin)
+		return "<stdin>", input, err
+	}
+	input, err := os.ReadFile(args[0])
+	return args[0], input, err
+}
+
+// printJSON prints the value as indented JSON and returns the exit code for the process.
+func printJSON(name string, value interface{}) int {
+	output, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return 1
+	}
+	fmt.Println(string(output))
+	return 0
+}
+
+// compileFile returns the bytecode of the program in the file, which is either compiled or read if it is already compiled.
+func compileFile(filename string, options repl.Options) (*compiler.Bytecode, error) {
+	input, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if compiler.IsBytecode(input) {
+		bytecode, err := compiler.ReadBytecode(bytes.NewReader(input))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		return bytecode, nil
+	}
+
+	p := parser.New(lexer.New(string(input)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s: parser has %d errors, first: %s", filename, len(p.Errors()), p.Errors()[0])
+	}
+
+	program, err = expandMacros(program)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	if options.Optimize {
+		program = optimizer.Optimize(program)
+	}
+
+	comp := compiler.New()
+	comp.Loader = repl.NewLoader(options)
+	comp.Path = filename
+	if err := comp.Compile(program); err != nil {
+		return nil, fmt.Errorf("%s: compiler error: %w", filename, err)
+	}
+	return comp.Bytecode(), nil
+}
+
+// expandMacros defines the macros of the program and expands their calls.
+func expandMacros(program *ast.Program) (*ast.Program, error) {
+	macros := object.NewEnvironment()
+	evaluator.DefineMacros(program, macros)
+	return evaluator.ExpandMacros(program, macros)
+}
+
+// writeHeapProfile writes a profile of the memory allocated by the command to the file.
+func writeHeapProfile(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// * collect garbage, so that the profile contains up-to-date statistics
+	runtime.GC()
+	return pprof.WriteHeapProfile(file)
 }
@@ -0,0 +1,143 @@
+package module
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/lexer"
+	"github.com/smalldevshima/go-monkey/parser"
+)
+
+/// Constants / Variables
+
+// ErrNotFound is returned by Loader.Resolve if no file exists for the path of a module.
+var ErrNotFound = errors.New("cannot find module")
+
+/// Types
+
+// Loader finds, reads and parses the source files of modules imported by Monkey programs.
+// Every module is loaded once, later loads of the same file return the cached module.
+// The zero value is a Loader that reads from the file system of the operating system
+// and resolves paths relative to the importing file only.
+type Loader struct {
+	// FS is the file system that modules are read from, like an embed.FS, an fstest.MapFS or the result of os.DirFS.
+	// Paths are slash-separated and relative to its root, as usual for io/fs, so imports cannot escape it.
+	// Absolute import paths start at its root. A nil FS reads from the file system of the operating system.
+	FS fs.FS
+	// SearchPaths are the directories searched for modules that are not found relative to the importing file
+	SearchPaths []string
+	// Prepare is applied to the program of every loaded module, e.g. to expand macros or to optimize it.
+	// A nil Prepare leaves the program as parsed.
+	Prepare func(program *ast.Program) (*ast.Program, error)
+
+	// loaded modules by resolved path
+	modules map[string]*Module
+}
+
+// Module is a parsed source file, which may be imported by other programs.
+type Module struct {
+	// Path is the cleaned path of the source file within the file system of the Loader, which identifies the module
+	Path    string
+	Program *ast.Program
+	// Exports are the names bound by the top-level export statements of the program in order
+	Exports []string
+}
+
+// Resolve returns the path of the source file of the module imported with the given path by the file from.
+// Relative paths are looked up relative to the directory of from first, then in every search path in order.
+// An empty from stands for a program that is not read from a file, so relative paths start in the working directory,
+// or the root of the FS.
+func (l *Loader) Resolve(name, from string) (string, error) {
+	name, from = filepath.ToSlash(name), filepath.ToSlash(from)
+	candidates := []string{name}
+	if !path.IsAbs(name) {
+		candidates = []string{path.Join(path.Dir(from), name)}
+		for _, dir := range l.SearchPaths {
+			candidates = append(candidates, path.Join(filepath.ToSlash(dir), name))
+		}
+	}
+
+	for _, candidate := range candidates {
+		candidate = path.Clean(candidate)
+		if l.FS != nil {
+			candidate = strings.TrimPrefix(candidate, "/")
+			if !fs.ValidPath(candidate) {
+				// * the path leaves the root of the FS
+				continue
+			}
+		}
+		if info, err := fs.Stat(l.fileSystem(), candidate); err == nil && info.Mode().IsRegular() {
+			if l.FS == nil {
+				return filepath.FromSlash(candidate), nil
+			}
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%w %q", ErrNotFound, name)
+}
+
+// Load returns the module imported with the given path by the file from, reading and parsing it on first use.
+// Modules that cannot be parsed or contain top-level return statements are not loaded.
+func (l *Loader) Load(name, from string) (*Module, error) {
+	resolved, err := l.Resolve(name, from)
+	if err != nil {
+		return nil, err
+	}
+	if module, ok := l.modules[resolved]; ok {
+		return module, nil
+	}
+
+	source, err := fs.ReadFile(l.fileSystem(), resolved)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		return nil, fmt.Errorf("%s: parser has %d errors, first: %s", resolved, len(errs), errs[0])
+	}
+
+	if l.Prepare != nil {
+		if program, err = l.Prepare(program); err != nil {
+			return nil, fmt.Errorf("%s: %w", resolved, err)
+		}
+	}
+
+	module := &Module{Path: resolved, Program: program, Exports: []string{}}
+	for _, stmt := range program.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.ReturnStatement:
+			return nil, fmt.Errorf("%s:%s: return statements are not allowed at the top level of a module", resolved, stmt.Pos())
+		case *ast.ExportStatement:
+			module.Exports = append(module.Exports, stmt.Statement.Name.Value)
+		}
+	}
+
+	if l.modules == nil {
+		l.modules = make(map[string]*Module)
+	}
+	l.modules[resolved] = module
+	return module, nil
+}
+
+// fileSystem returns the FS of the Loader, or the file system of the operating system if it has none.
+func (l *Loader) fileSystem() fs.FS {
+	if l.FS == nil {
+		return osFS{}
+	}
+	return l.FS
+}
+
+// osFS opens files of the operating system by their slash-separated path.
+// Unlike the FS returned by os.DirFS, it accepts absolute paths and paths relative to the working directory.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(filepath.FromSlash(name))
+}
@@ -0,0 +1,228 @@
+package module
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/smalldevshima/go-monkey/ast"
+)
+
+/// Tests
+
+func TestLoaderResolve(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.mk":        "",
+		"util.mk":        "",
+		"lib/util.mk":    "",
+		"lib/extra.mk":   "",
+		"shared/util.mk": "",
+		"shared/only.mk": "",
+		"shared/dir.mk/": "",
+	})
+	loader := &Loader{SearchPaths: []string{filepath.Join(dir, "shared"), filepath.Join(dir, "lib")}}
+	main := filepath.Join(dir, "main.mk")
+
+	tests := []struct {
+		name     string
+		path     string
+		from     string
+		expected string
+	}{
+		{"relative", "util.mk", main, "util.mk"},
+		{"relative/subdirectory", "lib/util.mk", main, "lib/util.mk"},
+		{"relative/parent", "../util.mk", filepath.Join(dir, "lib", "util.mk"), "util.mk"},
+		{"relative/cleaned", "./lib/../util.mk", main, "util.mk"},
+		{"search-path", "only.mk", main, "shared/only.mk"},
+		{"search-path/order", "extra.mk", main, "lib/extra.mk"},
+		{"absolute", filepath.Join(dir, "lib", "util.mk"), "", "lib/util.mk"},
+		{"not-found", "missing.mk", main, ""},
+		{"not-found/directory", "dir.mk", main, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved, err := loader.Resolve(test.path, test.from)
+			if test.expected == "" {
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("expected ErrNotFound, got=%v (resolved %q)", err, resolved)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("could not resolve %q: %s", test.path, err)
+			}
+			if expected := filepath.Join(dir, test.expected); resolved != expected {
+				t.Errorf("resolved path is wrong. expected=%q, got=%q", expected, resolved)
+			}
+		})
+	}
+}
+
+func TestLoaderResolveFS(t *testing.T) {
+	loader := &Loader{
+		FS: fstest.MapFS{
+			"main.mk":         {},
+			"util.mk":         {},
+			"lib/util.mk":     {},
+			"lib/sub/deep.mk": {},
+			"vendor/only.mk":  {},
+			"vendor/dir.mk":   {Mode: fs.ModeDir},
+		},
+		SearchPaths: []string{"vendor"},
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		from     string
+		expected string
+	}{
+		{"relative", "util.mk", "main.mk", "util.mk"},
+		{"relative/subdirectory", "sub/deep.mk", "lib/util.mk", "lib/sub/deep.mk"},
+		{"relative/parent", "../util.mk", "lib/util.mk", "util.mk"},
+		{"no-file", "lib/util.mk", "", "lib/util.mk"},
+		{"absolute", "/lib/util.mk", "lib/sub/deep.mk", "lib/util.mk"},
+		{"search-path", "only.mk", "lib/util.mk", "vendor/only.mk"},
+		{"escaping", "../../util.mk", "main.mk", ""},
+		{"escaping/search-path", "../../only.mk", "main.mk", ""},
+		{"not-found/directory", "dir.mk", "main.mk", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved, err := loader.Resolve(test.path, test.from)
+			if test.expected == "" {
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("expected ErrNotFound, got=%v (resolved %q)", err, resolved)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("could not resolve %q: %s", test.path, err)
+			}
+			if resolved != test.expected {
+				t.Errorf("resolved path is wrong. expected=%q, got=%q", test.expected, resolved)
+			}
+		})
+	}
+}
+
+func TestLoaderLoad(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lib.mk": "let helper = fn(x) { x }; export let a = 1; export let b = helper(2); a + b",
+	})
+	prepared := 0
+	loader := &Loader{Prepare: func(program *ast.Program) (*ast.Program, error) {
+		prepared++
+		return program, nil
+	}}
+
+	module, err := loader.Load("lib.mk", filepath.Join(dir, "main.mk"))
+	if err != nil {
+		t.Fatalf("could not load module: %s", err)
+	}
+	if expected := filepath.Join(dir, "lib.mk"); module.Path != expected {
+		t.Errorf("module path is wrong. expected=%q, got=%q", expected, module.Path)
+	}
+	if !reflect.DeepEqual(module.Exports, []string{"a", "b"}) {
+		t.Errorf("module exports are wrong. got=%q", module.Exports)
+	}
+	if len(module.Program.Statements) != 4 {
+		t.Errorf("module program has wrong number of statements. got=%d", len(module.Program.Statements))
+	}
+
+	again, err := loader.Load(filepath.Join(dir, "lib.mk"), "")
+	if err != nil {
+		t.Fatalf("could not load module again: %s", err)
+	}
+	if again != module {
+		t.Errorf("module was not cached")
+	}
+	if prepared != 1 {
+		t.Errorf("module was prepared %d times", prepared)
+	}
+}
+
+func TestLoaderLoadFS(t *testing.T) {
+	loader := &Loader{FS: fstest.MapFS{
+		"rules/lib.mk": {Data: []byte("export let limit = 10;")},
+	}}
+
+	module, err := loader.Load("lib.mk", "rules/main.mk")
+	if err != nil {
+		t.Fatalf("could not load module: %s", err)
+	}
+	if module.Path != "rules/lib.mk" {
+		t.Errorf("module path is wrong. expected=%q, got=%q", "rules/lib.mk", module.Path)
+	}
+	if !reflect.DeepEqual(module.Exports, []string{"limit"}) {
+		t.Errorf("module exports are wrong. got=%q", module.Exports)
+	}
+}
+
+func TestLoaderLoadErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"parse.mk":   "let = 1;",
+		"return.mk":  "export let a = 1;\nreturn a;",
+		"prepare.mk": "fail",
+	})
+	loader := &Loader{Prepare: func(program *ast.Program) (*ast.Program, error) {
+		if strings.Contains(program.String(), "fail") {
+			return nil, errors.New("cannot prepare")
+		}
+		return program, nil
+	}}
+	from := filepath.Join(dir, "main.mk")
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"missing.mk", `cannot find module "missing.mk"`},
+		{"parse.mk", "parse.mk: parser has 4 errors, first: unexpected token"},
+		{"return.mk", "return.mk:2:1: return statements are not allowed at the top level of a module"},
+		{"prepare.mk", "prepare.mk: cannot prepare"},
+	}
+
+	for _, test := range tests {
+		module, err := loader.Load(test.path, from)
+		if err == nil {
+			t.Errorf("expected error for %q, got module %q", test.path, module.Path)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", test.path, test.expected, err)
+		}
+	}
+}
+
+/// helpers
+
+// writeFiles creates the files with the given contents in a temporary directory and returns the directory.
+// Names ending with a slash create directories.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
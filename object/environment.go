@@ -12,22 +12,85 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+// NewSlotEnvironment creates an enclosed environment that stores the bindings of the given names in indexed slots,
+// as resolved by the resolver package. Bindings of other names are stored by name.
+// The names are shared by all environments of the same scope and must not be modified.
+func NewSlotEnvironment(outer *Environment, names []string) *Environment {
+	return &Environment{outer: outer, names: names, slots: make([]Object, len(names))}
+}
+
 /// Types
 
+// Environment holds the bindings of one scope and refers to the environment of the enclosing scope.
+// Bindings are accessed by name, or by slot for environments created by NewSlotEnvironment.
 type Environment struct {
+	// the bindings stored by name, created on first use for slot environments
 	store map[string]Object
 	outer *Environment
+
+	// the names of the slots, nil if the environment has no slots
+	names []string
+	slots []Object
 }
 
 func (e *Environment) Get(name string) (obj Object, ok bool) {
-	obj, ok = e.store[name]
+	obj, ok = e.getLocal(name)
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
 	return
 }
 
+// GetAt returns the binding resolved to the slot of the environment depth levels up the chain of outer environments.
+// If that environment has no slots, the binding is looked up by name instead.
+// Unlike Get, it does not search any other environment.
+func (e *Environment) GetAt(depth int, slot int, name string) (obj Object, ok bool) {
+	env := e
+	for ; depth > 0 && env != nil; depth-- {
+		env = env.outer
+	}
+	if env == nil {
+		return nil, false
+	}
+	if env.slots != nil && slot < len(env.slots) && env.names[slot] == name {
+		obj = env.slots[slot]
+		return obj, obj != nil
+	}
+	return env.getLocal(name)
+}
+
 func (e *Environment) Set(name string, val Object) Object {
+	for slot, slotName := range e.names {
+		if slotName == name {
+			e.slots[slot] = val
+			return val
+		}
+	}
+
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// SetAt sets the binding resolved to the slot of this environment.
+// If the environment has no slots, the binding is set by name instead.
+func (e *Environment) SetAt(slot int, name string, val Object) Object {
+	if e.slots != nil && slot < len(e.slots) && e.names[slot] == name {
+		e.slots[slot] = val
+		return val
+	}
+	return e.Set(name, val)
+}
+
+// getLocal returns the binding of the name in this environment only.
+func (e *Environment) getLocal(name string) (obj Object, ok bool) {
+	for slot, slotName := range e.names {
+		if slotName == name && e.slots[slot] != nil {
+			return e.slots[slot], true
+		}
+	}
+	obj, ok = e.store[name]
+	return
+}
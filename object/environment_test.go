@@ -0,0 +1,47 @@
+package object
+
+import "testing"
+
+/// Tests
+
+func TestSlotEnvironment(t *testing.T) {
+	global := NewEnvironment()
+	global.Set("g", &Integer{Value: 1})
+
+	env := NewSlotEnvironment(global, []string{"a", "b"})
+	env.SetAt(0, "a", &Integer{Value: 2})
+	env.Set("b", &Integer{Value: 3})
+	env.Set("unresolved", &Integer{Value: 4})
+
+	tests := []struct {
+		name     string
+		get      func() (Object, bool)
+		expected int64
+		ok       bool
+	}{
+		{"slot-by-name", func() (Object, bool) { return env.Get("a") }, 2, true},
+		{"slot-set-by-name", func() (Object, bool) { return env.GetAt(0, 1, "b") }, 3, true},
+		{"store", func() (Object, bool) { return env.Get("unresolved") }, 4, true},
+		{"outer-by-name", func() (Object, bool) { return env.Get("g") }, 1, true},
+		{"outer-without-slots", func() (Object, bool) { return env.GetAt(1, 0, "g") }, 1, true},
+		{"mismatching-slot", func() (Object, bool) { return env.GetAt(0, 1, "a") }, 2, true},
+		{"unset-slot", func() (Object, bool) { return NewSlotEnvironment(global, []string{"g"}).GetAt(0, 0, "g") }, 0, false},
+		{"no-search-beyond-depth", func() (Object, bool) { return env.GetAt(0, 0, "g") }, 0, false},
+		{"beyond-outermost", func() (Object, bool) { return env.GetAt(2, 0, "g") }, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj, ok := test.get()
+			if ok != test.ok {
+				t.Fatalf("ok is wrong. expected=%v, got=%v", test.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if integer, isInteger := obj.(*Integer); !isInteger || integer.Value != test.expected {
+				t.Errorf("binding is wrong. expected=%d, got=%v", test.expected, obj)
+			}
+		})
+	}
+}
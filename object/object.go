@@ -2,9 +2,13 @@ package object
 
 import (
 	"fmt"
+	"hash/fnv"
+	"sort"
 	"strings"
 
 	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/code"
+	"github.com/smalldevshima/go-monkey/token"
 )
 
 /// Constants / Variables
@@ -20,12 +24,23 @@ var (
 	O_BOOLEAN ObjectType = typeString("bool")
 	O_STRING  ObjectType = typeString("string")
 
+	O_ARRAY ObjectType = typeString("array")
+	O_HASH  ObjectType = typeString("hash")
+
 	O_RETURN_VALUE ObjectType = typeString("return_value")
 
 	O_ERROR = typeString("error")
 
 	O_FUNCTION = typeString("function")
 	O_BUILTIN  = typeString("builtin")
+	O_METHOD   = typeString("method")
+
+	O_COMPILED_FUNCTION = typeString("compiled_function")
+
+	O_QUOTE = typeString("quote")
+	O_MACRO = typeString("macro")
+
+	O_MODULE = typeString("module")
 )
 
 // Object string formats
@@ -36,12 +51,57 @@ const (
 	F_INTEGER = "%d"
 	F_STRING  = "%v"
 
+	F_ARRAY     = "[%s]"
+	F_HASH      = "{%s}"
+	F_HASH_PAIR = "%s: %s"
+
 	F_RETURN_VALUE = "%v"
 
-	F_ERROR = "ERROR: %s"
+	F_ERROR        = "ERROR: %s"
+	F_FRAME        = "\tat %s (%s)"
+	F_MODULE_FRAME = "<module %s>"
+
+	F_FUNCTION       = "fn(%s) {\n%s\n}"
+	F_NAMED_FUNCTION = "fn %s(%s) {\n%s\n}"
+	F_BUILTIN        = "fn(...args) { internal code }"
+
+	F_QUOTE = "QUOTE(%s)"
+	F_MACRO = "macro(%s) {\n%s\n}"
+
+	F_MODULE = "module %q {%s}"
+)
+
+// Names used in stack traces for frames without a function name
+const (
+	FRAME_PROGRAM   = "<program>"
+	FRAME_ANONYMOUS = "<anonymous>"
+)
+
+// Tracebacks of errors with more than TRACEBACK_MAX_FRAMES stack frames only show
+// the innermost and outermost TRACEBACK_MAX_FRAMES/2 frames
+const (
+	TRACEBACK_MAX_FRAMES = 20
+	F_FRAMES_OMITTED     = "\t... %d frames omitted ..."
+)
 
-	F_FUNCTION = "fn(%s) {\n%s\n}"
-	F_BUILTIN  = "fn(...args) { internal code }"
+// Error kinds
+const (
+	// K_TYPE is the kind of errors caused by values of unsupported types
+	K_TYPE ErrorKind = "type"
+	// K_REFERENCE is the kind of errors caused by unknown identifiers
+	K_REFERENCE ErrorKind = "reference"
+	// K_ARGUMENT is the kind of errors caused by invalid arguments in function calls
+	K_ARGUMENT ErrorKind = "argument"
+	// K_ARITHMETIC is the kind of errors caused by invalid arithmetic operations, like division by zero
+	K_ARITHMETIC ErrorKind = "arithmetic"
+	// K_THROWN is the kind of errors raised by throw statements
+	K_THROWN ErrorKind = "thrown"
+	// K_RESOURCE is the kind of errors caused by exceeding resource limits of the evaluation
+	K_RESOURCE ErrorKind = "resource"
+	// K_CANCELED is the kind of errors caused by the host canceling the evaluation
+	K_CANCELED ErrorKind = "canceled"
+	// K_IMPORT is the kind of errors caused by modules that cannot be imported
+	K_IMPORT ErrorKind = "import"
 )
 
 /// Functions
@@ -54,6 +114,18 @@ func typeString(typ string) ObjectType {
 
 type ObjectType string
 
+// HashKey is the key type used to store Hashable objects in a Hash.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by all objects that can be used as keys of a Hash.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
 // Object is the base interface for all values in Monkey
 type Object interface {
 	// The Type of the Monkey object
@@ -68,6 +140,13 @@ type Boolean struct {
 
 func (b *Boolean) Type() ObjectType { return O_BOOLEAN }
 func (b *Boolean) Inspect() string  { return fmt.Sprintf(F_BOOLEAN, b.Value) }
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
 
 type Integer struct {
 	Value int64
@@ -75,6 +154,7 @@ type Integer struct {
 
 func (i *Integer) Type() ObjectType { return O_INTEGER }
 func (i *Integer) Inspect() string  { return fmt.Sprintf(F_INTEGER, i.Value) }
+func (i *Integer) HashKey() HashKey { return HashKey{Type: i.Type(), Value: uint64(i.Value)} }
 
 type String struct {
 	Value string
@@ -82,6 +162,49 @@ type String struct {
 
 func (s *String) Type() ObjectType { return O_STRING }
 func (s *String) Inspect() string  { return fmt.Sprintf(F_STRING, s.Value) }
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return O_ARRAY }
+func (a *Array) Inspect() string {
+	elements := []string{}
+	for _, el := range a.Elements {
+		elements = append(elements, el.Inspect())
+	}
+	return fmt.Sprintf(F_ARRAY, strings.Join(elements, ", "))
+}
+
+// HashPair stores the original key object next to its value,
+// since the HashKey used for lookup cannot be converted back into the key.
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	// Error is the error described by the hash, if it was created for the parameter of a catch-branch.
+	// Throwing such a hash rethrows the error.
+	Error *Error
+}
+
+func (h *Hash) Type() ObjectType { return O_HASH }
+func (h *Hash) Inspect() string {
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, fmt.Sprintf(F_HASH_PAIR, pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+	// * sort the pairs, since map iteration order is random
+	sort.Strings(pairs)
+	return fmt.Sprintf(F_HASH, strings.Join(pairs, ", "))
+}
 
 type Null struct{}
 
@@ -95,32 +218,235 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return O_RETURN_VALUE }
 func (rv *ReturnValue) Inspect() string  { return fmt.Sprintf(F_RETURN_VALUE, rv.Value.Inspect()) }
 
+type ErrorKind string
+
 type Error struct {
 	Message string
+	Kind    ErrorKind
+	// the position in the source where the error occurred, if known
+	Position token.Position
+	// the value of the throw statement that raised the error, nil for runtime errors
+	Value Object
+	// Fatal errors cannot be caught by try-catch expressions
+	Fatal bool
+	// the function calls the error bubbled up through, innermost call first.
+	// A tail call replaces the frame of the calling function, so functions that returned the result of a call
+	// are not listed, like the outer function of "fn outer() { inner() }".
+	Stack []Frame
 }
 
 func (e *Error) Type() ObjectType { return O_ERROR }
 func (e *Error) Inspect() string  { return fmt.Sprintf(F_ERROR, e.Message) }
 
+// Traceback returns a multi-line representation of the error, listing the active function in every stack frame
+// together with the position at which it was executing, starting with the innermost frame.
+// Functions whose frames were replaced by tail calls are not listed, see Stack.
+func (e *Error) Traceback() string {
+	var out strings.Builder
+
+	out.WriteString(e.Inspect())
+	position := e.Position
+	omitted := len(e.Stack) - TRACEBACK_MAX_FRAMES
+	for index, frame := range e.Stack {
+		switch {
+		case omitted <= 0 || index < TRACEBACK_MAX_FRAMES/2 || index >= len(e.Stack)-TRACEBACK_MAX_FRAMES/2:
+			out.WriteString("\n")
+			out.WriteString(fmt.Sprintf(F_FRAME, frame.Function, position))
+		case index == TRACEBACK_MAX_FRAMES/2:
+			out.WriteString("\n")
+			out.WriteString(fmt.Sprintf(F_FRAMES_OMITTED, omitted))
+		}
+		// * the caller was executing at the call site of the current frame
+		position = frame.Position
+	}
+	out.WriteString("\n")
+	out.WriteString(fmt.Sprintf(F_FRAME, FRAME_PROGRAM, position))
+
+	return out.String()
+}
+
+// Frame is a single function call in the stack of an Error.
+type Frame struct {
+	// the name of the called function
+	Function string
+	// the position of the call expression
+	Position token.Position
+}
+
+// CallFunction calls a function, builtin or method with the arguments and returns its result.
+type CallFunction func(fn Object, args ...Object) Object
+
+// Runtime gives a method access to the evaluator or VM calling it.
+type Runtime struct {
+	// Call calls functions passed as arguments, like the callback of arr.map(f).
+	Call CallFunction
+	// Allocate accounts a string, array or hash created by the method against the limits of the evaluation.
+	// It returns the object itself, or a fatal error if a limit is exceeded.
+	Allocate func(obj Object) Object
+}
+
+// MethodFunction implements a method, which is called with the value it was looked up on as receiver.
+// Functions have to be called and new strings, arrays and hashes allocated with the Runtime.
+type MethodFunction func(rt *Runtime, receiver Object, args ...Object) Object
+
+// BoundMethod is a method looked up on a value, like "abc".upper, which is called like a function.
+type BoundMethod struct {
+	Name     string
+	Receiver Object
+	Fn       MethodFunction
+}
+
+func (bm *BoundMethod) Type() ObjectType { return O_METHOD }
+func (bm *BoundMethod) Inspect() string  { return F_BUILTIN }
+
 type Function struct {
+	// the name of the function, empty for anonymous functions
+	Name       string
 	Parameters []*ast.Identifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	// default values of the parameters at the same index, nil for parameters without default value
+	Defaults []ast.Expression
+	// the optional rest parameter, possibly nil
+	Rest *ast.Identifier
+	Body *ast.BlockStatement
+	Env  *Environment
+	// the names of the slots of the environments created for calls of the function,
+	// nil if the function literal was not resolved and bindings are stored by name
+	SlotNames []string
 }
 
 func (f *Function) Type() ObjectType { return O_FUNCTION }
 func (f *Function) Inspect() string {
 	args := []string{}
-	for _, arg := range f.Parameters {
-		args = append(args, arg.String())
+	for i, arg := range f.Parameters {
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			args = append(args, fmt.Sprintf("%s = %s", arg, f.Defaults[i]))
+		} else {
+			args = append(args, arg.String())
+		}
+	}
+	if f.Rest != nil {
+		args = append(args, "..."+f.Rest.String())
+	}
+	if f.Name != "" {
+		return fmt.Sprintf(F_NAMED_FUNCTION, f.Name, strings.Join(args, ", "), f.Body.String())
 	}
 	return fmt.Sprintf(F_FUNCTION, strings.Join(args, ", "), f.Body.String())
 }
 
+// DisplayName returns the name of the function as used in error messages and stack traces.
+func (f *Function) DisplayName() string {
+	if f.Name == "" {
+		return FRAME_ANONYMOUS
+	}
+	return f.Name
+}
+
 type BuiltinFunction func(args ...Object) Object
 type Builtin struct {
-	Fn BuiltinFunction
+	Name string
+	Fn   BuiltinFunction
 }
 
 func (b *Builtin) Type() ObjectType { return O_BUILTIN }
 func (b *Builtin) Inspect() string  { return F_BUILTIN }
+
+// Quote is the result of quoting an expression, which is not evaluated, but kept as AST node.
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return O_QUOTE }
+func (q *Quote) Inspect() string  { return fmt.Sprintf(F_QUOTE, q.Node) }
+
+// Macro is a macro defined by a macro literal, whose calls are expanded before the program is executed.
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return O_MACRO }
+func (m *Macro) Inspect() string {
+	params := []string{}
+	for _, param := range m.Parameters {
+		params = append(params, param.String())
+	}
+	return fmt.Sprintf(F_MACRO, strings.Join(params, ", "), m.Body.String())
+}
+
+// Module is the value that import statements bind, which holds the bindings exported by the imported module.
+type Module struct {
+	// the resolved path of the source file of the module
+	Path    string
+	Exports map[string]Object
+}
+
+func (m *Module) Type() ObjectType { return O_MODULE }
+func (m *Module) Inspect() string {
+	names := []string{}
+	for name := range m.Exports {
+		names = append(names, name)
+	}
+	// * sort the names, since map iteration order is random
+	sort.Strings(names)
+	return fmt.Sprintf(F_MODULE, m.Path, strings.Join(names, ", "))
+}
+
+// CompiledFunction is a function literal compiled to bytecode.
+// It is stored in the constant pool and turned into a Closure when the function literal is evaluated.
+type CompiledFunction struct {
+	// the name of the function, empty for anonymous functions
+	Name         string
+	Instructions code.Instructions
+	// Lines maps the instructions to the positions in the source they were compiled from
+	Lines code.LineTable
+	// the number of local bindings, including the parameters
+	NumLocals int
+	// the number of parameters, excluding the rest parameter
+	NumParameters int
+	// the number of parameters without default value
+	NumRequired int
+	// whether the function has a rest parameter, which is bound to the local after the parameters
+	HasRest bool
+	// the names of the local bindings, used in error messages
+	LocalNames []string
+	// the parameters and the body in source form, used by Inspect
+	Parameters []string
+	Body       string
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return O_COMPILED_FUNCTION }
+func (cf *CompiledFunction) Inspect() string {
+	if cf.Name != "" {
+		return fmt.Sprintf(F_NAMED_FUNCTION, cf.Name, strings.Join(cf.Parameters, ", "), cf.Body)
+	}
+	return fmt.Sprintf(F_FUNCTION, strings.Join(cf.Parameters, ", "), cf.Body)
+}
+
+// DisplayName returns the name of the function as used in error messages and stack traces.
+func (cf *CompiledFunction) DisplayName() string {
+	if cf.Name == "" {
+		return FRAME_ANONYMOUS
+	}
+	return cf.Name
+}
+
+// Scope holds the local bindings of a single call of a compiled function, indexed by slot.
+type Scope struct {
+	Function *CompiledFunction
+	Slots    []Object
+	// the scope the called closure was created in, nil for closures created outside of functions
+	Outer *Scope
+}
+
+// Closure is the value of a compiled function literal.
+// It keeps a reference to the Scope it was created in, so that it observes bindings made after its creation,
+// just like a Function does with its Environment.
+type Closure struct {
+	Fn    *CompiledFunction
+	Scope *Scope
+}
+
+// Closures are of the same type as functions of the evaluator, since they behave identically in Monkey code
+func (c *Closure) Type() ObjectType { return O_FUNCTION }
+func (c *Closure) Inspect() string  { return c.Fn.Inspect() }
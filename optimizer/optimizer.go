@@ -0,0 +1,268 @@
+package optimizer
+
+import (
+	"strconv"
+
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/evaluator"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Functions
+
+// Optimize rewrites the program in place and returns it.
+//
+// Prefix and infix expressions whose operands are literals are replaced by the literal of their result,
+// and if-expressions whose condition is a literal are replaced by the branch that is taken.
+// The results of operators are computed with the evaluator's semantics, so the optimized program produces the same results.
+// Operations resulting in an error, like a division by zero, are kept, so that the error is still raised when and where it occurred before.
+//
+// The source form of function literals reflects the rewritten bodies, so inspected functions may differ.
+func Optimize(program *ast.Program) *ast.Program {
+	program.Statements = optimizeStatements(program.Statements)
+	return program
+}
+
+// optimizeStatements optimizes a list of statements, splicing the taken branch of if-expressions in statement position into it.
+func optimizeStatements(statements []ast.Statement) []ast.Statement {
+	optimized := make([]ast.Statement, 0, len(statements))
+
+	for index, stmt := range statements {
+		es, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			optimized = append(optimized, optimizeStatement(stmt))
+			continue
+		}
+		ie, ok := es.Expression.(*ast.IfExpression)
+		if !ok {
+			optimized = append(optimized, optimizeStatement(stmt))
+			continue
+		}
+
+		optimizeIfChildren(ie)
+		branch, ok := takenBranch(ie)
+		if !ok {
+			optimized = append(optimized, stmt)
+			continue
+		}
+
+		last := index == len(statements)-1
+		switch {
+		// * the value of the last statement is the value of the list, which is null for an if-expression without taken branch
+		case branch == nil && last:
+			optimized = append(optimized, &ast.ExpressionStatement{Token: es.Token, Expression: nullLiteral(ie.Pos())})
+		case branch == nil:
+		// * an empty branch has no value, unlike the statements preceding it
+		case len(branch.Statements) == 0 && last:
+			optimized = append(optimized, &ast.ExpressionStatement{Token: es.Token, Expression: alwaysTaken(ie, branch)})
+		// * branches share the environment of the enclosing statements, so their statements behave the same when spliced
+		default:
+			optimized = append(optimized, branch.Statements...)
+		}
+	}
+
+	return optimized
+}
+
+func optimizeStatement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		stmt.Expression = optimizeExpression(stmt.Expression)
+	case *ast.LetStatement:
+		stmt.Value = optimizeExpression(stmt.Value)
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = optimizeExpression(stmt.ReturnValue)
+	case *ast.ThrowStatement:
+		stmt.Value = optimizeExpression(stmt.Value)
+	case *ast.ExportStatement:
+		optimizeStatement(stmt.Statement)
+	case *ast.BlockStatement:
+		optimizeBlock(stmt)
+	}
+	return stmt
+}
+
+func optimizeBlock(block *ast.BlockStatement) {
+	if block != nil {
+		block.Statements = optimizeStatements(block.Statements)
+	}
+}
+
+func optimizeExpressions(exps []ast.Expression) {
+	for index, exp := range exps {
+		exps[index] = optimizeExpression(exp)
+	}
+}
+
+// optimizeExpression optimizes the children of the expression and returns the expression or its replacement.
+func optimizeExpression(exp ast.Expression) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		exp.Right = optimizeExpression(exp.Right)
+		return foldPrefixExpression(exp)
+	case *ast.InfixExpression:
+		exp.Left = optimizeExpression(exp.Left)
+		exp.Right = optimizeExpression(exp.Right)
+		return foldInfixExpression(exp)
+	case *ast.IfExpression:
+		optimizeIfChildren(exp)
+		return pruneIfExpression(exp)
+	case *ast.TryExpression:
+		optimizeBlock(exp.Block)
+		optimizeBlock(exp.Catch)
+		optimizeBlock(exp.Finally)
+	case *ast.FunctionLiteral:
+		for index, def := range exp.Defaults {
+			if def != nil {
+				exp.Defaults[index] = optimizeExpression(def)
+			}
+		}
+		optimizeBlock(exp.Body)
+	case *ast.CallExpression:
+		exp.Function = optimizeExpression(exp.Function)
+		optimizeExpressions(exp.Arguments)
+	case *ast.SpreadExpression:
+		exp.Value = optimizeExpression(exp.Value)
+	case *ast.ArrayLiteral:
+		optimizeExpressions(exp.Elements)
+	case *ast.HashLiteral:
+		for index, pair := range exp.Pairs {
+			exp.Pairs[index] = ast.HashPair{Key: optimizeExpression(pair.Key), Value: optimizeExpression(pair.Value)}
+		}
+	case *ast.IndexExpression:
+		exp.Left = optimizeExpression(exp.Left)
+		exp.Index = optimizeExpression(exp.Index)
+	case *ast.PropertyExpression:
+		exp.Object = optimizeExpression(exp.Object)
+	}
+	return exp
+}
+
+func foldPrefixExpression(pe *ast.PrefixExpression) ast.Expression {
+	operand, ok := literalValue(pe.Right)
+	if !ok {
+		return pe
+	}
+	return resultLiteral(evaluator.PrefixOperation(pe.Operator, operand), pe)
+}
+
+func foldInfixExpression(ie *ast.InfixExpression) ast.Expression {
+	left, ok := literalValue(ie.Left)
+	if !ok {
+		return ie
+	}
+
+	// * the right operand is only evaluated if the left one is null
+	if ie.Operator == "??" {
+		if left == evaluator.NULL {
+			return ie.Right
+		}
+		return ie.Left
+	}
+
+	right, ok := literalValue(ie.Right)
+	if !ok {
+		return ie
+	}
+	return resultLiteral(evaluator.InfixOperation(ie.Operator, left, right), ie)
+}
+
+func optimizeIfChildren(ie *ast.IfExpression) {
+	ie.Condition = optimizeExpression(ie.Condition)
+	optimizeBlock(ie.Then)
+	optimizeBlock(ie.Otherwise)
+}
+
+// pruneIfExpression replaces an if-expression in expression position whose condition is a literal.
+func pruneIfExpression(ie *ast.IfExpression) ast.Expression {
+	branch, ok := takenBranch(ie)
+	switch {
+	case !ok:
+		return ie
+	case branch == nil:
+		return nullLiteral(ie.Pos())
+	case len(branch.Statements) == 1:
+		es, ok := branch.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			break
+		}
+		// * a named function literal in statement position also declares the function
+		if fl, ok := es.Expression.(*ast.FunctionLiteral); !ok || fl.Name == nil {
+			return es.Expression
+		}
+	}
+	return alwaysTaken(ie, branch)
+}
+
+// takenBranch returns the branch of an if-expression that is always taken, or nil if there is none.
+// It reports false if the condition is no literal.
+func takenBranch(ie *ast.IfExpression) (*ast.BlockStatement, bool) {
+	condition, ok := literalValue(ie.Condition)
+	if !ok {
+		return nil, false
+	}
+	if evaluator.IsTruthy(condition) {
+		return ie.Then, true
+	}
+	return ie.Otherwise, true
+}
+
+// alwaysTaken returns an if-expression that always takes the given branch.
+func alwaysTaken(ie *ast.IfExpression, branch *ast.BlockStatement) *ast.IfExpression {
+	condition := &ast.BooleanLiteral{
+		Token: token.Token{Type: token.TRUE, Literal: "true", Position: ie.Condition.Pos()},
+		Value: true,
+	}
+	return &ast.IfExpression{Token: ie.Token, Condition: condition, Then: branch}
+}
+
+// literalValue returns the value of a literal expression.
+func literalValue(exp ast.Expression) (object.Object, bool) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: exp.Value}, true
+	case *ast.StringLiteral:
+		return &object.String{Value: exp.Value}, true
+	case *ast.BooleanLiteral:
+		return evaluator.NativeBooleanToObject(exp.Value), true
+	case *ast.NullLiteral:
+		return evaluator.NULL, true
+	}
+	return nil, false
+}
+
+// resultLiteral returns the literal of the result of an operation, located at the expression it replaces.
+// If the result has no literal form, like errors, the expression itself is returned.
+func resultLiteral(result object.Object, exp ast.Expression) ast.Expression {
+	position := exp.Pos()
+
+	switch result := result.(type) {
+	case *object.Integer:
+		return &ast.IntegerLiteral{
+			Token: token.Token{Type: token.INTEGER, Literal: strconv.FormatInt(result.Value, 10), Position: position},
+			Value: result.Value,
+		}
+	case *object.String:
+		return &ast.StringLiteral{
+			Token: token.Token{Type: token.STRING, Literal: result.Value, Position: position},
+			Value: result.Value,
+		}
+	case *object.Boolean:
+		tokenType := token.FALSE
+		if result.Value {
+			tokenType = token.TRUE
+		}
+		return &ast.BooleanLiteral{
+			Token: token.Token{Type: tokenType, Literal: strconv.FormatBool(result.Value), Position: position},
+			Value: result.Value,
+		}
+	case *object.Null:
+		return nullLiteral(position)
+	}
+	return exp
+}
+
+func nullLiteral(position token.Position) *ast.NullLiteral {
+	return &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null", Position: position}}
+}
@@ -0,0 +1,135 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/evaluator"
+	"github.com/smalldevshima/go-monkey/lexer"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/parser"
+)
+
+/// Tests
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"fold/integers", "60 * 60 * 24", "86400;"},
+		{"fold/precedence", "1 + 2 * 3 - -4", "11;"},
+		{"fold/comparison", "1 < 2 == true", "true;"},
+		{"fold/strings", `"a" + "b" + "c"`, "abc;"},
+		{"fold/bang", "!null", "true;"},
+		{"fold/null-comparison", "null == 1", "false;"},
+		{"fold/nullish/null", "null ?? x", "x;"},
+		{"fold/nullish/value", "1 ?? x", "1;"},
+		{"fold/partial", "x + 2 * 3", "(x + 6);"},
+		{"fold/left-associative", "x + 2 + 3", "((x + 2) + 3);"},
+		{"fold/nested", "f(1 + 1, [2 * 2], {3 - 3: x[4 / 2]})", "f(2, [4], {0: (x[2])});"},
+		{"fold/function", "fn(a = 2 * 2) { a * (3 + 4) }", "fn(a = 4) { (a * 7); };"},
+		{"fold/let", "let day = 60 * 60 * 24;", "let day = 86400;"},
+		{"fold/export", "export let day = 60 * 60 * 24;", "export let day = 86400;"},
+		{"keep/division-by-zero", "1 / 0", "(1 / 0);"},
+		{"keep/type-mismatch", "1 + true", "(1 + true);"},
+		{"keep/unknown-operator", "-true", "(-true);"},
+		{"keep/folded-operand-error", "(2 - 2) + -false", "(0 + (-false));"},
+
+		{"if/true/splice", "if (true) { let x = 1; x } else { 2 }; 3", "let x = 1;x;3;"},
+		{"if/false/splice", "if (false) { 1 } else { let y = 2; y }", "let y = 2;y;"},
+		{"if/false/drop", "if (false) { 1 }; 2", "2;"},
+		{"if/false/last", "1; if (false) { 2 }", "1;null;"},
+		{"if/truthy-condition", `if ("") { 1 } else { 2 }`, "1;"},
+		{"if/folded-condition", "if (1 > 2) { 1 } else { 2 }", "2;"},
+		{"if/empty-last", "1; if (true) {}", "1;if (true) {  };"},
+		{"if/expression/single", "let x = if (1 < 2) { 10 } else { 20 };", "let x = 10;"},
+		{"if/expression/none", "let x = if (false) { 10 };", "let x = null;"},
+		{"if/expression/block", "let x = if (false) { 1 } else { let y = 2; y };", "let x = if (true) { let y = 2;y; };"},
+		{"if/expression/named-function", "let x = if (true) { fn f() {} };", "let x = if (true) { fn f() {  }; };"},
+		{"if/nested", "fn() { if (true) { if (false) { 1 } else { return 2 } } }", "fn() { return 2; };"},
+		{"if/keep", "if (x) { 1 + 1 } else { 2 + 2 }", "if (x) { 2; } else { 4; };"},
+		{"try", "try { 1 + 1 } catch (e) { if (true) { 2 } } finally { 3 * 3 }", "try { 2; } catch (e) { 2; } finally { 9; };"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			program := Optimize(testParse(t, test.input))
+			if program.String() != test.expected {
+				t.Errorf("program is wrong. expected=%q, got=%q", test.expected, program.String())
+			}
+		})
+	}
+}
+
+func TestOptimizePreservesSemantics(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"arithmetic", "let x = 3; 60 * 60 * 24 + x * (2 - 5)"},
+		{"division-by-zero", "let f = fn() {\n\t1 + 10 / (5 - 5)\n};\nf()"},
+		{"division-by-zero/caught", `try { 10 / 0 } catch (e) { [e["kind"], e["message"]] }`},
+		{"type-error/position", "let x = 1;\nx + (1 + true)"},
+		{"if/value", "let f = fn(x) { if (true) { x * 2 } else { x } }; f(4)"},
+		{"if/none", "if (false) { 1 }"},
+		{"if/empty", "if (true) {}"},
+		{"if/empty-else", "1; if (false) { 1 } else {}"},
+		{"if/let-in-branch", "if (true) { let x = 5 }; x"},
+		{"if/named-function-in-branch", "if (1) { fn f() { 42 } }; f()"},
+		{"if/return", "let f = fn() { if (true) { return 1; }; 2 }; f()"},
+		{"if/return/program", "if (true) { return 1; }; 2"},
+		{"if/error-in-branch", "let f = fn() {\n\tif (true) {\n\t\tnope\n\t}\n};\nf()"},
+		{"tail-calls", "let loop = fn(n) { if (n == 0) { if (true) { 0 } } else { loop(n - 1) } }; loop(10000)"},
+		{"tail-call/traceback", "let g = fn() { 1 / 0 };\nlet f = fn() { if (true) { g() } };\nf()"},
+		{"nullish", "let f = fn() { null ?? 1 + 2 }; f()"},
+		{"closures", "let adder = fn(a = 1 + 1) { fn(b) { if (false) { 0 } else { a + b * (2 * 2) } } }; adder()(3)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expected := evaluator.Eval(testParse(t, test.input), object.NewEnvironment())
+			actual := evaluator.Eval(Optimize(testParse(t, test.input)), object.NewEnvironment())
+			checkSameResult(t, expected, actual)
+		})
+	}
+}
+
+/// helpers
+
+func testParse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+	return program
+}
+
+func checkSameResult(t *testing.T, expected, actual object.Object) {
+	t.Helper()
+
+	if expected == nil || actual == nil {
+		if expected != actual {
+			t.Fatalf("results differ. expected=%v, got=%v", expected, actual)
+		}
+		return
+	}
+
+	if expectedErr, ok := expected.(*object.Error); ok {
+		actualErr, ok := actual.(*object.Error)
+		if !ok {
+			t.Fatalf("result is not *object.Error. got=%T (%+v)", actual, actual)
+		}
+		if expectedErr.Traceback() != actualErr.Traceback() || expectedErr.Kind != actualErr.Kind {
+			t.Errorf("errors differ.\nexpected (%s):\n%s\ngot (%s):\n%s", expectedErr.Kind, expectedErr.Traceback(), actualErr.Kind, actualErr.Traceback())
+		}
+		return
+	}
+
+	if expected.Type() != actual.Type() || expected.Inspect() != actual.Inspect() {
+		t.Errorf("results differ. expected=%s (%s), got=%s (%s)", expected.Inspect(), expected.Type(), actual.Inspect(), actual.Type())
+	}
+}
@@ -15,34 +15,54 @@ import (
 const (
 	_ Precedence = iota
 	LOWEST
+	COALESCE
 	EQUALS
 	LTGT
+	PIPE
 	SUM
 	PRODUCT
 	PREFIX
 	CALL
+	INDEX
 )
 
 var (
 	// prefixTokens is the list of all tokens that are parsed in prefix position
-	prefixTokens = []token.TokenType{token.IDENTIFIER, token.INTEGER, token.STRING, token.BANG, token.DASH, token.TRUE, token.FALSE, token.LPAREN, token.IF, token.FUNCTION, token.LBRACKET}
+	prefixTokens = []token.TokenType{token.IDENTIFIER, token.INTEGER, token.STRING, token.BANG, token.DASH, token.TRUE, token.FALSE, token.LPAREN, token.IF, token.FUNCTION, token.MACRO, token.LBRACKET, token.LBRACE, token.NULL, token.TRY}
 	// infixTokens is the list of all tokens that are parsed in infix position
-	infixTokens = []token.TokenType{token.EQ, token.NEQ, token.LT, token.GT, token.PLUS, token.DASH, token.SLASH, token.ASTERISK, token.LPAREN}
+	infixTokens = []token.TokenType{token.EQ, token.NEQ, token.LT, token.GT, token.PLUS, token.DASH, token.SLASH, token.ASTERISK, token.LPAREN, token.NULLISH, token.PIPE, token.LBRACKET, token.OPTIONAL_LBRACKET, token.DOT, token.OPTIONAL_DOT}
 
 	// precedences maps every infix operator to its corresponding precedence value
 	precedences = map[token.TokenType]Precedence{
+		token.NULLISH:  COALESCE,
 		token.EQ:       EQUALS,
 		token.NEQ:      EQUALS,
 		token.LT:       LTGT,
 		token.GT:       LTGT,
+		token.PIPE:     PIPE,
 		token.PLUS:     SUM,
 		token.DASH:     SUM,
 		token.SLASH:    PRODUCT,
 		token.ASTERISK: PRODUCT,
 		token.LPAREN:   CALL,
+
+		token.LBRACKET:          INDEX,
+		token.OPTIONAL_LBRACKET: INDEX,
+		token.DOT:               INDEX,
+		token.OPTIONAL_DOT:      INDEX,
 	}
 )
 
+/// Functions
+
+// OperatorPrecedence returns the precedence of the given infix operator, or LOWEST if it is no infix operator.
+func OperatorPrecedence(operator string) Precedence {
+	if p, ok := precedences[token.TokenType(operator)]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 /// Types
 
 type Precedence uint
@@ -61,6 +81,8 @@ type Parser struct {
 	peekToken    token.Token
 
 	errors []string
+	// comments contains the comments skipped by nextToken
+	comments []*ast.Comment
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -106,6 +128,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 		p.nextToken()
 	}
 
+	program.Comments = p.comments
 	return program
 }
 
@@ -122,6 +145,18 @@ func (p *Parser) parseStatement() ast.Statement {
 		if s := p.parseReturnStatement(); s != nil {
 			return s
 		}
+	case token.THROW:
+		if s := p.parseThrowStatement(); s != nil {
+			return s
+		}
+	case token.IMPORT:
+		if s := p.parseImportStatement(); s != nil {
+			return s
+		}
+	case token.EXPORT:
+		if s := p.parseExportStatement(); s != nil {
+			return s
+		}
 	default:
 		if s := p.parseExpressionStatement(); s != nil {
 			return s
@@ -185,6 +220,64 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.currentToken}
+
+	p.nextToken()
+
+	exp := p.parseExpression(LOWEST)
+	if exp == nil {
+		return nil
+	}
+
+	stmt.Value = exp
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.currentToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	stmt.Path = p.parseStringLiteral().(*ast.StringLiteral)
+
+	if !p.expectPeek(token.AS) || !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseExportStatement parses an export statement, which always exports a let statement.
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	stmt := &ast.ExportStatement{Token: p.currentToken}
+
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+
+	let := p.parseLetStatement()
+	if let == nil {
+		return nil
+	}
+
+	stmt.Statement = let
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.currentToken}
 
@@ -212,12 +305,21 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	for !p.currentTokenIs(token.RBRACE) && !p.currentTokenIs(token.EOF) {
 		stmt := p.parseStatement()
+		switch stmt.(type) {
+		case *ast.ImportStatement, *ast.ExportStatement:
+			// * modules are imported and export their bindings only at the top level
+			msg := fmt.Sprintf("%s statements are only allowed at the top level of a program", stmt.TokenLiteral())
+			p.errors = append(p.errors, msg)
+		}
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
 
+	if p.currentTokenIs(token.RBRACE) {
+		block.Rbrace = p.currentToken.Position
+	}
 	return block
 }
 
@@ -275,6 +377,10 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 		if exp := p.parseBooleanLiteral(); exp != nil {
 			return exp
 		}
+	case token.NULL:
+		if exp := p.parseNullLiteral(); exp != nil {
+			return exp
+		}
 	case token.LPAREN:
 		if exp := p.parseGroupedExpression(); exp != nil {
 			return exp
@@ -283,14 +389,26 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 		if exp := p.parseIfExpression(); exp != nil {
 			return exp
 		}
+	case token.TRY:
+		if exp := p.parseTryExpression(); exp != nil {
+			return exp
+		}
 	case token.FUNCTION:
 		if exp := p.parseFunctionLiteral(); exp != nil {
 			return exp
 		}
+	case token.MACRO:
+		if exp := p.parseMacroLiteral(); exp != nil {
+			return exp
+		}
 	case token.LBRACKET:
 		if exp := p.parseArrayLiteral(); exp != nil {
 			return exp
 		}
+	case token.LBRACE:
+		if exp := p.parseHashLiteral(); exp != nil {
+			return exp
+		}
 	default:
 		unhandled = true
 	}
@@ -333,20 +451,27 @@ func (p *Parser) parseBooleanLiteral() ast.Expression {
 	return &ast.BooleanLiteral{Token: p.currentToken, Value: p.currentTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.currentToken}
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	fnLit := &ast.FunctionLiteral{Token: p.currentToken}
 
+	// * check if the function is named
+	if p.peekTokenIs(token.IDENTIFIER) {
+		p.nextToken()
+		fnLit.Name = p.parseIdentifier().(*ast.Identifier)
+	}
+
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
 
-	parameters := p.parseFunctionParameters()
-	if parameters == nil {
+	if !p.parseFunctionParameters(fnLit) {
 		return nil
 	}
 
-	fnLit.Parameters = parameters
-
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
@@ -360,40 +485,145 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return fnLit
 }
 
+// parseMacroLiteral parses a macro literal, whose parameters cannot have default values and which has no rest parameter.
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	macro := &ast.MacroLiteral{Token: p.currentToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	// * the parameters are parsed like the ones of functions, but only plain parameters are accepted
+	fnLit := &ast.FunctionLiteral{}
+	if !p.parseFunctionParameters(fnLit) {
+		return nil
+	}
+	for index := range fnLit.Parameters {
+		if fnLit.Default(index) != nil {
+			p.errors = append(p.errors, fmt.Sprintf("macro parameter %q cannot have a default value", fnLit.Parameters[index].Value))
+			return nil
+		}
+	}
+	if fnLit.Rest != nil {
+		p.errors = append(p.errors, fmt.Sprintf("macro cannot have rest parameter %q", fnLit.Rest.Value))
+		return nil
+	}
+	macro.Parameters = fnLit.Parameters
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	body := p.parseBlockStatement()
+	if body == nil {
+		return nil
+	}
+
+	macro.Body = body
+	return macro
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.currentToken}
 
-	array.Elements = p.parseExpressionList(token.RBRACKET)
+	elements := p.parseExpressionList(token.RBRACKET)
+	if elements == nil {
+		return nil
+	}
 
+	array.Elements = elements
+	array.Rbracket = p.currentToken.Position
 	return array
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	params := []*ast.Identifier{}
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.currentToken, Pairs: []ast.HashPair{}}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if key == nil {
+			return nil
+		}
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	hash.Rbrace = p.currentToken.Position
+	return hash
+}
+
+// parseFunctionParameters parses the parameter list of the function literal, including default values and the rest parameter.
+// It reports whether the parameter list was parsed successfully.
+func (p *Parser) parseFunctionParameters(fnLit *ast.FunctionLiteral) bool {
+	fnLit.Parameters = []*ast.Identifier{}
+	fnLit.Defaults = []ast.Expression{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return params
+		return true
 	}
 
 	for !p.currentTokenIs(token.RPAREN) {
-		if !p.peekTokenIs(token.IDENTIFIER) {
-			return nil
+		// * the rest parameter has to be the last parameter
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENTIFIER) {
+				return false
+			}
+			fnLit.Rest = p.parseIdentifier().(*ast.Identifier)
+			return p.expectPeek(token.RPAREN)
 		}
 
-		p.nextToken()
+		if !p.expectPeek(token.IDENTIFIER) {
+			return false
+		}
 		param := p.parseIdentifier().(*ast.Identifier)
-		params = append(params, param)
+
+		var def ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			def = p.parseExpression(LOWEST)
+			if def == nil {
+				return false
+			}
+		} else if len(fnLit.Defaults) > 0 && fnLit.Defaults[len(fnLit.Defaults)-1] != nil {
+			msg := fmt.Sprintf("parameter %q without default value follows parameter with default value", param.Value)
+			p.errors = append(p.errors, msg)
+			return false
+		}
+
+		fnLit.Parameters = append(fnLit.Parameters, param)
+		fnLit.Defaults = append(fnLit.Defaults, def)
 
 		if !p.peekTokenIs(token.RPAREN) && !p.peekTokenIs(token.COMMA) {
 			msg := fmt.Sprintf("unexpected token of type %q with literal %q, expected token of type %q or %q", p.peekToken.Type, p.peekToken.Literal, token.RPAREN, token.COMMA)
 			p.errors = append(p.errors, msg)
-			return []*ast.Identifier{}
+			return false
 		}
 		p.nextToken()
 	}
 
-	return params
+	return true
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
@@ -406,12 +636,29 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 			exp.Function = left
 			return exp
 		}
-	case token.EQ, token.NEQ, token.LT, token.GT, token.PLUS, token.DASH, token.ASTERISK, token.SLASH:
+	case token.LBRACKET, token.OPTIONAL_LBRACKET:
+		exp := p.parseIndexExpression()
+		if exp != nil {
+			exp.Left = left
+			return exp
+		}
+	case token.DOT, token.OPTIONAL_DOT:
+		exp := p.parsePropertyExpression()
+		if exp != nil {
+			exp.Object = left
+			return exp
+		}
+	case token.EQ, token.NEQ, token.LT, token.GT, token.PLUS, token.DASH, token.ASTERISK, token.SLASH, token.NULLISH:
 		exp := p.parseBinaryOperator()
 		if exp != nil {
 			exp.Left = left
 			return exp
 		}
+	case token.PIPE:
+		exp := p.parsePipeExpression(left)
+		if exp != nil {
+			return exp
+		}
 	default:
 		unhandled = true
 	}
@@ -466,6 +713,31 @@ func (p *Parser) parseBinaryOperator() *ast.InfixExpression {
 	return exp
 }
 
+// parsePipeExpression creates an ast.CallExpression from the operator '|>', which passes the value on its left
+// as first argument to the call on its right, so "x |> f(a)" is parsed as "f(x, a)".
+// Any other expression on its right is called with the value as only argument, so "x |> f" is parsed as "f(x)".
+func (p *Parser) parsePipeExpression(left ast.Expression) *ast.CallExpression {
+	exp := &ast.CallExpression{Token: p.currentToken}
+
+	pre := p.currentPrecedence()
+	p.nextToken()
+
+	right := p.parseExpression(pre)
+	if right == nil {
+		return nil
+	}
+
+	if call, ok := right.(*ast.CallExpression); ok && call.Token.Type == token.LPAREN {
+		exp.Function = call.Function
+		exp.Arguments = append([]ast.Expression{left}, call.Arguments...)
+		exp.Rparen = call.Rparen
+		return exp
+	}
+	exp.Function = right
+	exp.Arguments = []ast.Expression{left}
+	return exp
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 
@@ -473,9 +745,57 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
+
+	// * the parentheses end the short-circuit of optional links within, which only matters if the chain is continued
+	switch p.peekToken.Type {
+	case token.LPAREN, token.LBRACKET, token.OPTIONAL_LBRACKET, token.DOT, token.OPTIONAL_DOT:
+		if hasOptionalLink(exp) {
+			setGrouped(exp)
+		}
+	}
 	return exp
 }
 
+// hasOptionalLink reports whether the expression is a chain with an optional link, which is not enclosed in parentheses itself.
+func hasOptionalLink(exp ast.Expression) bool {
+	for {
+		switch link := exp.(type) {
+		case *ast.IndexExpression:
+			if link.Optional {
+				return true
+			}
+			exp = link.Left
+		case *ast.PropertyExpression:
+			if link.Optional {
+				return true
+			}
+			exp = link.Object
+		case *ast.CallExpression:
+			if link.Token.Type == token.PIPE {
+				return false
+			}
+			exp = link.Function
+		default:
+			return false
+		}
+		if ast.IsGrouped(exp) {
+			return false
+		}
+	}
+}
+
+// setGrouped marks the link of a chain as enclosed in parentheses.
+func setGrouped(exp ast.Expression) {
+	switch link := exp.(type) {
+	case *ast.IndexExpression:
+		link.Grouped = true
+	case *ast.PropertyExpression:
+		link.Grouped = true
+	case *ast.CallExpression:
+		link.Grouped = true
+	}
+}
+
 func (p *Parser) parseCallExpression() *ast.CallExpression {
 	exp := &ast.CallExpression{Token: p.currentToken}
 
@@ -485,6 +805,38 @@ func (p *Parser) parseCallExpression() *ast.CallExpression {
 	}
 
 	exp.Arguments = arguments
+	exp.Rparen = p.currentToken.Position
+	return exp
+}
+
+func (p *Parser) parseIndexExpression() *ast.IndexExpression {
+	exp := &ast.IndexExpression{Token: p.currentToken, Optional: p.currentTokenIs(token.OPTIONAL_LBRACKET)}
+
+	p.nextToken()
+
+	index := p.parseExpression(LOWEST)
+	if index == nil {
+		return nil
+	}
+
+	exp.Index = index
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	exp.Rbracket = p.currentToken.Position
+	return exp
+}
+
+func (p *Parser) parsePropertyExpression() *ast.PropertyExpression {
+	exp := &ast.PropertyExpression{Token: p.currentToken, Optional: p.currentTokenIs(token.OPTIONAL_DOT)}
+
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	exp.Property = p.parseIdentifier().(*ast.Identifier)
 	return exp
 }
 
@@ -496,13 +848,18 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 		return list
 	}
 
-	for !p.currentTokenIs(token.RPAREN) {
+	for !p.currentTokenIs(end) {
 		if p.peekTokenIs(token.EOF) {
 			return nil
 		}
 
 		p.nextToken()
-		expr := p.parseExpression(LOWEST)
+		var expr ast.Expression
+		if p.currentTokenIs(token.ELLIPSIS) {
+			expr = p.parseSpreadExpression()
+		} else {
+			expr = p.parseExpression(LOWEST)
+		}
 		list = append(list, expr)
 
 		if !p.peekTokenIs(end) && !p.peekTokenIs(token.COMMA) {
@@ -516,6 +873,21 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+// parseSpreadExpression creates an ast.SpreadExpression, which is only allowed in argument lists and array literals.
+func (p *Parser) parseSpreadExpression() ast.Expression {
+	exp := &ast.SpreadExpression{Token: p.currentToken}
+
+	p.nextToken()
+
+	value := p.parseExpression(LOWEST)
+	if value == nil {
+		return nil
+	}
+
+	exp.Value = value
+	return exp
+}
+
 func (p *Parser) parseIfExpression() *ast.IfExpression {
 	exp := &ast.IfExpression{Token: p.currentToken}
 
@@ -566,10 +938,80 @@ func (p *Parser) parseIfExpression() *ast.IfExpression {
 	return exp
 }
 
+func (p *Parser) parseTryExpression() *ast.TryExpression {
+	exp := &ast.TryExpression{Token: p.currentToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	block := p.parseBlockStatement()
+	if block == nil {
+		return nil
+	}
+
+	exp.Block = block
+
+	// * check if there is a CATCH block
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+
+		exp.CatchParameter = p.parseIdentifier().(*ast.Identifier)
+
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		catch := p.parseBlockStatement()
+		if catch == nil {
+			return nil
+		}
+
+		exp.Catch = catch
+	}
+
+	// * check if there is a FINALLY block
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		finally := p.parseBlockStatement()
+		if finally == nil {
+			return nil
+		}
+
+		exp.Finally = finally
+	}
+
+	if exp.Catch == nil && exp.Finally == nil {
+		msg := fmt.Sprintf("try expression needs at least one of %q or %q, got token of type %q with literal %q", token.CATCH, token.FINALLY, p.peekToken.Type, p.peekToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	return exp
+}
+
 // nextToken advances the tokens read from the internal Lexer.
+// Comments are skipped and collected, so they can be attached to the program.
 func (p *Parser) nextToken() {
 	p.currentToken = p.peekToken
 	p.peekToken = p.lx.NextToken()
+	for p.peekToken.Type == token.COMMENT {
+		p.comments = append(p.comments, &ast.Comment{Token: p.peekToken})
+		p.peekToken = p.lx.NextToken()
+	}
 }
 
 // expectPeek compares the next token against the provided.
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/benchmarks"
 	"github.com/smalldevshima/go-monkey/lexer"
 	"github.com/smalldevshima/go-monkey/token"
 )
@@ -100,6 +101,74 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestThrowStatements(t *testing.T) {
+	input := `throw "oops"; throw x + 1`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	expected := []string{`oops`, "(x + 1)"}
+	for index, stmt := range program.Statements {
+		throw, ok := stmt.(*ast.ThrowStatement)
+		if !ok {
+			t.Fatalf("program.Statements[%d] is not *ast.ThrowStatement. got=%T", index, stmt)
+		}
+		if throw.Value.String() != expected[index] {
+			t.Errorf("throw.Value.String is wrong. expected=%q, got=%q", expected[index], throw.Value.String())
+		}
+	}
+}
+
+func TestImportAndExportStatements(t *testing.T) {
+	input := `import "lib/math.mk" as math; export let two = math.add(1, 1);`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	imp, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ImportStatement. got=%T", program.Statements[0])
+	}
+	if imp.Path.Value != "lib/math.mk" {
+		t.Errorf("imp.Path.Value is wrong. expected=%q, got=%q", "lib/math.mk", imp.Path.Value)
+	}
+	checkIdentifier(t, imp.Name, "math")
+
+	export, ok := program.Statements[1].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[1] is not *ast.ExportStatement. got=%T", program.Statements[1])
+	}
+	checkIdentifier(t, export.Statement.Name, "two")
+	if export.Statement.Value.String() != "(math.add)(1, 1)" {
+		t.Errorf("export.Statement.Value.String is wrong. expected=%q, got=%q", "(math.add)(1, 1)", export.Statement.Value.String())
+	}
+
+	invalid := []string{
+		`import lib as lib`,
+		`import "lib.mk"`,
+		`import "lib.mk" as "lib"`,
+		`export fn f() {}`,
+		`export 1`,
+		`fn() { import "lib.mk" as lib }`,
+		`if (true) { export let x = 1 }`,
+	}
+	for _, input := range invalid {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for input %q", input)
+		}
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := `foobar;`
 
@@ -139,6 +208,7 @@ func TestLiteralExpression(t *testing.T) {
 		{"false;", false},
 		{`"hello world";`, `"hello world"`},
 		{"myVar;", "myVar"},
+		{"null;", nil},
 	}
 
 	for _, test := range literalTests {
@@ -312,6 +382,58 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"!(true == true)",
 			"(!(true == true));",
 		},
+		{
+			"a ?? b == c",
+			"(a ?? (b == c));",
+		},
+		{
+			"a ?? b ?? c",
+			"((a ?? b) ?? c);",
+		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d);",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])));",
+		},
+		{
+			"a?[b]?.c ?? -d",
+			"(((a?[b])?.c) ?? (-d));",
+		},
+		{
+			"-lib.x.y(z) * 2",
+			"((-((lib.x).y)(z)) * 2);",
+		},
+		{
+			"x |> f",
+			"f(x);",
+		},
+		{
+			"x |> f(a, b)",
+			"f(x, a, b);",
+		},
+		{
+			"x |> f(a) |> g |> h(b)",
+			"h(g(f(x, a)), b);",
+		},
+		{
+			"a + b |> f * 2 < c",
+			"((f * 2)((a + b)) < c);",
+		},
+		{
+			"x |> f(a)(b) == y ?? z",
+			"((f(a)(x, b) == y) ?? z);",
+		},
+		{
+			"x |> lib.f(a)",
+			"(lib.f)(x, a);",
+		},
+		{
+			"x |> (y |> f)",
+			"f(y)(x);",
+		},
 	}
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
@@ -398,6 +520,49 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestTryExpression(t *testing.T) {
+	tryTests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"catch", "try { a } catch (e) { b }", "try { a; } catch (e) { b; }"},
+		{"finally", "try { a } finally { c }", "try { a; } finally { c; }"},
+		{"catch-finally", "try { a } catch (err) { b } finally { c }", "try { a; } catch (err) { b; } finally { c; }"},
+	}
+
+	for _, test := range tryTests {
+		t.Run("try/"+test.name, func(t *testing.T) {
+			p := New(lexer.New(test.input))
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+			if len(program.Statements) != 1 {
+				t.Fatalf("program.Statements does not contain 1 statement. got=%d: %s", len(program.Statements), program.Statements)
+			}
+
+			stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+			}
+			exp, ok := stmt.Expression.(*ast.TryExpression)
+			if !ok {
+				t.Fatalf("stmt.Expression is not *ast.TryExpression. got=%T", stmt.Expression)
+			}
+			if exp.String() != test.expected {
+				t.Errorf("exp.String is wrong.\nexpected:\n\t%s\ngot:\n\t%s", test.expected, exp)
+			}
+		})
+	}
+
+	t.Run("try/missing-branches", func(t *testing.T) {
+		p := New(lexer.New("try { a }"))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected parser errors for try without catch and finally")
+		}
+	})
+}
+
 func TestFunctionLiteral(t *testing.T) {
 	fnTests := []struct {
 		input   string
@@ -458,6 +623,43 @@ func TestFunctionLiteral(t *testing.T) {
 	}
 }
 
+func TestNamedFunctionLiteral(t *testing.T) {
+	fnTests := []struct {
+		input    string
+		name     string
+		expected string
+	}{
+		{"fn add(a, b) { a + b }", "add", "fn add(a, b) { (a + b); };"},
+		{"let f = fn g() { 1 }", "g", "let f = fn g() { 1; };"},
+	}
+	for _, test := range fnTests {
+		t.Run("namedFunction/"+test.name, func(t *testing.T) {
+			p := New(lexer.New(test.input))
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+			if len(program.Statements) != 1 {
+				t.Fatalf("program.Statements does not contain 1 statement. got=%d: %s", len(program.Statements), program.Statements)
+			}
+
+			var fn *ast.FunctionLiteral
+			switch stmt := program.Statements[0].(type) {
+			case *ast.ExpressionStatement:
+				fn, _ = stmt.Expression.(*ast.FunctionLiteral)
+			case *ast.LetStatement:
+				fn, _ = stmt.Value.(*ast.FunctionLiteral)
+			}
+			if fn == nil {
+				t.Fatalf("program.Statements[0] does not contain *ast.FunctionLiteral. got=%s", program.Statements[0])
+			}
+
+			checkIdentifier(t, fn.Name, test.name)
+			if program.String() != test.expected {
+				t.Errorf("program.String is wrong.\nexpected:\n\t%s\ngot:\n\t%s", test.expected, program)
+			}
+		})
+	}
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -483,6 +685,89 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionParameterDefaultsAndRest(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 10) {}", "fn(a, b = 10) {  };"},
+		{"fn(a = 1 + 2, b = a) {}", "fn(a = (1 + 2), b = a) {  };"},
+		{"fn(...rest) {}", "fn(...rest) {  };"},
+		{"fn(a, b = 1, ...rest) {}", "fn(a, b = 1, ...rest) {  };"},
+		{"f(...xs)", "f(...xs);"},
+		{"[1, ...xs, ...[2, 3]]", "[1, ...xs, ...[2, 3]];"},
+	}
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != test.expected {
+			t.Errorf("program.String is wrong.\nexpected:\n\t%q\ngot:\n\t%q", test.expected, program.String())
+		}
+	}
+
+	invalid := []string{
+		"fn(a = 1, b) {}",
+		"fn(...rest, a) {}",
+		"fn(...) {}",
+	}
+	for _, input := range invalid {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for input %q", input)
+		}
+	}
+}
+
+func TestMacroLiteral(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d: %s", len(program.Statements), program.Statements)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.MacroLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro.Parameters does not contain 2 identifiers. got=%d", len(macro.Parameters))
+	}
+	checkIdentifier(t, macro.Parameters[0], "x")
+	checkIdentifier(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements does not contain 1 statement. got=%d", len(macro.Body.Statements))
+	}
+	body, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro.Body.Statements[0] is not *ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+	checkInfixExpression(t, body.Expression, "x", "+", "y")
+
+	invalid := []string{
+		"macro(a = 1) {}",
+		"macro(...rest) {}",
+		"macro x() {}",
+	}
+	for _, input := range invalid {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for input %q", input)
+		}
+	}
+}
+
 func TestFunctionCallExpression(t *testing.T) {
 	callTests := []struct {
 		name      string
@@ -621,6 +906,245 @@ func TestParsingArrayLiterals(t *testing.T) {
 	}
 }
 
+func TestParsingIndexExpressions(t *testing.T) {
+	indexTests := []struct {
+		name     string
+		input    string
+		left     string
+		index    interface{}
+		optional bool
+	}{
+		{"index/identifier", "myArray[1]", "myArray", 1, false},
+		{"index/optional", "myHash?[key]", "myHash", "key", true},
+	}
+
+	for _, test := range indexTests {
+		t.Run(test.name, func(t *testing.T) {
+			l := lexer.New(test.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("Statements[0] is not *ast.ExpressionStatement, got=%T", program.Statements[0])
+			}
+
+			exp, ok := stmt.Expression.(*ast.IndexExpression)
+			if !ok {
+				t.Fatalf("stmt.Expression is not *ast.IndexExpression, got=%T", stmt.Expression)
+			}
+
+			checkIdentifier(t, exp.Left, test.left)
+			checkLiteralExpression(t, exp.Index, test.index)
+			if exp.Optional != test.optional {
+				t.Errorf("exp.Optional is not %v. got=%v", test.optional, exp.Optional)
+			}
+		})
+	}
+}
+
+func TestParsingPropertyExpressions(t *testing.T) {
+	propertyTests := []struct {
+		name     string
+		input    string
+		optional bool
+	}{
+		{"property/plain", "config.timeout", false},
+		{"property/optional", "config?.timeout", true},
+	}
+
+	for _, test := range propertyTests {
+		t.Run(test.name, func(t *testing.T) {
+			l := lexer.New(test.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("Statements[0] is not *ast.ExpressionStatement, got=%T", program.Statements[0])
+			}
+
+			exp, ok := stmt.Expression.(*ast.PropertyExpression)
+			if !ok {
+				t.Fatalf("stmt.Expression is not *ast.PropertyExpression, got=%T", stmt.Expression)
+			}
+
+			checkIdentifier(t, exp.Object, "config")
+			checkIdentifier(t, exp.Property, "timeout")
+			if exp.Optional != test.optional {
+				t.Errorf("exp.Optional is not %v. got=%v", test.optional, exp.Optional)
+			}
+		})
+	}
+}
+
+func TestParsingGroupedChains(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		grouped bool
+	}{
+		{"optional/continued", "(a?.b).c", true},
+		{"optional/index", "(a?[0]).c", true},
+		{"optional/call", "(a?.b(1)).c", true},
+		{"optional/not-continued", "(a?.b) + 1", false},
+		{"plain", "(a.b).c", false},
+		{"already-grouped", "((a?.b).c).d", false},
+		{"pipeline", "(x |> a?.b).c", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := New(lexer.New(test.input))
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("Statements[0] is not *ast.ExpressionStatement, got=%T", program.Statements[0])
+			}
+
+			var operand ast.Expression
+			switch exp := stmt.Expression.(type) {
+			case *ast.PropertyExpression:
+				operand = exp.Object
+			case *ast.InfixExpression:
+				operand = exp.Left
+			default:
+				t.Fatalf("stmt.Expression is not *ast.PropertyExpression or *ast.InfixExpression, got=%T", stmt.Expression)
+			}
+			if ast.IsGrouped(operand) != test.grouped {
+				t.Errorf("operand %s is not grouped=%v", operand, test.grouped)
+			}
+		})
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	hashTests := []struct {
+		name     string
+		input    string
+		expected map[string]string
+	}{
+		{"empty", "{}", map[string]string{}},
+		{"string-keys", `{"one": 1, "two": 2, "three": 3}`, map[string]string{"one": "1", "two": "2", "three": "3"}},
+		{"expression-values", `{"one": 0 + 1, "two": 10 - 8}`, map[string]string{"one": "(0 + 1)", "two": "(10 - 8)"}},
+	}
+
+	for _, test := range hashTests {
+		t.Run(test.name, func(t *testing.T) {
+			l := lexer.New(test.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("Statements[0] is not *ast.ExpressionStatement, got=%T", program.Statements[0])
+			}
+
+			hash, ok := stmt.Expression.(*ast.HashLiteral)
+			if !ok {
+				t.Fatalf("stmt.Expression is not *ast.HashLiteral, got=%T", stmt.Expression)
+			}
+
+			if len(hash.Pairs) != len(test.expected) {
+				t.Fatalf("len(hash.Pairs) not %d, got=%d", len(test.expected), len(hash.Pairs))
+			}
+			for _, pair := range hash.Pairs {
+				key, ok := pair.Key.(*ast.StringLiteral)
+				if !ok {
+					t.Fatalf("key is not *ast.StringLiteral, got=%T", pair.Key)
+				}
+				if pair.Value.String() != test.expected[key.Value] {
+					t.Errorf("value for key %q is wrong. expected=%q, got=%q", key.Value, test.expected[key.Value], pair.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// add adds
+let add = fn(a, b) {
+	a + b // sum
+};
+// end`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement, got=%d", len(program.Statements))
+	}
+
+	expected := []struct {
+		text     string
+		position token.Position
+	}{
+		{"// add adds", token.Position{Line: 1, Column: 1}},
+		{"// sum", token.Position{Line: 3, Column: 8}},
+		{"// end", token.Position{Line: 5, Column: 1}},
+	}
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("program.Comments does not contain %d comments, got=%d", len(expected), len(program.Comments))
+	}
+	for index, comment := range program.Comments {
+		if comment.Text() != expected[index].text || comment.Pos() != expected[index].position {
+			t.Errorf("comment[%d] is wrong. expected=%q at %s, got=%q at %s", index, expected[index].text, expected[index].position, comment.Text(), comment.Pos())
+		}
+	}
+}
+
+func TestClosingPositions(t *testing.T) {
+	input := "let x = fn() {\n\tf(a)[[1, 2]]\n};\n{\"a\": 1}"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	fn := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	index := fn.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+	call := index.Left.(*ast.CallExpression)
+	array := index.Index.(*ast.ArrayLiteral)
+	hash := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+
+	tests := []struct {
+		name     string
+		have     token.Position
+		expected token.Position
+	}{
+		{"block", fn.Body.Rbrace, token.Position{Line: 3, Column: 1}},
+		{"call", call.Rparen, token.Position{Line: 2, Column: 5}},
+		{"index", index.Rbracket, token.Position{Line: 2, Column: 13}},
+		{"array", array.Rbracket, token.Position{Line: 2, Column: 12}},
+		{"hash", hash.Rbrace, token.Position{Line: 4, Column: 8}},
+	}
+	for _, test := range tests {
+		if test.have != test.expected {
+			t.Errorf("closing position of %s is wrong. expected=%s, got=%s", test.name, test.expected, test.have)
+		}
+	}
+}
+
+/// Benchmarks
+
+func BenchmarkParser(b *testing.B) {
+	for _, program := range benchmarks.Programs() {
+		b.Run(program.Name, func(b *testing.B) {
+			b.SetBytes(int64(len(program.Source)))
+			for i := 0; i < b.N; i++ {
+				New(lexer.New(program.Source)).ParseProgram()
+			}
+		})
+	}
+}
+
 /// helpers
 
 func checkParserErrors(t *testing.T, p *Parser) {
@@ -700,6 +1224,18 @@ func checkStringLiteral(t *testing.T, exp ast.Expression, value string) {
 	}
 }
 
+func checkNullLiteral(t *testing.T, exp ast.Expression) {
+	t.Helper()
+	nullLit, ok := exp.(*ast.NullLiteral)
+	if !ok {
+		t.Errorf("exp is not *ast.NullLiteral. got=%T", exp)
+		return
+	}
+	if nullLit.Token.Type != token.NULL {
+		t.Errorf("nullLit.Token.Type is not %q. got=%q", token.NULL, nullLit.Token.Type)
+	}
+}
+
 func checkIdentifier(t *testing.T, exp ast.Expression, value string) {
 	t.Helper()
 	ident, ok := exp.(*ast.Identifier)
@@ -729,6 +1265,8 @@ func checkLiteralExpression(t *testing.T, exp ast.Expression, expected interface
 		}
 	case bool:
 		checkBooleanLiteral(t, exp, v)
+	case nil:
+		checkNullLiteral(t, exp)
 	default:
 		t.Errorf("type of expected not handled. got=%T", expected)
 	}
@@ -4,19 +4,39 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"io/fs"
 
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/compiler"
 	"github.com/smalldevshima/go-monkey/evaluator"
 	"github.com/smalldevshima/go-monkey/lexer"
+	"github.com/smalldevshima/go-monkey/module"
 	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/optimizer"
 	"github.com/smalldevshima/go-monkey/parser"
+	"github.com/smalldevshima/go-monkey/vm"
 )
 
+/// Constants / Variables
+
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
+// Engines that execute Monkey programs
+const (
+	// ENGINE_EVAL walks the AST of the program with the evaluator package
+	ENGINE_EVAL Engine = "eval"
+	// ENGINE_VM compiles the program to bytecode and executes it with the vm package
+	ENGINE_VM Engine = "vm"
+)
+
+/// Functions
+
+func Start(in io.Reader, out io.Writer, options Options) {
 	scanner := bufio.NewScanner(in)
 	writer := bufio.NewWriter(out)
-	env := object.NewEnvironment()
+	s := newSession(options)
+	// macros are kept between lines, like the global bindings of the session
+	macros := object.NewEnvironment()
 
 	for {
 		writer.WriteString(PROMPT)
@@ -36,14 +56,124 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			writer.WriteString(evaluated.Inspect())
-		} else {
-			writer.WriteString("nil")
+		program, err := expandMacros(program, macros)
+		if err != nil {
+			printMacroError(writer, err)
+			continue
+		}
+
+		if options.Optimize {
+			program = optimizer.Optimize(program)
+		}
+
+		result, err := s.execute(program)
+		if err != nil {
+			printCompilerError(writer, err)
+			continue
+		}
+		printResult(writer, result)
+	}
+}
+
+// Run parses and executes the given Monkey program with the options and writes its result to out.
+// Parser errors, compiler errors and runtime errors, including their traceback, are also written to out.
+// Run reports whether the program was executed without errors.
+func Run(input string, out io.Writer, options Options) bool {
+	writer := bufio.NewWriter(out)
+	defer writer.Flush()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(writer, p.Errors())
+		return false
+	}
+
+	program, err := expandMacros(program, object.NewEnvironment())
+	if err != nil {
+		printMacroError(writer, err)
+		return false
+	}
+
+	if options.Optimize {
+		program = optimizer.Optimize(program)
+	}
+
+	result, err := newSession(options).execute(program)
+	if err != nil {
+		printCompilerError(writer, err)
+		return false
+	}
+	printResult(writer, result)
+	return result == nil || result.Type() != object.O_ERROR
+}
+
+// RunBytecode executes the compiled program with the VM and writes its result to out, just like Run.
+func RunBytecode(bytecode *compiler.Bytecode, out io.Writer) bool {
+	writer := bufio.NewWriter(out)
+	defer writer.Flush()
+
+	result := vm.New(bytecode).Run()
+	printResult(writer, result)
+	return result == nil || result.Type() != object.O_ERROR
+}
+
+// expandMacros defines the macros of the program in env and expands their calls.
+func expandMacros(program *ast.Program, env *object.Environment) (*ast.Program, error) {
+	evaluator.DefineMacros(program, env)
+	return evaluator.ExpandMacros(program, env)
+}
+
+// NewLoader creates the loader of the modules imported by programs executed with the options.
+// Modules are prepared like the programs themselves, by expanding their macros and optimizing them if enabled.
+func NewLoader(options Options) *module.Loader {
+	return &module.Loader{
+		FS:          options.FS,
+		SearchPaths: options.SearchPaths,
+		Prepare: func(program *ast.Program) (*ast.Program, error) {
+			program, err := expandMacros(program, object.NewEnvironment())
+			if err != nil {
+				return nil, err
+			}
+			if options.Optimize {
+				program = optimizer.Optimize(program)
+			}
+			return program, nil
+		},
+	}
+}
+
+// newSession creates the state of the engine that is kept between executed programs.
+func newSession(options Options) session {
+	loader := NewLoader(options)
+	if options.Engine == ENGINE_VM {
+		return &vmSession{
+			loader:      loader,
+			path:        options.Path,
+			symbolTable: compiler.NewGlobalSymbolTable(),
+			constants:   []object.Object{},
+			globals:     make([]object.Object, vm.GlobalsSize),
 		}
-		writer.WriteString("\n")
 	}
+	e := evaluator.New()
+	e.Loader = loader
+	e.Path = options.Path
+	return &evalSession{evaluator: e, env: object.NewEnvironment()}
+}
+
+// printResult writes the inspected result object, or the traceback if the result is an error.
+func printResult(out *bufio.Writer, result object.Object) {
+	switch result := result.(type) {
+	case nil:
+		out.WriteString("nil")
+	case *object.Error:
+		out.WriteString(result.Traceback())
+	default:
+		out.WriteString(result.Inspect())
+	}
+	out.WriteString("\n")
 }
 
 func printParserErrors(out *bufio.Writer, errors []string) {
@@ -56,3 +186,65 @@ func printParserErrors(out *bufio.Writer, errors []string) {
 		out.WriteString(fmt.Sprintf("%3d: %s\n", i+1, msg))
 	}
 }
+
+func printMacroError(out *bufio.Writer, err error) {
+	out.WriteString(fmt.Sprintf("macro expansion error: %s\n", err))
+}
+
+func printCompilerError(out *bufio.Writer, err error) {
+	out.WriteString(fmt.Sprintf("compiler error: %s\n", err))
+}
+
+/// Types
+
+// Engine selects how Monkey programs are executed.
+type Engine string
+
+// Options configure how Start and Run execute programs.
+type Options struct {
+	Engine Engine
+	// Optimize enables rewriting programs with the optimizer package before executing them
+	Optimize bool
+	// Path is the source file of the executed program, which relative import paths are resolved against
+	Path string
+	// SearchPaths are the directories searched for imported modules that are not found relative to the program
+	SearchPaths []string
+	// FS is the file system that imported modules are read from, nil means the file system of the operating system
+	FS fs.FS
+}
+
+// session executes programs one after another, sharing global bindings between them.
+type session interface {
+	execute(program *ast.Program) (object.Object, error)
+}
+
+type evalSession struct {
+	// the evaluator is kept, so that imported modules are evaluated once per session
+	evaluator *evaluator.Evaluator
+	env       *object.Environment
+}
+
+func (s *evalSession) execute(program *ast.Program) (object.Object, error) {
+	return s.evaluator.Eval(program, s.env), nil
+}
+
+type vmSession struct {
+	loader      *module.Loader
+	path        string
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+}
+
+func (s *vmSession) execute(program *ast.Program) (object.Object, error) {
+	comp := compiler.NewWithState(s.symbolTable, s.constants)
+	comp.Loader = s.loader
+	comp.Path = s.path
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	bytecode := comp.Bytecode()
+	s.constants = bytecode.Constants
+	return vm.NewWithGlobalsStore(bytecode, s.globals).Run(), nil
+}
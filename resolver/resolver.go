@@ -0,0 +1,428 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Constants / Variables
+
+// Severities of diagnostics
+const (
+	// SEVERITY_ERROR diagnostics describe code that fails when it is executed
+	SEVERITY_ERROR Severity = "error"
+	// SEVERITY_WARNING diagnostics describe code that is likely a mistake
+	SEVERITY_WARNING Severity = "warning"
+)
+
+// Diagnostic messages
+const (
+	DIAG_UNKNOWN_IDENTIFIER    DiagnosticFormat = "unknown identifier: %s"
+	DIAG_SHADOWED_BINDING      DiagnosticFormat = "declaration of %s shadows the binding declared at %s"
+	DIAG_SHADOWED_PREDECLARED  DiagnosticFormat = "declaration of %s shadows a predeclared binding"
+	DIAG_UNUSED_LET            DiagnosticFormat = "%s is declared but never used"
+	DIAG_UNUSED_LET_REDECLARED DiagnosticFormat = "%s is declared %d times but never used"
+	DIAG_UNUSED_IMPORT         DiagnosticFormat = "%s is imported but never used"
+)
+
+// Kinds of declarations
+const (
+	declarationLet declarationKind = iota
+	declarationParameter
+	declarationFunction
+	declarationCatchParameter
+	declarationImport
+)
+
+/// Functions
+
+// Resolve resolves the identifiers of the program and reports diagnostics about its bindings.
+//
+// The predeclared names are defined before the program is run, like builtins or the bindings of previously run programs.
+// Their storage is unknown to the resolver, so identifiers referring to them remain unresolved.
+func Resolve(program *ast.Program, predeclared ...string) *Resolution {
+	r := &resolver{
+		predeclared: make(map[string]bool, len(predeclared)),
+		resolution: &Resolution{
+			Bindings: make(map[*ast.Identifier]Binding),
+			Scopes:   make(map[ast.Node]*Scope),
+		},
+	}
+	for _, name := range predeclared {
+		r.predeclared[name] = true
+	}
+
+	r.enterScope(program)
+	r.hoist(program.Statements)
+	r.resolveStatements(program.Statements)
+	r.leaveScope()
+
+	sort.SliceStable(r.resolution.Diagnostics, func(i, j int) bool {
+		a, b := r.resolution.Diagnostics[i].Position, r.resolution.Diagnostics[j].Position
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return r.resolution
+}
+
+/// Types
+
+type Severity string
+
+type DiagnosticFormat string
+
+// Diagnostic is a problem found in a program without running it.
+type Diagnostic struct {
+	Severity Severity
+	Message  string
+	Position token.Position
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Position, d.Severity, d.Message)
+}
+
+// Binding is the storage location of a resolved identifier.
+type Binding struct {
+	// Depth is the number of environments between the one the identifier is used in and the one defining it.
+	// Environments are created for the program, every function call and every catch-branch.
+	Depth int
+	// Slot is the index of the binding within the scope defining it.
+	Slot int
+}
+
+// Scope describes the bindings of one environment.
+type Scope struct {
+	// Names contains the name of every binding by slot.
+	Names []string
+}
+
+// Resolution is the result of resolving a program.
+type Resolution struct {
+	// Bindings maps the resolved identifiers to their binding.
+	// This includes the identifiers declaring bindings, which always have a depth of zero.
+	Bindings map[*ast.Identifier]Binding
+	// Scopes maps the nodes creating environments to their scope.
+	// These are the program, function literals and try-expressions with a catch-branch.
+	Scopes map[ast.Node]*Scope
+	// Diagnostics are sorted by position.
+	Diagnostics []Diagnostic
+}
+
+// HasErrors reports whether any diagnostic has SEVERITY_ERROR.
+func (r *Resolution) HasErrors() bool {
+	for _, diagnostic := range r.Diagnostics {
+		if diagnostic.Severity == SEVERITY_ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+type declarationKind int
+
+// symbol is a binding declared in a scope.
+type symbol struct {
+	slot int
+	kind declarationKind
+	// the position of the first declaration
+	position token.Position
+	// the number of let statements declaring the binding
+	lets int
+	used bool
+	// whether the binding is exported from the module, so that it may be used by other programs
+	exported bool
+}
+
+// scope is the state of a scope while it is resolved.
+type scope struct {
+	outer   *scope
+	scope   *Scope
+	symbols map[string]*symbol
+}
+
+type resolver struct {
+	predeclared map[string]bool
+	resolution  *Resolution
+	scope       *scope
+}
+
+func (r *resolver) enterScope(node ast.Node) {
+	s := &Scope{Names: []string{}}
+	r.resolution.Scopes[node] = s
+	r.scope = &scope{outer: r.scope, scope: s, symbols: make(map[string]*symbol)}
+}
+
+// leaveScope reports unused let bindings and imports of the current scope and returns to the enclosing one.
+func (r *resolver) leaveScope() {
+	for _, name := range r.scope.scope.Names {
+		sym := r.scope.symbols[name]
+		if sym.used || sym.exported {
+			continue
+		}
+		switch {
+		case sym.kind == declarationImport:
+			r.report(SEVERITY_WARNING, sym.position, DIAG_UNUSED_IMPORT, name)
+		case sym.kind != declarationLet:
+		case sym.lets > 1:
+			r.report(SEVERITY_WARNING, sym.position, DIAG_UNUSED_LET_REDECLARED, name, sym.lets)
+		default:
+			r.report(SEVERITY_WARNING, sym.position, DIAG_UNUSED_LET, name)
+		}
+	}
+	r.scope = r.scope.outer
+}
+
+func (r *resolver) report(severity Severity, position token.Position, format DiagnosticFormat, a ...interface{}) {
+	r.resolution.Diagnostics = append(r.resolution.Diagnostics, Diagnostic{
+		Severity: severity,
+		Message:  fmt.Sprintf(string(format), a...),
+		Position: position,
+	})
+}
+
+// declare defines the name in the current scope, unless it already is, and reports if it shadows an enclosing binding.
+func (r *resolver) declare(ident *ast.Identifier, kind declarationKind) {
+	sym, ok := r.scope.symbols[ident.Value]
+	if !ok {
+		r.checkShadowing(ident)
+		sym = &symbol{slot: len(r.scope.scope.Names), kind: kind, position: ident.Pos()}
+		r.scope.symbols[ident.Value] = sym
+		r.scope.scope.Names = append(r.scope.scope.Names, ident.Value)
+	}
+	if kind == declarationLet {
+		sym.lets++
+	} else {
+		sym.kind = kind
+	}
+}
+
+func (r *resolver) checkShadowing(ident *ast.Identifier) {
+	for s := r.scope.outer; s != nil; s = s.outer {
+		if sym, ok := s.symbols[ident.Value]; ok {
+			r.report(SEVERITY_WARNING, ident.Pos(), DIAG_SHADOWED_BINDING, ident.Value, sym.position)
+			return
+		}
+	}
+	if r.predeclared[ident.Value] {
+		r.report(SEVERITY_WARNING, ident.Pos(), DIAG_SHADOWED_PREDECLARED, ident.Value)
+	}
+}
+
+// bind records the binding of an identifier declaring a binding in the current scope.
+func (r *resolver) bind(ident *ast.Identifier) {
+	r.resolution.Bindings[ident] = Binding{Depth: 0, Slot: r.scope.symbols[ident.Value].slot}
+}
+
+// hoist declares the bindings created by let statements and function declarations in the statements and the blocks within them.
+// They belong to the current scope no matter where in it they occur, since blocks of if- and try-expressions share the environment.
+// Function literals and catch-branches have scopes of their own.
+func (r *resolver) hoist(statements []ast.Statement) {
+	for _, stmt := range statements {
+		r.hoistNode(stmt)
+	}
+}
+
+func (r *resolver) hoistNode(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		r.declare(node.Name, declarationLet)
+		r.hoistNode(node.Value)
+	case *ast.ExpressionStatement:
+		if fl, ok := node.Expression.(*ast.FunctionLiteral); ok && fl.Name != nil {
+			r.declare(fl.Name, declarationFunction)
+			return
+		}
+		r.hoistNode(node.Expression)
+	case *ast.ReturnStatement:
+		r.hoistNode(node.ReturnValue)
+	case *ast.ThrowStatement:
+		r.hoistNode(node.Value)
+	case *ast.ImportStatement:
+		r.declare(node.Name, declarationImport)
+	case *ast.ExportStatement:
+		r.hoistNode(node.Statement)
+		r.scope.symbols[node.Statement.Name.Value].exported = true
+	case *ast.BlockStatement:
+		if node != nil {
+			r.hoist(node.Statements)
+		}
+	case *ast.IfExpression:
+		r.hoistNode(node.Condition)
+		r.hoistNode(node.Then)
+		if node.Otherwise != nil {
+			r.hoistNode(node.Otherwise)
+		}
+	case *ast.TryExpression:
+		r.hoistNode(node.Block)
+		if node.Finally != nil {
+			r.hoistNode(node.Finally)
+		}
+	case *ast.PrefixExpression:
+		r.hoistNode(node.Right)
+	case *ast.InfixExpression:
+		r.hoistNode(node.Left)
+		r.hoistNode(node.Right)
+	case *ast.CallExpression:
+		r.hoistNode(node.Function)
+		for _, arg := range node.Arguments {
+			r.hoistNode(arg)
+		}
+	case *ast.SpreadExpression:
+		r.hoistNode(node.Value)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			r.hoistNode(el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			r.hoistNode(pair.Key)
+			r.hoistNode(pair.Value)
+		}
+	case *ast.IndexExpression:
+		r.hoistNode(node.Left)
+		r.hoistNode(node.Index)
+	case *ast.PropertyExpression:
+		r.hoistNode(node.Object)
+	}
+}
+
+func (r *resolver) resolveStatements(statements []ast.Statement) {
+	for _, stmt := range statements {
+		r.resolveNode(stmt)
+	}
+}
+
+func (r *resolver) resolveNode(node ast.Node) {
+	switch node := node.(type) {
+	// * Statements:
+	case *ast.LetStatement:
+		r.resolveNode(node.Value)
+		r.bind(node.Name)
+	case *ast.ExpressionStatement:
+		r.resolveNode(node.Expression)
+		if fl, ok := node.Expression.(*ast.FunctionLiteral); ok && fl.Name != nil {
+			r.bind(fl.Name)
+		}
+	case *ast.ReturnStatement:
+		r.resolveNode(node.ReturnValue)
+	case *ast.ThrowStatement:
+		r.resolveNode(node.Value)
+	case *ast.ImportStatement:
+		r.bind(node.Name)
+	case *ast.ExportStatement:
+		r.resolveNode(node.Statement)
+	case *ast.BlockStatement:
+		if node != nil {
+			r.resolveStatements(node.Statements)
+		}
+
+	// * Expressions:
+	case *ast.Identifier:
+		r.resolveIdentifier(node)
+	case *ast.FunctionLiteral:
+		r.resolveFunctionLiteral(node)
+	case *ast.IfExpression:
+		r.resolveNode(node.Condition)
+		r.resolveNode(node.Then)
+		if node.Otherwise != nil {
+			r.resolveNode(node.Otherwise)
+		}
+	case *ast.TryExpression:
+		r.resolveTryExpression(node)
+	case *ast.PrefixExpression:
+		r.resolveNode(node.Right)
+	case *ast.InfixExpression:
+		r.resolveNode(node.Left)
+		r.resolveNode(node.Right)
+	case *ast.CallExpression:
+		r.resolveNode(node.Function)
+		for _, arg := range node.Arguments {
+			r.resolveNode(arg)
+		}
+	case *ast.SpreadExpression:
+		r.resolveNode(node.Value)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			r.resolveNode(el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			r.resolveNode(pair.Key)
+			r.resolveNode(pair.Value)
+		}
+	case *ast.IndexExpression:
+		r.resolveNode(node.Left)
+		r.resolveNode(node.Index)
+	case *ast.PropertyExpression:
+		r.resolveNode(node.Object)
+	}
+}
+
+// resolveIdentifier binds an identifier to the innermost binding of its name.
+func (r *resolver) resolveIdentifier(ident *ast.Identifier) {
+	depth := 0
+	for s := r.scope; s != nil; s = s.outer {
+		if sym, ok := s.symbols[ident.Value]; ok {
+			sym.used = true
+			r.resolution.Bindings[ident] = Binding{Depth: depth, Slot: sym.slot}
+			return
+		}
+		depth++
+	}
+
+	if !r.predeclared[ident.Value] {
+		r.report(SEVERITY_ERROR, ident.Pos(), DIAG_UNKNOWN_IDENTIFIER, ident.Value)
+	}
+}
+
+// resolveFunctionLiteral resolves the function in a new scope, which holds the parameters followed by the hoisted bindings of the body.
+// Default values are resolved in that scope as well, since they are evaluated in the environment of the call.
+func (r *resolver) resolveFunctionLiteral(fl *ast.FunctionLiteral) {
+	r.enterScope(fl)
+	defer r.leaveScope()
+
+	for _, param := range fl.Parameters {
+		r.declare(param, declarationParameter)
+	}
+	if fl.Rest != nil {
+		r.declare(fl.Rest, declarationParameter)
+	}
+	r.hoist(fl.Body.Statements)
+	for _, def := range fl.Defaults {
+		if def != nil {
+			r.hoistNode(def)
+		}
+	}
+
+	for index, param := range fl.Parameters {
+		if def := fl.Default(index); def != nil {
+			r.resolveNode(def)
+		}
+		r.bind(param)
+	}
+	if fl.Rest != nil {
+		r.bind(fl.Rest)
+	}
+	r.resolveStatements(fl.Body.Statements)
+}
+
+// resolveTryExpression resolves the catch-branch in a new scope, which holds the catch parameter followed by the hoisted bindings of the branch.
+func (r *resolver) resolveTryExpression(te *ast.TryExpression) {
+	r.resolveNode(te.Block)
+
+	if te.Catch != nil {
+		r.enterScope(te)
+		r.declare(te.CatchParameter, declarationCatchParameter)
+		r.hoist(te.Catch.Statements)
+		r.bind(te.CatchParameter)
+		r.resolveStatements(te.Catch.Statements)
+		r.leaveScope()
+	}
+
+	if te.Finally != nil {
+		r.resolveNode(te.Finally)
+	}
+}
@@ -0,0 +1,172 @@
+package resolver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/smalldevshima/go-monkey/ast"
+	"github.com/smalldevshima/go-monkey/lexer"
+	"github.com/smalldevshima/go-monkey/parser"
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Tests
+
+func TestBindings(t *testing.T) {
+	input := `let a = 1;
+let f = fn(b, c = b, ...d) {
+	let e = a + b;
+	if (true) { let g = e }
+	fn h() { [a, b, e, h] }
+	try { g } catch (err) { [err, e, c, d] }
+};
+f(a)`
+
+	tests := []struct {
+		name     string
+		position token.Position
+		expected Binding
+	}{
+		{"a", token.Position{Line: 1, Column: 5}, Binding{Depth: 0, Slot: 0}},
+		{"f", token.Position{Line: 2, Column: 5}, Binding{Depth: 0, Slot: 1}},
+		{"b", token.Position{Line: 2, Column: 12}, Binding{Depth: 0, Slot: 0}},
+		{"c", token.Position{Line: 2, Column: 15}, Binding{Depth: 0, Slot: 1}},
+		{"b", token.Position{Line: 2, Column: 19}, Binding{Depth: 0, Slot: 0}},
+		{"d", token.Position{Line: 2, Column: 25}, Binding{Depth: 0, Slot: 2}},
+		{"e", token.Position{Line: 3, Column: 6}, Binding{Depth: 0, Slot: 3}},
+		{"a", token.Position{Line: 3, Column: 10}, Binding{Depth: 1, Slot: 0}},
+		{"g", token.Position{Line: 4, Column: 18}, Binding{Depth: 0, Slot: 4}},
+		{"h", token.Position{Line: 5, Column: 5}, Binding{Depth: 0, Slot: 5}},
+		{"a", token.Position{Line: 5, Column: 12}, Binding{Depth: 2, Slot: 0}},
+		{"b", token.Position{Line: 5, Column: 15}, Binding{Depth: 1, Slot: 0}},
+		{"h", token.Position{Line: 5, Column: 21}, Binding{Depth: 1, Slot: 5}},
+		{"g", token.Position{Line: 6, Column: 8}, Binding{Depth: 0, Slot: 4}},
+		{"err", token.Position{Line: 6, Column: 19}, Binding{Depth: 0, Slot: 0}},
+		{"err", token.Position{Line: 6, Column: 27}, Binding{Depth: 0, Slot: 0}},
+		{"e", token.Position{Line: 6, Column: 32}, Binding{Depth: 1, Slot: 3}},
+		{"d", token.Position{Line: 6, Column: 38}, Binding{Depth: 1, Slot: 2}},
+		{"f", token.Position{Line: 8, Column: 1}, Binding{Depth: 0, Slot: 1}},
+	}
+
+	resolution := Resolve(testParse(t, input))
+	identifiers := map[token.Position]*ast.Identifier{}
+	for ident := range resolution.Bindings {
+		identifiers[ident.Pos()] = ident
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s@%s", test.name, test.position), func(t *testing.T) {
+			ident, ok := identifiers[test.position]
+			if !ok || ident.Value != test.name {
+				t.Fatalf("no identifier %s at %s", test.name, test.position)
+			}
+			binding, ok := resolution.Bindings[ident]
+			if !ok {
+				t.Fatalf("identifier is not resolved")
+			}
+			if binding != test.expected {
+				t.Errorf("binding is wrong. expected=%+v, got=%+v", test.expected, binding)
+			}
+		})
+	}
+}
+
+func TestScopes(t *testing.T) {
+	program := testParse(t, "let a = 1; let f = fn(x, ...rest) { let y = 2; y }; try { let b = 3 } catch (e) { let c = e }")
+	resolution := Resolve(program)
+
+	fl := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	te := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.TryExpression)
+
+	tests := []struct {
+		name     string
+		node     ast.Node
+		expected []string
+	}{
+		{"program", program, []string{"a", "f", "b"}},
+		{"function", fl, []string{"x", "rest", "y"}},
+		{"catch", te, []string{"e", "c"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scope, ok := resolution.Scopes[test.node]
+			if !ok {
+				t.Fatalf("no scope for node")
+			}
+			if fmt.Sprint(scope.Names) != fmt.Sprint(test.expected) {
+				t.Errorf("names are wrong. expected=%v, got=%v", test.expected, scope.Names)
+			}
+		})
+	}
+
+	if len(resolution.Scopes) != len(tests) {
+		t.Errorf("wrong number of scopes. expected=%d, got=%d", len(tests), len(resolution.Scopes))
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		predeclared []string
+		expected    []string
+	}{
+		{"none", "let a = 1; let f = fn(x) { a + x }; f(2)", nil, []string{}},
+		{"unknown", "let f = fn() { x }; f()", nil, []string{"1:16: error: unknown identifier: x"}},
+		{"unknown/hash-key", "{a: 1}", nil, []string{"1:2: error: unknown identifier: a"}},
+		{"unknown/property", "let h = {}; h?.a", nil, []string{}},
+		{"predeclared", "len(x)", []string{"len", "x"}, []string{}},
+		{"later-declaration", "let f = fn() { g() }; let g = fn() { 1 }; f()", nil, []string{}},
+		{"unused", "let a = 1; let b = 2; b", nil, []string{"1:5: warning: a is declared but never used"}},
+		{"unused/redeclared", "let a = 1; let a = 2;", nil, []string{"1:5: warning: a is declared 2 times but never used"}},
+		{"unused/parameters-and-functions", "fn f(x) { 1 }; let g = fn(y, ...z) { try {} catch (e) {} }; g", nil, []string{}},
+		{"unused/in-function", "let f = fn() { let a = 1; 2 }; f", nil, []string{"1:20: warning: a is declared but never used"}},
+		{"unused/exported", "export let a = 1; let b = 2; export let b = 3;", nil, []string{}},
+		{"unused/import", `import "a.mk" as a; import "b.mk" as b; b.f`, nil, []string{"1:18: warning: a is imported but never used"}},
+		{"shadowing/import", `import "a.mk" as a; let f = fn(a) { a }; f(a)`, nil, []string{"1:32: warning: declaration of a shadows the binding declared at 1:18"}},
+		{"shadowing", "let a = 1; let f = fn(a) { let b = a; b }; f(a)", nil, []string{"1:23: warning: declaration of a shadows the binding declared at 1:5"}},
+		{"shadowing/catch", "let e = 1; try { e } catch (e) { e }", nil, []string{"1:29: warning: declaration of e shadows the binding declared at 1:5"}},
+		{"shadowing/predeclared", "let len = fn(x) { 1 }; len(1)", []string{"len"}, []string{"1:5: warning: declaration of len shadows a predeclared binding"}},
+		{"no-shadowing/same-scope", "let f = fn(a) { let a = 2; a }; f(1)", nil, []string{}},
+		{"sorted", "let f = fn() { let u = 1; y }; z; f", nil, []string{
+			"1:20: warning: u is declared but never used",
+			"1:27: error: unknown identifier: y",
+			"1:32: error: unknown identifier: z",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolution := Resolve(testParse(t, test.input), test.predeclared...)
+
+			actual := []string{}
+			for _, diagnostic := range resolution.Diagnostics {
+				actual = append(actual, diagnostic.String())
+			}
+			if fmt.Sprintf("%q", actual) != fmt.Sprintf("%q", test.expected) {
+				t.Errorf("diagnostics are wrong.\nexpected=%q\ngot=%q", test.expected, actual)
+			}
+
+			hasErrors := false
+			for _, diagnostic := range resolution.Diagnostics {
+				hasErrors = hasErrors || diagnostic.Severity == SEVERITY_ERROR
+			}
+			if resolution.HasErrors() != hasErrors {
+				t.Errorf("HasErrors is wrong. expected=%v", hasErrors)
+			}
+		})
+	}
+}
+
+/// helpers
+
+func testParse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+	return program
+}
@@ -1,5 +1,7 @@
 package token
 
+import "fmt"
+
 /// Constants and Variables
 
 // Possible token types for lexer/ parser/ ast
@@ -10,6 +12,7 @@ const (
 	IDENTIFIER TokenType = "IDENTIFIER"
 	INTEGER    TokenType = "INTEGER"
 	STRING     TokenType = "STRING"
+	COMMENT    TokenType = "COMMENT"
 
 	ASSIGN   TokenType = "="
 	PLUS     TokenType = "+"
@@ -21,9 +24,14 @@ const (
 	GT       TokenType = ">"
 	EQ       TokenType = "=="
 	NEQ      TokenType = "!="
+	NULLISH  TokenType = "??"
+	PIPE     TokenType = "|>"
 
 	COMMA     TokenType = ","
 	SEMICOLON TokenType = ";"
+	COLON     TokenType = ":"
+	ELLIPSIS  TokenType = "..."
+	DOT       TokenType = "."
 
 	LPAREN   TokenType = "("
 	RPAREN   TokenType = ")"
@@ -32,27 +40,52 @@ const (
 	LBRACKET TokenType = "["
 	RBRACKET TokenType = "]"
 
+	OPTIONAL_LBRACKET TokenType = "?["
+	OPTIONAL_DOT      TokenType = "?."
+
 	FUNCTION TokenType = "FUNCTION"
+	MACRO    TokenType = "MACRO"
 	RETURN   TokenType = "RETURN"
 	LET      TokenType = "LET"
 
 	TRUE  TokenType = "TRUE"
 	FALSE TokenType = "FALSE"
+	NULL  TokenType = "NULL"
 
 	IF   TokenType = "IF"
 	ELSE TokenType = "ELSE"
+
+	TRY     TokenType = "TRY"
+	CATCH   TokenType = "CATCH"
+	FINALLY TokenType = "FINALLY"
+	THROW   TokenType = "THROW"
+
+	IMPORT TokenType = "IMPORT"
+	EXPORT TokenType = "EXPORT"
+	AS     TokenType = "AS"
 )
 
 var (
 	// keywords is a map of literals to their corresponding TokenType.
 	keywords = map[string]TokenType{
 		"fn":     FUNCTION,
+		"macro":  MACRO,
 		"return": RETURN,
 		"let":    LET,
 		"true":   TRUE,
 		"false":  FALSE,
+		"null":   NULL,
 		"if":     IF,
 		"else":   ELSE,
+
+		"try":     TRY,
+		"catch":   CATCH,
+		"finally": FINALLY,
+		"throw":   THROW,
+
+		"import": IMPORT,
+		"export": EXPORT,
+		"as":     AS,
 	}
 )
 
@@ -72,6 +105,24 @@ func LookupIdent(ident string) TokenType {
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type    TokenType `json:"type"`
+	Literal string    `json:"literal"`
+	// the position of the first character of the token in the input
+	Position Position `json:"position"`
+}
+
+// Position describes a location in the input of the lexer.
+// Lines and columns are counted starting at 1, the zero value denotes an unknown position.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// IsValid reports whether the position is known.
+func (p Position) IsValid() bool {
+	return p.Line > 0
 }
@@ -0,0 +1,67 @@
+package vm
+
+import (
+	"github.com/smalldevshima/go-monkey/code"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Constants / Variables
+
+// Stages of an error handler
+const (
+	// stageTry is the stage while the try-branch is executed
+	stageTry handlerStage = iota
+	// stageCatch is the stage while the catch-branch is executed
+	stageCatch
+	// stageFinally is the stage while the finally-branch is executed
+	stageFinally
+)
+
+/// Types
+
+// Frame is the execution state of a single function call.
+type Frame struct {
+	cl *object.Closure
+	// the offset of the next instruction
+	ip int
+	// the local bindings of the call, nil for the program
+	scope *object.Scope
+	// the stack pointer before the function and its arguments were pushed
+	basePointer int
+	// the error handlers installed by try-expressions, innermost last
+	handlers []handler
+
+	// the display name of the function executing in the frame, which changes with tail calls
+	name string
+	// the position of the call expression that created the frame
+	call token.Position
+	// the number of tail calls of closures that replaced the function of the frame
+	tailCalls int
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+type handlerStage int
+
+// handler catches errors raised while executing the branches of a try-expression.
+type handler struct {
+	// the offsets of the catch- and finally-branch, zero if there is none
+	catch, finally int
+	// the stack pointer when the try-expression was entered
+	sp    int
+	stage handlerStage
+	// how the try- or catch-branch was left, resumed after the finally-branch
+	completion completion
+}
+
+// completion is the result of the try- or catch-branch of a try-expression.
+type completion struct {
+	value object.Object
+	// whether value is returned from the function
+	returning bool
+	// the error raised by the branch, nil if it was completed without an error
+	err *object.Error
+}
@@ -0,0 +1,95 @@
+package vm
+
+import (
+	"context"
+
+	"github.com/smalldevshima/go-monkey/evaluator"
+	"github.com/smalldevshima/go-monkey/object"
+)
+
+/// Functions
+
+func (vm *VM) maxCallDepth() int {
+	if vm.MaxCallDepth <= 0 {
+		return evaluator.DEFAULT_MAX_CALL_DEPTH
+	}
+	return vm.MaxCallDepth
+}
+
+func (vm *VM) maxTailCalls() int {
+	if vm.MaxTailCalls <= 0 {
+		return evaluator.DEFAULT_MAX_TAIL_CALLS
+	}
+	return vm.MaxTailCalls
+}
+
+// step counts an executed instruction and returns a fatal error if the run has to be aborted.
+func (vm *VM) step() *object.Error {
+	vm.executed++
+
+	if vm.MaxSteps > 0 && vm.executed > vm.MaxSteps {
+		return evaluator.NewFatalError(evaluator.ERR_STEP_LIMIT_EXCEEDED, vm.MaxSteps)
+	}
+
+	if vm.executed%evaluator.CONTEXT_CHECK_INTERVAL == 0 {
+		return vm.checkContext()
+	}
+
+	return nil
+}
+
+// checkContext returns a fatal error if the context of the run is done.
+func (vm *VM) checkContext() *object.Error {
+	switch vm.ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return evaluator.NewFatalError(evaluator.ERR_TIMEOUT)
+	default:
+		return evaluator.NewFatalError(evaluator.ERR_CANCELED)
+	}
+}
+
+// checkCollectionSize returns a fatal error if an array or hash of the given size must not be created.
+func (vm *VM) checkCollectionSize(size int) *object.Error {
+	if vm.MaxCollectionSize > 0 && size > vm.MaxCollectionSize {
+		return evaluator.NewFatalError(evaluator.ERR_COLLECTION_SIZE_EXCEEDED, vm.MaxCollectionSize, size)
+	}
+	return nil
+}
+
+// allocate accounts the given number of bytes and returns a fatal error if they exceed the memory quota of the run.
+func (vm *VM) allocate(size int64) *object.Error {
+	vm.allocated += size
+	if vm.MaxAllocatedBytes > 0 && vm.allocated > vm.MaxAllocatedBytes {
+		return evaluator.NewFatalError(evaluator.ERR_MEMORY_LIMIT_EXCEEDED, vm.MaxAllocatedBytes)
+	}
+	return nil
+}
+
+// allocateElements accounts the given number of elements appended to an array.
+func (vm *VM) allocateElements(array *object.Array, added int) *object.Error {
+	if err := vm.checkCollectionSize(len(array.Elements)); err != nil {
+		return err
+	}
+	return vm.allocate(evaluator.SIZE_ARRAY_ELEMENT * int64(added))
+}
+
+// allocateValue accounts a newly created object, checking the size of arrays and hashes as well.
+// It returns the object itself, or a fatal error if a limit is exceeded.
+func (vm *VM) allocateValue(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Array:
+		if err := vm.checkCollectionSize(len(obj.Elements)); err != nil {
+			return err
+		}
+	case *object.Hash:
+		if err := vm.checkCollectionSize(len(obj.Pairs)); err != nil {
+			return err
+		}
+	}
+	if err := vm.allocate(evaluator.ObjectSize(obj)); err != nil {
+		return err
+	}
+	return obj
+}
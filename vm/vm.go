@@ -0,0 +1,756 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/smalldevshima/go-monkey/code"
+	"github.com/smalldevshima/go-monkey/compiler"
+	"github.com/smalldevshima/go-monkey/evaluator"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/token"
+)
+
+/// Constants / Variables
+
+// StackSize is the initial size of the stack, which grows as needed
+const StackSize = 2048
+
+// GlobalsSize is the maximum number of globals
+const GlobalsSize = 1 << 16
+
+// infixOperators maps the opcodes of infix operators to the operator in the source
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpLessThan:    "<",
+	code.OpGreaterThan: ">",
+}
+
+// prefixOperators maps the opcodes of prefix operators to the operator in the source
+var prefixOperators = map[code.Opcode]string{
+	code.OpMinus: "-",
+	code.OpBang:  "!",
+}
+
+/// Functions
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsStore(bytecode, make([]object.Object, GlobalsSize))
+}
+
+// NewWithGlobalsStore creates a VM that uses the given globals, so that they can be kept between runs of several programs.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, Lines: bytecode.Lines}
+	mainFrame := &Frame{cl: &object.Closure{Fn: mainFn}, name: object.FRAME_PROGRAM}
+
+	return &VM{
+		MaxCallDepth: evaluator.DEFAULT_MAX_CALL_DEPTH,
+		MaxTailCalls: evaluator.DEFAULT_MAX_TAIL_CALLS,
+		constants:    bytecode.Constants,
+		globals:      globals,
+		globalNames:  bytecode.GlobalNames,
+		stack:        make([]object.Object, StackSize),
+		frames:       []*Frame{mainFrame},
+	}
+}
+
+// checkArgumentCount returns an error if the function cannot be called with the given number of arguments.
+func checkArgumentCount(fn *object.CompiledFunction, argc int) *object.Error {
+	optional := fn.NumParameters - fn.NumRequired
+
+	switch {
+	case fn.HasRest && argc < fn.NumRequired:
+		return evaluator.NewError(evaluator.ERR_ARG_COUNT_MINIMUM, fn.DisplayName(), fn.NumRequired, argc)
+	case !fn.HasRest && optional == 0 && argc != fn.NumRequired:
+		return evaluator.NewError(evaluator.ERR_ARG_COUNT_MISMATCH, fn.DisplayName(), fn.NumRequired, argc)
+	case !fn.HasRest && (argc < fn.NumRequired || argc > fn.NumParameters):
+		return evaluator.NewError(evaluator.ERR_ARG_COUNT_RANGE, fn.DisplayName(), fn.NumRequired, fn.NumParameters, argc)
+	}
+	return nil
+}
+
+/// Types
+
+// VM executes bytecode produced by the compiler package.
+// Results, including errors and their stack traces, match the evaluation of the same program by the evaluator package, except that:
+//   - when calling a value that is not a function, errors raised by the arguments take precedence,
+//     and likewise errors raised by hash values take precedence over unusable hash keys.
+//   - quote is not supported, so programs still calling it after macro expansion are rejected by the compiler.
+//
+// The limits of a run mirror the ones of evaluator.Evaluator, but steps are counted per executed instruction,
+// constants like string literals are not accounted as allocations, and the arguments of calls with spread arguments
+// are collected in an array accounted like an array literal, so the VM reaches the limits at different points.
+//
+// A VM must not be used for multiple runs concurrently.
+type VM struct {
+	// MaxCallDepth is the maximum number of nested function calls.
+	MaxCallDepth int
+	// MaxTailCalls is the maximum number of consecutive tail calls of closures within a single call,
+	// which replace the frame of the calling function and so do not count toward MaxCallDepth.
+	MaxTailCalls int
+	// MaxSteps is the maximum number of executed instructions, zero means no limit.
+	MaxSteps int64
+	// MaxDuration is the maximum wall-clock duration of a run, zero means no limit.
+	MaxDuration time.Duration
+	// MaxCollectionSize is the maximum number of elements of a single array or hash, zero means no limit.
+	MaxCollectionSize int
+	// MaxAllocatedBytes is the approximate number of bytes that strings, arrays and hashes created during a run
+	// may occupy in total, zero means no limit.
+	MaxAllocatedBytes int64
+
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	// the index of the next free slot of the stack
+	sp int
+
+	// the frame of the program followed by one frame per active function call
+	frames []*Frame
+	// the number of frames below the function called by a method, which the current run returns to, zero for the program
+	base int
+
+	// context of the current run
+	ctx context.Context
+	// number of executed instructions in the current run
+	executed int64
+	// approximate number of bytes allocated in the current run
+	allocated int64
+}
+
+// newScope binds the arguments to the parameters of the closure in a new scope enclosed by the closure's scope.
+// Parameters without argument remain unset, so that their default value is evaluated by the function itself.
+func (vm *VM) newScope(cl *object.Closure, args []object.Object) (*object.Scope, *object.Error) {
+	fn := cl.Fn
+	if err := checkArgumentCount(fn, len(args)); err != nil {
+		return nil, err
+	}
+
+	slots := make([]object.Object, fn.NumLocals)
+	if len(args) > fn.NumParameters {
+		copy(slots, args[:fn.NumParameters])
+	} else {
+		copy(slots, args)
+	}
+
+	if fn.HasRest {
+		rest := []object.Object{}
+		if len(args) > fn.NumParameters {
+			rest = append(rest, args[fn.NumParameters:]...)
+		}
+		restArray := vm.allocateValue(&object.Array{Elements: rest})
+		if err, ok := restArray.(*object.Error); ok {
+			return nil, err
+		}
+		slots[fn.NumParameters] = restArray
+	}
+
+	return &object.Scope{Function: fn, Slots: slots, Outer: cl.Scope}, nil
+}
+
+// Run executes the program and returns the value of its last statement, just like evaluator.Eval does.
+func (vm *VM) Run() object.Object {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext executes the program like Run, but aborts with a fatal error when the context is done or any limit of the VM is exceeded.
+func (vm *VM) RunContext(ctx context.Context) object.Object {
+	if vm.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, vm.MaxDuration)
+		defer cancel()
+	}
+
+	vm.ctx = ctx
+	vm.executed = 0
+	vm.allocated = 0
+	if err := vm.checkContext(); err != nil {
+		return err
+	}
+	return vm.run()
+}
+
+// run executes instructions until the outermost frame of the current run returns, and returns its result.
+func (vm *VM) run() object.Object {
+	for {
+		frame := vm.frames[len(vm.frames)-1]
+		ins := frame.Instructions()
+		ip := frame.ip
+		op := code.Opcode(ins[ip])
+
+		if err := vm.step(); err != nil {
+			err.Position = frame.cl.Fn.Lines.PositionAt(ip)
+			result, _ := vm.raise(err)
+			return result
+		}
+
+		var err *object.Error
+
+		switch op {
+		case code.OpConstant:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 3
+			vm.push(vm.constants[index])
+
+		case code.OpPop:
+			frame.ip++
+			vm.pop()
+
+		case code.OpTrue:
+			frame.ip++
+			vm.push(evaluator.TRUE)
+
+		case code.OpFalse:
+			frame.ip++
+			vm.push(evaluator.FALSE)
+
+		case code.OpNull:
+			frame.ip++
+			vm.push(evaluator.NULL)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan:
+			frame.ip++
+			right := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(vm.allocateValue(evaluator.InfixOperation(infixOperators[op], left, right)))
+
+		case code.OpMinus, code.OpBang:
+			frame.ip++
+			err = vm.pushResult(evaluator.PrefixOperation(prefixOperators[op], vm.pop()))
+
+		case code.OpJump:
+			frame.ip = int(code.ReadUint16(ins[ip+1:]))
+
+		case code.OpJumpNotTruthy:
+			frame.ip += 3
+			if !evaluator.IsTruthy(vm.pop()) {
+				frame.ip = int(code.ReadUint16(ins[ip+1:]))
+			}
+
+		case code.OpJumpNull:
+			frame.ip += 3
+			if vm.stack[vm.sp-1] == evaluator.NULL {
+				frame.ip = int(code.ReadUint16(ins[ip+1:]))
+			}
+
+		case code.OpJumpNotNull:
+			frame.ip += 3
+			if vm.stack[vm.sp-1] != evaluator.NULL {
+				frame.ip = int(code.ReadUint16(ins[ip+1:]))
+			} else {
+				vm.pop()
+			}
+
+		case code.OpGetGlobal:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 3
+			err = vm.pushBinding(vm.globals[index], vm.globalNames[index], nil)
+
+		case code.OpSetGlobal:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 3
+			vm.globals[index] = vm.pop()
+
+		case code.OpGetLocal:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 3
+			err = vm.pushBinding(frame.scope.Slots[index], frame.scope.Function.LocalNames[index], frame.scope.Outer)
+
+		case code.OpSetLocal:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 3
+			frame.scope.Slots[index] = vm.pop()
+
+		case code.OpGetFree:
+			depth := code.ReadUint8(ins[ip+1:])
+			index := code.ReadUint16(ins[ip+2:])
+			frame.ip += 4
+			scope := frame.scope
+			for ; depth > 0; depth-- {
+				scope = scope.Outer
+			}
+			err = vm.pushBinding(scope.Slots[index], scope.Function.LocalNames[index], scope.Outer)
+
+		case code.OpGetBuiltin:
+			index := code.ReadUint8(ins[ip+1:])
+			frame.ip += 2
+			vm.push(evaluator.Builtins()[index])
+
+		case code.OpArray:
+			count := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 3
+			elements := make([]object.Object, count)
+			copy(elements, vm.stack[vm.sp-count:vm.sp])
+			vm.sp -= count
+			err = vm.pushResult(vm.allocateValue(&object.Array{Elements: elements}))
+
+		case code.OpAppend:
+			frame.ip++
+			element := vm.pop()
+			array := vm.stack[vm.sp-1].(*object.Array)
+			array.Elements = append(array.Elements, element)
+			err = vm.allocateElements(array, 1)
+
+		case code.OpExtend:
+			frame.ip++
+			value := vm.pop()
+			spread, ok := value.(*object.Array)
+			if !ok {
+				err = evaluator.NewError(evaluator.ERR_SPREAD_NOT_ARRAY, value.Type())
+				break
+			}
+			array := vm.stack[vm.sp-1].(*object.Array)
+			array.Elements = append(array.Elements, spread.Elements...)
+			err = vm.allocateElements(array, len(spread.Elements))
+
+		case code.OpHash:
+			count := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 3
+			var hash *object.Hash
+			hash, err = vm.buildHash(vm.sp-count, vm.sp)
+			if err == nil {
+				vm.sp -= count
+				err = vm.pushResult(vm.allocateValue(hash))
+			}
+
+		case code.OpIndex:
+			frame.ip++
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.IndexOperation(left, index))
+
+		case code.OpProperty:
+			name := vm.constants[code.ReadUint16(ins[ip+1:])].(*object.String)
+			frame.ip += 3
+			err = vm.pushResult(evaluator.PropertyOperation(vm.pop(), name.Value))
+
+		case code.OpClosure:
+			fn := vm.constants[code.ReadUint16(ins[ip+1:])].(*object.CompiledFunction)
+			frame.ip += 3
+			vm.push(&object.Closure{Fn: fn, Scope: frame.scope})
+
+		case code.OpCall:
+			argc := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip += 2
+			err = vm.callFunction(argc, frame.cl.Fn.Lines.PositionAt(ip))
+
+		case code.OpCallArray:
+			frame.ip++
+			err = vm.callFunction(vm.spreadArguments(), frame.cl.Fn.Lines.PositionAt(ip))
+
+		case code.OpTailCall:
+			argc := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip += 2
+			err = vm.tailCallFunction(argc, frame.cl.Fn.Lines.PositionAt(ip))
+
+		case code.OpTailCallArray:
+			frame.ip++
+			err = vm.tailCallFunction(vm.spreadArguments(), frame.cl.Fn.Lines.PositionAt(ip))
+
+		case code.OpJumpIfBound:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 5
+			if frame.scope.Slots[index] != nil {
+				frame.ip = int(code.ReadUint16(ins[ip+3:]))
+			}
+
+		case code.OpReturnValue:
+			frame.ip++
+			if result, done := vm.returnValue(vm.pop()); done {
+				return result
+			}
+
+		case code.OpReturn:
+			frame.ip++
+			// * the program has no result, functions return null
+			var value object.Object = evaluator.NULL
+			if len(vm.frames) == 1 {
+				value = nil
+			}
+			if result, done := vm.returnValue(value); done {
+				return result
+			}
+
+		case code.OpThrow:
+			frame.ip++
+			err = evaluator.ErrorFromObject(vm.pop())
+
+		case code.OpTry:
+			catch := int(code.ReadUint16(ins[ip+1:]))
+			finally := int(code.ReadUint16(ins[ip+3:]))
+			frame.ip += 5
+			frame.handlers = append(frame.handlers, handler{catch: catch, finally: finally, sp: vm.sp})
+
+		case code.OpEndTry:
+			frame.ip++
+			value := vm.pop()
+			h := &frame.handlers[len(frame.handlers)-1]
+			if h.finally != 0 {
+				h.stage = stageFinally
+				h.completion = completion{value: value}
+				frame.ip = h.finally
+				break
+			}
+			frame.handlers = frame.handlers[:len(frame.handlers)-1]
+			vm.push(value)
+
+		case code.OpEndFinally:
+			frame.ip++
+			h := frame.handlers[len(frame.handlers)-1]
+			frame.handlers = frame.handlers[:len(frame.handlers)-1]
+			switch {
+			case h.completion.err != nil:
+				err = h.completion.err
+			case h.completion.returning:
+				if result, done := vm.returnValue(h.completion.value); done {
+					return result
+				}
+			default:
+				vm.push(h.completion.value)
+			}
+
+		case code.OpImport:
+			cached := vm.globals[code.ReadUint16(ins[ip+1:])]
+			fn := vm.constants[code.ReadUint16(ins[ip+3:])].(*object.CompiledFunction)
+			frame.ip += 5
+			if cached != nil {
+				vm.push(cached)
+			} else {
+				// * the module function does not enclose the scope of the importing program
+				vm.push(&object.Closure{Fn: fn})
+				err = vm.callFunction(0, frame.cl.Fn.Lines.PositionAt(ip))
+			}
+
+		case code.OpModule:
+			path := vm.constants[code.ReadUint16(ins[ip+1:])].(*object.String)
+			count := int(code.ReadUint16(ins[ip+3:]))
+			frame.ip += 5
+			exports := make(map[string]object.Object, count)
+			for index := vm.sp - 2*count; index < vm.sp; index += 2 {
+				exports[vm.stack[index].(*object.String).Value] = vm.stack[index+1]
+			}
+			vm.sp -= 2 * count
+			vm.push(&object.Module{Path: path.Value, Exports: exports})
+
+		default:
+			return &object.Error{Message: fmt.Sprintf("unknown opcode %d at offset %d", op, ip), Fatal: true}
+		}
+
+		if err != nil {
+			if !err.Position.IsValid() {
+				err.Position = frame.cl.Fn.Lines.PositionAt(ip)
+			}
+			if result, done := vm.raise(err); done {
+				return result
+			}
+		}
+	}
+}
+
+func (vm *VM) push(obj object.Object) {
+	if vm.sp >= len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+	vm.stack[vm.sp] = obj
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+// pushResult pushes the result of an operation, unless it is an error, which is returned instead.
+func (vm *VM) pushResult(result object.Object) *object.Error {
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+	vm.push(result)
+	return nil
+}
+
+// pushBinding pushes the value bound in a global or local slot.
+// If the slot has not been bound yet, because the let statement defining it has not been executed,
+// the name is looked up in the enclosing scopes, the globals and the builtins instead, just like the evaluator does.
+// The outer scope is the one enclosing the scope of the slot, nil for global slots and slots of the program's scope.
+func (vm *VM) pushBinding(value object.Object, name string, outer *object.Scope) *object.Error {
+	if value == nil {
+		value = vm.lookupName(name, outer)
+	}
+	if value == nil {
+		return evaluator.NewError(evaluator.ERR_IDENTIFIER_UNKNOWN, name)
+	}
+	vm.push(value)
+	return nil
+}
+
+// lookupName returns the value bound to the name in the innermost scope, starting with the given one, or nil if there is none.
+func (vm *VM) lookupName(name string, scope *object.Scope) object.Object {
+	for ; scope != nil; scope = scope.Outer {
+		for index, local := range scope.Function.LocalNames {
+			if local == name && scope.Slots[index] != nil {
+				return scope.Slots[index]
+			}
+		}
+	}
+
+	for index, global := range vm.globalNames {
+		if global == name && vm.globals[index] != nil {
+			return vm.globals[index]
+		}
+	}
+
+	for _, builtin := range evaluator.Builtins() {
+		if builtin.Name == name {
+			return builtin
+		}
+	}
+	return nil
+}
+
+// buildHash creates a hash from the keys and values in the given range of the stack.
+func (vm *VM) buildHash(start, end int) (*object.Hash, *object.Error) {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for i := start; i < end; i += 2 {
+		key, value := vm.stack[i], vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, evaluator.NewError(evaluator.ERR_UNHASHABLE, key.Type())
+		}
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return &object.Hash{Pairs: pairs}, nil
+}
+
+// spreadArguments replaces the array on top of the stack by its elements and returns their number.
+func (vm *VM) spreadArguments() int {
+	args := vm.pop().(*object.Array).Elements
+	for _, arg := range args {
+		vm.push(arg)
+	}
+	return len(args)
+}
+
+// callFunction calls the function below the given number of arguments on the stack.
+// Closures are executed in a new frame, builtins are applied immediately.
+func (vm *VM) callFunction(argc int, call token.Position) *object.Error {
+	basePointer := vm.sp - argc - 1
+	args := vm.stack[basePointer+1 : vm.sp]
+
+	switch callee := vm.stack[basePointer].(type) {
+	case *object.Closure:
+		if len(vm.frames)-1 >= vm.maxCallDepth() {
+			return evaluator.NewFatalError(evaluator.ERR_CALL_DEPTH_EXCEEDED, vm.maxCallDepth())
+		}
+		scope, err := vm.newScope(callee, args)
+		if err != nil {
+			return err
+		}
+		vm.sp = basePointer
+		vm.frames = append(vm.frames, &Frame{cl: callee, scope: scope, basePointer: basePointer, name: callee.Fn.DisplayName(), call: call})
+
+	case *object.Builtin:
+		if len(vm.frames)-1 >= vm.maxCallDepth() {
+			return evaluator.NewFatalError(evaluator.ERR_CALL_DEPTH_EXCEEDED, vm.maxCallDepth())
+		}
+		result := callee.Fn(append([]object.Object{}, args...)...)
+		vm.sp = basePointer
+		if err, ok := result.(*object.Error); ok {
+			return builtinError(err, callee.Name, call, call)
+		}
+		vm.push(result)
+
+	case *object.BoundMethod:
+		if len(vm.frames)-1 >= vm.maxCallDepth() {
+			return evaluator.NewFatalError(evaluator.ERR_CALL_DEPTH_EXCEEDED, vm.maxCallDepth())
+		}
+		result := callee.Fn(vm.runtime(call), callee.Receiver, append([]object.Object{}, args...)...)
+		vm.sp = basePointer
+		if err, ok := result.(*object.Error); ok {
+			return builtinError(err, callee.Name, call, call)
+		}
+		vm.push(result)
+
+	default:
+		return evaluator.NewError(evaluator.ERR_NOT_A_FUNCTION, callee.Type())
+	}
+
+	return nil
+}
+
+// tailCallFunction calls the function below the given number of arguments on the stack in place of the current function.
+// The current frame is reused for closures and removed for builtins.
+func (vm *VM) tailCallFunction(argc int, site token.Position) *object.Error {
+	frame := vm.frames[len(vm.frames)-1]
+	basePointer := vm.sp - argc - 1
+	args := vm.stack[basePointer+1 : vm.sp]
+
+	switch callee := vm.stack[basePointer].(type) {
+	case *object.Closure:
+		if frame.tailCalls >= vm.maxTailCalls() {
+			return evaluator.NewFatalError(evaluator.ERR_CALL_DEPTH_EXCEEDED, vm.maxTailCalls())
+		}
+		scope, err := vm.newScope(callee, args)
+		if err != nil {
+			return err
+		}
+		vm.sp = frame.basePointer
+		frame.cl = callee
+		frame.scope = scope
+		frame.ip = 0
+		frame.name = callee.Fn.DisplayName()
+		frame.tailCalls++
+
+	case *object.Builtin:
+		result := callee.Fn(append([]object.Object{}, args...)...)
+		vm.sp = frame.basePointer
+		vm.frames = vm.frames[:len(vm.frames)-1]
+		if err, ok := result.(*object.Error); ok {
+			// * the builtin takes the place of the current frame
+			return builtinError(err, callee.Name, site, frame.call)
+		}
+		vm.push(result)
+
+	case *object.BoundMethod:
+		result := callee.Fn(vm.runtime(frame.call), callee.Receiver, append([]object.Object{}, args...)...)
+		vm.sp = frame.basePointer
+		vm.frames = vm.frames[:len(vm.frames)-1]
+		if err, ok := result.(*object.Error); ok {
+			// * the method takes the place of the current frame
+			return builtinError(err, callee.Name, site, frame.call)
+		}
+		vm.push(result)
+
+	default:
+		return evaluator.NewError(evaluator.ERR_NOT_A_FUNCTION, callee.Type())
+	}
+
+	return nil
+}
+
+// builtinError adds the position of the call site and the frame of the builtin to an error returned by a builtin.
+func builtinError(err *object.Error, name string, site token.Position, call token.Position) *object.Error {
+	if !err.Position.IsValid() {
+		err.Position = site
+	}
+	if name != "" {
+		err.Stack = append(err.Stack, object.Frame{Function: name, Position: call})
+	}
+	return err
+}
+
+// runtime returns the Runtime of methods called at the given position.
+func (vm *VM) runtime(call token.Position) *object.Runtime {
+	return &object.Runtime{Call: vm.caller(call), Allocate: vm.allocateValue}
+}
+
+// caller returns the function that methods call functions with.
+// Closures are executed by a nested run of the VM, which returns once the closure has returned.
+// Their frames are attributed to the given call site, which is the one of the method.
+func (vm *VM) caller(call token.Position) object.CallFunction {
+	return func(fn object.Object, args ...object.Object) object.Object {
+		base, sp := vm.base, vm.sp
+		vm.base = len(vm.frames)
+		defer func() { vm.base = base }()
+
+		vm.push(fn)
+		for _, arg := range args {
+			vm.push(arg)
+		}
+		if err := vm.callFunction(len(args), call); err != nil {
+			vm.sp = sp
+			if !err.Position.IsValid() {
+				err.Position = call
+			}
+			return err
+		}
+		if len(vm.frames) == vm.base {
+			// * builtins and methods are applied without a frame
+			return vm.pop()
+		}
+		return vm.run()
+	}
+}
+
+// returnValue returns the value from the current function, executing the finally-branches of all active try-expressions of the frame first.
+// It reports whether the program has been returned from, in which case the value is its result.
+func (vm *VM) returnValue(value object.Object) (object.Object, bool) {
+	frame := vm.frames[len(vm.frames)-1]
+
+	for len(frame.handlers) > 0 {
+		h := &frame.handlers[len(frame.handlers)-1]
+		if h.finally != 0 && h.stage != stageFinally {
+			h.stage = stageFinally
+			h.completion = completion{value: value, returning: true}
+			vm.sp = h.sp
+			frame.ip = h.finally
+			return nil, false
+		}
+		frame.handlers = frame.handlers[:len(frame.handlers)-1]
+	}
+
+	if len(vm.frames) == 1 {
+		return value, true
+	}
+
+	vm.frames = vm.frames[:len(vm.frames)-1]
+	vm.sp = frame.basePointer
+	if len(vm.frames) == vm.base {
+		// * the function called by a method returns to the method
+		return value, true
+	}
+	vm.push(value)
+	return nil, false
+}
+
+// raise unwinds the frames until an error handler catches the error.
+// Every unwound function call is added to the stack of the error.
+// Fatal errors are not caught and do not execute finally-branches.
+// It reports whether the error has reached the program, in which case it is the result of the program.
+func (vm *VM) raise(err *object.Error) (object.Object, bool) {
+	for {
+		frame := vm.frames[len(vm.frames)-1]
+
+		for !err.Fatal && len(frame.handlers) > 0 {
+			h := &frame.handlers[len(frame.handlers)-1]
+			vm.sp = h.sp
+
+			switch {
+			case h.stage == stageTry && h.catch != 0:
+				h.stage = stageCatch
+				vm.push(evaluator.ErrorToHash(err))
+				frame.ip = h.catch
+				return nil, false
+			case h.stage != stageFinally && h.finally != 0:
+				h.stage = stageFinally
+				h.completion = completion{err: err}
+				frame.ip = h.finally
+				return nil, false
+			}
+
+			frame.handlers = frame.handlers[:len(frame.handlers)-1]
+		}
+
+		if len(vm.frames) == 1 {
+			return err, true
+		}
+
+		err.Stack = append(err.Stack, object.Frame{Function: frame.name, Position: frame.call})
+		vm.frames = vm.frames[:len(vm.frames)-1]
+		vm.sp = frame.basePointer
+		if len(vm.frames) == vm.base {
+			// * the error is returned to the method that called the function
+			return err, true
+		}
+	}
+}
@@ -0,0 +1,519 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/smalldevshima/go-monkey/benchmarks"
+	"github.com/smalldevshima/go-monkey/compiler"
+	"github.com/smalldevshima/go-monkey/evaluator"
+	"github.com/smalldevshima/go-monkey/lexer"
+	"github.com/smalldevshima/go-monkey/object"
+	"github.com/smalldevshima/go-monkey/parser"
+)
+
+/// Constants / Variables
+
+// evaluatorTestFile contains the test suite of the evaluator, whose inputs are run by both engines
+const evaluatorTestFile = "../evaluator/evaluator_test.go"
+
+const fibonacci = "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(20)"
+
+/// Tests
+
+func TestEvaluatorParity(t *testing.T) {
+	inputs := evaluatorTestInputs(t)
+	if len(inputs) < 100 {
+		t.Fatalf("found too few inputs in %s. got=%d", evaluatorTestFile, len(inputs))
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			p := parser.New(lexer.New(input.source))
+			program := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				t.Skipf("input is no valid program: %v", p.Errors())
+			}
+
+			expected := evaluator.Eval(program, object.NewEnvironment())
+			actual := testRun(t, input.source)
+			checkSameResult(t, expected, actual)
+		})
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"captured-argument", "let adder = fn(x) { fn(y) { x + y } }; adder(2)(3)", "5"},
+		{"nested", "let f = fn(a) { fn(b) { fn(c) { a + b + c } } }; f(1)(2)(3)", "6"},
+		{"later-binding", "let f = fn() { let g = fn() { x }; let x = 2; g() }; f()", "2"},
+		{"local-mutual-recursion", `
+			let f = fn(n) {
+				fn even(n) { if (n == 0) { true } else { odd(n - 1) } }
+				fn odd(n) { if (n == 0) { false } else { even(n - 1) } }
+				even(n)
+			};
+			f(11)`, "false"},
+		{"catch-parameter", `let f = try { throw 1 } catch (e) { fn() { e["value"] } }; f()`, "1"},
+		{"default-refers-to-parameter", "let f = fn(a, b = a * 2) { [a, b] }; f(3)", "[3, 6]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := testRun(t, test.input)
+			if result == nil || result.Inspect() != test.expected {
+				t.Errorf("result is wrong. expected=%s, got=%v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestGlobalsStore(t *testing.T) {
+	symbolTable := compiler.NewGlobalSymbolTable()
+	constants := []object.Object{}
+	globals := make([]object.Object, GlobalsSize)
+
+	var result object.Object
+	for _, line := range []string{"let x = 2;", "let double = fn(n) { n * x };", "double(21)"} {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(parser.New(lexer.New(line)).ParseProgram()); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+		result = NewWithGlobalsStore(bytecode, globals).Run()
+	}
+
+	if result == nil || result.Inspect() != "42" {
+		t.Errorf("result is wrong. expected=42, got=%v", result)
+	}
+}
+
+func TestModuleParity(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "math.mk"), `
+		let twice = fn(f, x) { f(f(x)) };
+		export let double = fn(x) { x * 2 };
+		export let quadruple = fn(x) { twice(double, x) };
+		export let answer = 42;`)
+	writeFile(t, filepath.Join(dir, "lib", "fail.mk"), `
+		import "../math.mk" as math;
+		let check = fn(x) { if (x) { throw math.answer } };
+		check(true);`)
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"export", `import "math.mk" as m; m.answer`},
+		{"closure", `import "math.mk" as m; m.quadruple(3)`},
+		{"in-function", `import "math.mk" as m; let f = fn(x) { m.double(x) + 1 }; f(1)`},
+		{"once", `import "math.mk" as m; import "./lib/../math.mk" as n; [m.double == n.double, m]`},
+		{"unexported", `import "math.mk" as m; m.twice`},
+		{"failing", "let x = 1;\nimport \"lib/fail.mk\" as f;"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			program := parser.New(lexer.New(test.input)).ParseProgram()
+			e := evaluator.New()
+			e.Path = filepath.Join(dir, "main.mk")
+			expected := e.Eval(program, object.NewEnvironment())
+
+			comp := compiler.New()
+			comp.Path = filepath.Join(dir, "main.mk")
+			if err := comp.Compile(program); err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+			actual := New(comp.Bytecode()).Run()
+			checkSameResult(t, expected, actual)
+		})
+	}
+}
+
+func TestModuleCompilerErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.mk"), `import "b.mk" as b; export let a = 1;`)
+	writeFile(t, filepath.Join(dir, "b.mk"), `import "a.mk" as a; export let b = 2;`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "missing.mk" as m;`, `cannot import "missing.mk" at 1:1: cannot find module "missing.mk"`},
+		{`import "a.mk" as a;`, fmt.Sprintf("import cycle: %[1]s/a.mk -> %[1]s/b.mk -> %[1]s/a.mk", dir)},
+	}
+
+	for _, test := range tests {
+		comp := compiler.New()
+		comp.Path = filepath.Join(dir, "main.mk")
+		err := comp.Compile(parser.New(lexer.New(test.input)).ParseProgram())
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("wrong compiler error for %q. expected=%q, got=%v", test.input, test.expected, err)
+		}
+	}
+}
+
+// TestQuoteParity checks that programs quoting expressions, which the evaluator supports, are rejected by the compiler.
+func TestQuoteParity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(1 + unquote(2 + 3))", "quote is not supported by the vm: call at 1:6"},
+		{"let f = fn(x) { quote(x) }; f(1)", "quote is not supported by the vm: call at 1:22"},
+	}
+
+	for _, test := range tests {
+		program := parser.New(lexer.New(test.input)).ParseProgram()
+		if evaluated := evaluator.Eval(program, object.NewEnvironment()); evaluated.Type() != object.O_QUOTE {
+			t.Errorf("evaluator result for %q is not a quote. got=%s", test.input, evaluated.Inspect())
+		}
+
+		err := compiler.New().Compile(program)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("wrong compiler error for %q. expected=%q, got=%v", test.input, test.expected, err)
+		}
+	}
+}
+
+func TestSerializedBytecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"fibonacci", "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10)"},
+		{"closures", "let adder = fn(x) { fn(y = 1, ...rest) { x + y + len(rest) } }; adder(2)(3, 4, 5)"},
+		{"traceback", "let f = fn(x) {\n\tx[\"key\"]\n};\nlet g = fn() { f(1) + 1 };\ng()"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := testCompile(t, test.input).WriteTo(&buf); err != nil {
+				t.Fatalf("could not serialize: %s", err)
+			}
+			bytecode, err := compiler.ReadBytecode(&buf)
+			if err != nil {
+				t.Fatalf("could not deserialize: %s", err)
+			}
+
+			checkSameResult(t, testRun(t, test.input), New(bytecode).Run())
+		})
+	}
+}
+
+func TestCallDepthLimit(t *testing.T) {
+	machine := New(testCompile(t, "let f = fn(x) { f(x) + 1 }; f(1)"))
+	machine.MaxCallDepth = 50
+
+	err, ok := machine.Run().(*object.Error)
+	if !ok {
+		t.Fatalf("result is not *object.Error")
+	}
+	if err.Message != "maximum call depth 50 exceeded" || !err.Fatal {
+		t.Errorf("err is wrong. got Message=%q, Fatal=%v", err.Message, err.Fatal)
+	}
+	if len(err.Stack) != 50 {
+		t.Errorf("err.Stack has wrong length. expected=50, got=%d", len(err.Stack))
+	}
+
+	t.Run("tail-calls/unbounded", func(t *testing.T) {
+		err, ok := New(testCompile(t, "let f = fn(x) { f(x) }; f(1)")).Run().(*object.Error)
+		if !ok {
+			t.Fatalf("result is not *object.Error")
+		}
+		if err.Message != "maximum call depth 1000000 exceeded" || !err.Fatal {
+			t.Errorf("err is wrong. got Message=%q, Fatal=%v", err.Message, err.Fatal)
+		}
+		if err.Traceback() != "ERROR: maximum call depth 1000000 exceeded\n\tat f (1:18)\n\tat <program> (1:26)" {
+			t.Errorf("err.Traceback() is wrong. got=%q", err.Traceback())
+		}
+	})
+}
+
+func TestContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	machine := New(testCompile(t, "let loop = fn(x) { loop(x) }; loop(1)"))
+	// * the loop must outlive the deadline instead of exceeding the tail call limit
+	machine.MaxTailCalls = math.MaxInt
+	result := machine.RunContext(ctx)
+	err, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("result is not *object.Error. got=%T (%+v)", result, result)
+	}
+	if err.Message != "evaluation timed out" || err.Kind != object.K_RESOURCE || !err.Fatal {
+		t.Errorf("err is wrong. got Message=%q, Kind=%q, Fatal=%v", err.Message, err.Kind, err.Fatal)
+	}
+}
+
+func TestResourceLimits(t *testing.T) {
+	const infiniteLoop = "let loop = fn(x) { loop(x) }; loop(1)"
+	const doubling = `let grow = fn(s, n) { if (n == 0) { s } else { grow(s + s, n - 1) } }; `
+
+	tests := []struct {
+		name      string
+		input     string
+		configure func(machine *VM)
+		expected  string
+	}{
+		{"steps/within", "1 + 2", func(machine *VM) { machine.MaxSteps = 10 }, "3"},
+		{"steps/exceeded", infiniteLoop, func(machine *VM) { machine.MaxSteps, machine.MaxTailCalls = 10000, math.MaxInt }, "maximum number of evaluation steps 10000 exceeded"},
+		{"steps/uncatchable", "try { " + infiniteLoop + " } catch (e) { 1 }", func(machine *VM) { machine.MaxSteps, machine.MaxTailCalls = 500, math.MaxInt }, "maximum number of evaluation steps 500 exceeded"},
+		{"duration/exceeded", infiniteLoop, func(machine *VM) { machine.MaxDuration, machine.MaxTailCalls = 20*time.Millisecond, math.MaxInt }, "evaluation timed out"},
+		{"collection/array/within", "len([1, 2, 3])", func(machine *VM) { machine.MaxCollectionSize = 3 }, "3"},
+		{"collection/array/exceeded", "[1, 2, 3, 4]", func(machine *VM) { machine.MaxCollectionSize = 3 }, "maximum collection size 3 exceeded. got=4"},
+		{"collection/array/spread", "let xs = [1, 2]; [...xs, ...xs]", func(machine *VM) { machine.MaxCollectionSize = 3 }, "maximum collection size 3 exceeded. got=4"},
+		{"collection/hash/exceeded", `{"a": 1, "b": 2, "c": 3, "d": 4}`, func(machine *VM) { machine.MaxCollectionSize = 3 }, "maximum collection size 3 exceeded. got=4"},
+		{"collection/rest/exceeded", "let f = fn(...rest) { rest }; f(1, 2, 3, 4)", func(machine *VM) { machine.MaxCollectionSize = 3 }, "maximum collection size 3 exceeded. got=4"},
+		{"collection/method/exceeded", "[1, 2, 3].push(4)", func(machine *VM) { machine.MaxCollectionSize = 3 }, "maximum collection size 3 exceeded. got=4"},
+		{"memory/string/within", `len("abc" + "def")`, func(machine *VM) { machine.MaxAllocatedBytes = 100 }, "6"},
+		{"memory/string/exceeded", `let s = "abcdefghij"; s + s + s`, func(machine *VM) { machine.MaxAllocatedBytes = 60 }, "maximum allocation of 60 bytes exceeded"},
+		{"memory/string/growing", doubling + `len(grow("x", 30))`, func(machine *VM) { machine.MaxAllocatedBytes = 1 << 20 }, "maximum allocation of 1048576 bytes exceeded"},
+		{"memory/array/exceeded", "[1, 2, 3, 4, 5, 6, 7, 8]", func(machine *VM) { machine.MaxAllocatedBytes = 100 }, "maximum allocation of 100 bytes exceeded"},
+		{"memory/hash/exceeded", `{1: 1, 2: 2}`, func(machine *VM) { machine.MaxAllocatedBytes = 100 }, "maximum allocation of 100 bytes exceeded"},
+		{"memory/rest/exceeded", "let f = fn(...rest) { 1 }; f(1, 2, 3, 4, 5, 6, 7, 8)", func(machine *VM) { machine.MaxAllocatedBytes = 100 }, "maximum allocation of 100 bytes exceeded"},
+		{"memory/method/exceeded", `"abcdefghij".upper()`, func(machine *VM) { machine.MaxAllocatedBytes = 20 }, "maximum allocation of 20 bytes exceeded"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			machine := New(testCompile(t, test.input))
+			test.configure(machine)
+
+			result := machine.Run()
+			err, ok := result.(*object.Error)
+			if !ok {
+				if result.Inspect() != test.expected {
+					t.Errorf("result is wrong. expected=%q, got=%q", test.expected, result.Inspect())
+				}
+				return
+			}
+			if err.Message != test.expected || err.Kind != object.K_RESOURCE || !err.Fatal {
+				t.Errorf("err is wrong. expected Message=%q, got Message=%q, Kind=%q, Fatal=%v", test.expected, err.Message, err.Kind, err.Fatal)
+			}
+		})
+	}
+}
+
+/// Benchmarks
+
+func BenchmarkFibonacci(b *testing.B) {
+	program := parser.New(lexer.New(fibonacci)).ParseProgram()
+
+	b.Run("evaluator", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			evaluator.Eval(program, object.NewEnvironment())
+		}
+	})
+
+	b.Run("vm", func(b *testing.B) {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			b.Fatalf("compiler error: %s", err)
+		}
+		bytecode := comp.Bytecode()
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			New(bytecode).Run()
+		}
+	})
+}
+
+func BenchmarkPrograms(b *testing.B) {
+	for _, program := range benchmarks.Programs() {
+		comp := compiler.New()
+		if err := comp.Compile(parser.New(lexer.New(program.Source)).ParseProgram()); err != nil {
+			b.Fatalf("compiler error in %s: %s", program.Name, err)
+		}
+		bytecode := comp.Bytecode()
+
+		b.Run(program.Name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if result := New(bytecode).Run(); result.Type() == object.O_ERROR {
+					b.Fatal(result.Inspect())
+				}
+			}
+		})
+	}
+}
+
+/// helpers
+
+type testInput struct {
+	name   string
+	source string
+}
+
+// evaluatorTestInputs extracts the Monkey programs from the evaluator test suite.
+// These are the "input" fields of test tables, and the string arguments of testEval calls or input variables.
+func evaluatorTestInputs(t *testing.T) []testInput {
+	t.Helper()
+	fset := gotoken.NewFileSet()
+	file, err := goparser.ParseFile(fset, evaluatorTestFile, nil, 0)
+	if err != nil {
+		t.Fatalf("could not parse %s: %s", evaluatorTestFile, err)
+	}
+
+	inputs := []testInput{}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+
+		add := func(node ast.Node, expr ast.Expr) {
+			if source, ok := stringConstant(expr); ok {
+				name := fmt.Sprintf("%s/line-%d", fn.Name.Name, fset.Position(node.Pos()).Line)
+				inputs = append(inputs, testInput{name: name, source: source})
+			}
+		}
+
+		ast.Inspect(fn, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.CompositeLit:
+				array, ok := node.Type.(*ast.ArrayType)
+				if !ok {
+					break
+				}
+				fields, ok := array.Elt.(*ast.StructType)
+				if !ok {
+					break
+				}
+				index := fieldIndex(fields, "input")
+				if index < 0 {
+					break
+				}
+				for _, el := range node.Elts {
+					if row, ok := el.(*ast.CompositeLit); ok && index < len(row.Elts) {
+						add(row, row.Elts[index])
+					}
+				}
+			case *ast.CallExpr:
+				if ident, ok := node.Fun.(*ast.Ident); ok && ident.Name == "testEval" && len(node.Args) == 1 {
+					add(node, node.Args[0])
+				}
+			case *ast.AssignStmt:
+				if ident, ok := node.Lhs[0].(*ast.Ident); ok && ident.Name == "input" && len(node.Rhs) == 1 {
+					add(node, node.Rhs[0])
+				}
+			}
+			return true
+		})
+	}
+
+	return inputs
+}
+
+// fieldIndex returns the index of the named field of the struct, or -1 if there is none.
+func fieldIndex(fields *ast.StructType, name string) int {
+	index := 0
+	for _, field := range fields.Fields.List {
+		for _, fieldName := range field.Names {
+			if fieldName.Name == name {
+				return index
+			}
+			index++
+		}
+	}
+	return -1
+}
+
+// stringConstant returns the value of a string literal or a concatenation of string literals.
+func stringConstant(expr ast.Expr) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		if expr.Kind != gotoken.STRING {
+			return "", false
+		}
+		value, err := strconv.Unquote(expr.Value)
+		return value, err == nil
+	case *ast.BinaryExpr:
+		left, ok := stringConstant(expr.X)
+		if !ok || expr.Op != gotoken.ADD {
+			return "", false
+		}
+		right, ok := stringConstant(expr.Y)
+		return left + right, ok
+	}
+	return "", false
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testCompile(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return comp.Bytecode()
+}
+
+func testRun(t *testing.T, input string) object.Object {
+	t.Helper()
+	return New(testCompile(t, input)).Run()
+}
+
+// checkSameResult checks that the VM produced the same result as the evaluator.
+// Errors are compared including their kind, position and traceback.
+func checkSameResult(t *testing.T, expected, actual object.Object) {
+	t.Helper()
+
+	if expected == nil || actual == nil {
+		if expected != actual {
+			t.Fatalf("results differ. evaluator=%v, vm=%v", expected, actual)
+		}
+		return
+	}
+
+	if expected.Type() != actual.Type() {
+		t.Fatalf("result types differ. evaluator=%s (%s), vm=%s (%s)", expected.Type(), expected.Inspect(), actual.Type(), actual.Inspect())
+	}
+
+	if expectedErr, ok := expected.(*object.Error); ok {
+		actualErr := actual.(*object.Error)
+		if expectedErr.Traceback() != actualErr.Traceback() {
+			t.Errorf("tracebacks differ.\nevaluator:\n%s\nvm:\n%s", expectedErr.Traceback(), actualErr.Traceback())
+		}
+		if expectedErr.Kind != actualErr.Kind || expectedErr.Fatal != actualErr.Fatal {
+			t.Errorf("errors differ. evaluator Kind=%q, Fatal=%v. vm Kind=%q, Fatal=%v", expectedErr.Kind, expectedErr.Fatal, actualErr.Kind, actualErr.Fatal)
+		}
+		return
+	}
+
+	if expected.Inspect() != actual.Inspect() {
+		t.Errorf("results differ.\nevaluator:\n%s\nvm:\n%s", expected.Inspect(), actual.Inspect())
+	}
+}